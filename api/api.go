@@ -0,0 +1,12 @@
+// Package api holds the API definitions of the payment service: the OpenAPI
+// document of the HTTP API and, under payment/v1, the protobuf definition of
+// the gRPC API.
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document of the HTTP API, served at /openapi.json.
+// Bump info.version when the API changes.
+//
+//go:embed openapi.json
+var OpenAPI []byte
@@ -1,47 +1,138 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"payment-service/api"
+	paymentv1 "payment-service/api/payment/v1"
+	"payment-service/internal/app"
+	"payment-service/internal/config"
 	"payment-service/internal/delivery"
-	"payment-service/internal/repository"
+	"payment-service/internal/logging"
+	"payment-service/internal/metrics"
+	"payment-service/internal/ratelimit"
+	"payment-service/internal/seed"
+	"payment-service/internal/tracing"
 	"payment-service/internal/usecase"
+	"payment-service/internal/worker"
 
 	"github.com/go-chi/chi"
-	_ "github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
+const usage = `Usage: payment-service [command]
+
+Commands:
+  serve [flags]         Start the HTTP and gRPC APIs (default)
+  migrate [flags] up    Apply all pending migrations
+  migrate [flags] down [n]
+                        Revert the last n migrations (default 1)
+  migrate [flags] status
+                        Show which migrations are applied
+  seed [flags]          Create demo or load test users and wallets
+                          --profile demo|loadtest  (default demo)
+                          --users N                number of generated users
+                          --seed S                 seed for IDs and balances (default 1)
+
+Every command accepts the configuration flags, e.g. --config FILE,
+--backend, --addr or --db-host; run "payment-service serve -h" for the list.
+Flags override environment variables, which override the config file.
+`
+
 func main() {
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbUser := getEnv("DB_USER", "user_payment")
-	dbPassword := getEnv("DB_PASSWORD", "pass_payment")
-	dbName := getEnv("DB_NAME", "db_payment")
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "serve":
+		serve(os.Args[2:])
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "seed":
+		runSeed(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// readinessTimeout bounds all checks of one /readyz request together. It must
+// stay below the probe timeouts of docker-compose and Kubernetes.
+const readinessTimeout = 2 * time.Second
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// traceFlushTimeout bounds exporting the spans still buffered on shutdown.
+const traceFlushTimeout = 5 * time.Second
 
-	db, err := sql.Open("postgres", dsn)
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg := loadConfig(flags, args)
+	logger, err := logging.New(os.Stderr, cfg.Log)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		log.Fatalf("failed to set up logging: %v", err)
 	}
-	defer db.Close()
+	// Route the log package, still used by the helpers shared with the
+	// other commands, through the structured logger as well.
+	slog.SetDefault(logger)
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("failed to ping database: %v", err)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	if cfg.Tracing.Exporter != "none" {
+		slog.Info("exporting traces", "exporter", cfg.Tracing.Exporter)
+	}
+	store := openStore(cfg)
+
+	if cfg.MigrateOnStartup && store.Persistent() {
+		migrateUp(store)
+	}
+
+	if cfg.OptimisticLocking {
+		slog.Info("using optimistic locking for wallet updates")
+	}
+	m := metrics.New()
+	if store.DB != nil {
+		m.RegisterDB(store.DB)
 	}
+	uc := app.NewUsecase(cfg, store.Repo, usecase.WithMetrics(m), usecase.WithLogger(logger))
 
-	log.Println("Connected to PostgreSQL database")
+	if !store.Persistent() {
+		if _, err := seed.Run(context.Background(), uc, seed.Options{Profile: "demo", Seed: 1}); err != nil {
+			log.Fatalf("failed to seed in-memory repository: %v", err)
+		}
+		slog.Warn("using in-memory repository seeded with the demo profile, data is lost on restart")
+	}
 
-	repo := repository.NewPostgresRepo(db)
-	uc := usecase.NewPaymentUsecase(repo)
 	handler := delivery.NewHttpHandler(uc)
 
 	r := chi.NewRouter()
+	r.Use(logging.RequestID)
+	r.Use(tracing.Middleware)
+	r.Use(logging.AccessLog(logger))
+	r.Use(m.Middleware)
+	r.Handle("/metrics", m.Handler())
+
+	docs := delivery.NewDocsHandler(api.OpenAPI)
+	r.Get("/openapi.json", docs.OpenAPI)
+	r.Get("/docs", docs.SwaggerUI)
 
 	// Tambahkan health check endpoint
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -49,20 +140,215 @@ func main() {
 		w.Write([]byte("Payment Service is running"))
 	})
 
-	r.Post("/transfer", handler.Transfer)
-	r.Post("/topup", handler.TopUp)
-	r.Get("/transaction/{refId}", handler.GetTransaction)
-	r.Get("/wallet/{userId}", handler.GetWallet)
+	checks, err := store.ReadinessChecks()
+	if err != nil {
+		log.Fatalf("failed to set up readiness checks: %v", err)
+	}
+
+	var workers []app.Worker
+	reconciliation := usecase.NewReconciliationUsecase(store.Repo, store.Reports)
+	if cfg.ReconcileInterval > 0 {
+		reconciler := worker.NewReconciler(reconciliation, cfg.ReconcileInterval)
+		reconciler.Metrics = m
+		workers = append(workers, reconciler)
+		checks = append(checks, delivery.HealthCheck{Name: "reconciler", Check: reconciler.Health})
+		slog.Info("reconciling the ledger periodically", "interval", cfg.ReconcileInterval.String())
+	}
+
+	batches := usecase.NewBatchUsecase(uc, store.Batches, usecase.BatchPolicy{
+		DefaultMode: cfg.Batch.DefaultMode,
+		MaxItems:    cfg.Batch.MaxItems,
+	})
+	if cfg.Batch.PollInterval > 0 {
+		processor := worker.NewBatchProcessor(batches, cfg.Batch.PollInterval)
+		processor.Metrics = m
+		workers = append(workers, processor)
+		checks = append(checks, delivery.HealthCheck{Name: "batch_processor", Check: processor.Health})
+	} else {
+		slog.Warn("BATCH_POLL_INTERVAL is 0, transfer batches are not processed by this instance")
+	}
+
+	schedules := usecase.NewScheduleUsecase(uc, store.Schedules, usecase.SchedulePolicy{
+		RetryInterval: cfg.Schedule.RetryInterval,
+		MaxRetries:    cfg.Schedule.MaxRetries,
+	})
+	if cfg.Schedule.PollInterval > 0 {
+		runner := worker.NewScheduleRunner(schedules, cfg.Schedule.PollInterval)
+		runner.Metrics = m
+		workers = append(workers, runner)
+		checks = append(checks, delivery.HealthCheck{Name: "schedule_runner", Check: runner.Health})
+	} else {
+		slog.Warn("SCHEDULE_POLL_INTERVAL is 0, scheduled transfers are not run by this instance")
+	}
+
+	if cfg.Server.GRPCAddr != "" {
+		grpcServer, err := newGRPCServer(cfg.Server, uc)
+		if err != nil {
+			log.Fatalf("failed to set up gRPC server: %v", err)
+		}
+		workers = append(workers, grpcServer)
+		checks = append(checks, delivery.HealthCheck{Name: "grpc", Check: grpcServer.Health})
+		slog.Info("starting gRPC server", "addr", cfg.Server.GRPCAddr, "tls", cfg.Server.TLS())
+	}
+
+	health := delivery.NewHealthHandler(readinessTimeout, checks...)
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", health.Ready)
 
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	batchHandler := delivery.NewBatchHandler(batches)
+	payoutHandler := delivery.NewPayoutHandler(uc)
+	scheduleHandler := delivery.NewScheduleHandler(schedules)
+	requestHandler := delivery.NewPaymentRequestHandler(usecase.NewPaymentRequestUsecase(uc, store.Requests))
+	splitHandler := delivery.NewSplitHandler(usecase.NewSplitUsecase(uc, store.Splits))
+	limiter := ratelimit.New(cfg.RateLimit, ratelimit.NewMemoryStore())
+	var admin *delivery.AdminHandler
+	if cfg.AdminToken != "" {
+		admin = delivery.NewAdminHandler(reconciliation)
+	} else {
+		slog.Warn("ADMIN_TOKEN is not set, admin endpoints are disabled")
 	}
+	v1 := func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(limiter.Middleware)
+			r.Post("/transfer", handler.Transfer)
+			r.Post("/topup", handler.TopUp)
+			r.Get("/transaction/{refId}", handler.GetTransaction)
+			r.Get("/wallet/{userId}", handler.GetWallet)
+			r.Post("/transfers/batch", batchHandler.SubmitBatch)
+			r.Get("/batches/{id}", batchHandler.GetBatch)
+			r.Post("/payouts", payoutHandler.UploadPayout)
+			r.Post("/schedules", scheduleHandler.CreateSchedule)
+			r.Get("/schedules/{id}", scheduleHandler.GetSchedule)
+			r.Post("/schedules/{id}/pause", scheduleHandler.PauseSchedule)
+			r.Post("/schedules/{id}/resume", scheduleHandler.ResumeSchedule)
+			r.Post("/schedules/{id}/cancel", scheduleHandler.CancelSchedule)
+			r.Post("/payment-requests", requestHandler.CreatePaymentRequest)
+			r.Get("/payment-requests", requestHandler.ListPaymentRequests)
+			r.Get("/payment-requests/{id}", requestHandler.GetPaymentRequest)
+			r.Post("/payment-requests/{id}/accept", requestHandler.AcceptPaymentRequest)
+			r.Post("/payment-requests/{id}/decline", requestHandler.DeclinePaymentRequest)
+			r.Post("/splits", splitHandler.CreateSplit)
+			r.Get("/splits/{id}", splitHandler.GetSplit)
+			r.Post("/splits/{id}/pay", splitHandler.PayShare)
+		})
+		if admin != nil {
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(delivery.RequireAdminToken(cfg.AdminToken))
+				r.Post("/reconciliations", admin.RunReconciliation)
+				r.Get("/reconciliations", admin.ListReconciliations)
+				r.Get("/reconciliations/{id}", admin.GetReconciliation)
+			})
+		}
+	}
+	// Breaking changes go to a /v2 router mounted next to /v1, which keeps
+	// serving its clients until it is deprecated in turn.
+	r.Route("/v1", v1)
+	if cfg.API.LegacyRoutes {
+		r.Group(func(r chi.Router) {
+			r.Use(delivery.Deprecated(cfg.API.DeprecatedSince, cfg.API.Sunset, "/v1"))
+			v1(r)
+		})
+		slog.Info("serving deprecated unversioned routes", "sunset", cfg.API.Sunset.Format(time.DateOnly))
+	}
+
+	lifecycle := &app.Lifecycle{
+		Server: &http.Server{
+			Addr:              cfg.Server.Addr,
+			Handler:           r,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		Workers:         workers,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+	}
+	if cfg.Server.TLS() {
+		lifecycle.Listen = func(srv *http.Server) error {
+			return srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		}
+		slog.Info("starting HTTPS server", "addr", cfg.Server.Addr)
+	} else {
+		slog.Info("starting server", "addr", cfg.Server.Addr)
+	}
+
+	// SIGTERM is what docker and Kubernetes send before killing the
+	// container.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = lifecycle.Run(ctx)
+	if closeErr := store.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to close database: %w", closeErr))
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to flush traces: %w", flushErr))
+	}
+	if err != nil {
+		log.Fatalf("server stopped: %v", err)
+	}
+	slog.Info("server stopped")
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// loadConfig registers the configuration flags with fs, parses args and
+// loads the configuration or exits.
+func loadConfig(fs *flag.FlagSet, args []string) config.Config {
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+	cfg, err := config.Load(flags)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+// openStore opens the repository selected by cfg or exits.
+func openStore(cfg config.Config) *app.Store {
+	store, err := app.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch cfg.Backend {
+	case "postgres":
+		log.Println("Connected to PostgreSQL database")
+	case "sqlite":
+		log.Printf("Using SQLite database %s", cfg.SQLitePath)
+	}
+	return store
+}
+
+// migrateUp applies pending migrations or exits.
+func migrateUp(store *app.Store) {
+	m, err := store.Migrator()
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+}
+
+// newGRPCServer listens on cfg.GRPCAddr and serves the PaymentService there,
+// with TLS when the HTTP server has it and server reflection so that tools
+// like grpcurl work without the proto files.
+func newGRPCServer(cfg config.Server, uc *usecase.PaymentUsecase) (*app.GRPCServer, error) {
+	opts := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+	if cfg.TLS() {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	paymentv1.RegisterPaymentServiceServer(srv, delivery.NewGrpcServer(uc))
+	reflection.Register(srv)
+
+	ln, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		return nil, err
 	}
-	return defaultValue
+	return &app.GRPCServer{Server: srv, Listener: ln}, nil
 }
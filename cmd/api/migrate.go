@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runMigrate implements the migrate command against the database selected by
+// REPO_BACKEND.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cfg := loadConfig(flags, args)
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	store := openStore(cfg)
+	defer store.Close()
+	m, err := store.Migrator()
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up failed: %v", err)
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of migrations %q", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("migrate down failed: %v", err)
+		}
+		for _, migration := range reverted {
+			log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status failed: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
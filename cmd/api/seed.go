@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"payment-service/internal/app"
+	"payment-service/internal/seed"
+)
+
+// runSeed implements the seed command against the database selected by
+// REPO_BACKEND. The schema must already be migrated.
+func runSeed(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	profile := flags.String("profile", "demo", "seed profile ("+strings.Join(seed.ProfileNames(), ", ")+")")
+	users := flags.Int("users", 0, "number of generated users (default depends on the profile)")
+	seedValue := flags.Uint64("seed", 1, "seed for generated IDs and balances")
+	cfg := loadConfig(flags, args)
+	store := openStore(cfg)
+	defer store.Close()
+	if !store.Persistent() {
+		log.Fatalf("REPO_BACKEND %q cannot be seeded, the memory backend seeds the demo profile on startup", cfg.Backend)
+	}
+
+	result, err := seed.Run(context.Background(), app.NewUsecase(cfg, store.Repo), seed.Options{
+		Profile: *profile,
+		Users:   *users,
+		Seed:    *seedValue,
+	})
+	if err != nil {
+		log.Fatalf("seed failed: %v", err)
+	}
+	log.Printf("Seeded profile %s: %d users created, %d already existed", *profile, result.Created, result.Skipped)
+}
@@ -0,0 +1,337 @@
+// Command paymentctl is the operator CLI for the payment service. It talks to
+// the database selected by the same flags, environment variables and
+// configuration file as cmd/api.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"payment-service/internal/app"
+	"payment-service/internal/config"
+	"payment-service/internal/payout"
+	"payment-service/internal/usecase"
+)
+
+const usage = `Usage: paymentctl [-o table|json] [configuration flags] <command>
+
+Commands:
+  wallet get <user-id>          Show a wallet
+  wallet freeze <user-id>       Stop a wallet from sending, receiving and top ups
+  wallet unfreeze <user-id>     Reactivate a frozen wallet
+  tx get <reference>            Show a transaction
+  tx list [flags]               List transactions, newest first
+                                  --user ID     sent or received by user
+                                  --type TYPE   transfer, topup, adjustment, refund or opening
+                                  --limit N     at most N transactions (default 50)
+  adjust [flags]                Credit or debit a wallet
+                                  --user ID         wallet owner (required)
+                                  --amount N        positive to credit, negative to debit (required)
+                                  --reference REF   idempotency reference (generated if empty)
+  refund <reference>            Refund a completed transfer to its sender
+  ledger check                  Check that money is conserved and compare every
+                                balance with its transactions, exits with
+                                status 1 on discrepancies
+  payout [flags] <file>         Pay the rows of a CSV or JSON payout file after
+                                validating all of them, exits with status 1
+                                listing the invalid rows
+                                  --sender ID       paying wallet owner (required)
+                                  --dry-run         only validate and summarize
+                                  --format FORMAT   csv or json (default by file name)
+                                  --results FILE    also write the results as CSV
+
+The database is selected like for the API server: by the configuration flags
+(--backend, --sqlite-path, --config, ...; see paymentctl -h), REPO_BACKEND
+(postgres or sqlite) and the DB_* and SQLITE_PATH variables, or CONFIG_FILE.
+`
+
+var (
+	// errUsage makes paymentctl print the usage and exit with status 2.
+	errUsage = errors.New("usage")
+
+	// errDiscrepancies makes paymentctl exit with status 1 without printing
+	// an error, the report has already been written.
+	errDiscrepancies = errors.New("ledger has discrepancies")
+
+	// errInvalidPayout makes paymentctl exit with status 1 after the invalid
+	// rows of a payout file have been listed.
+	errInvalidPayout = errors.New("payout file has invalid rows")
+)
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], config.Load, os.Stdout, os.Stderr))
+}
+
+// run executes the command in args. load builds the configuration from the
+// configuration flags in args; main passes config.Load.
+func run(ctx context.Context, args []string, load func(*config.Flags) (config.Config, error), stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("paymentctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "table", "output format: table or json")
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "paymentctl: unknown output format %q\n", *output)
+		return 2
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cfg, err := load(configFlags)
+	if err != nil {
+		fmt.Fprintf(stderr, "paymentctl: invalid configuration: %v\n", err)
+		return 1
+	}
+
+	store, err := app.Open(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "paymentctl: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+	if !store.Persistent() {
+		fmt.Fprintf(stderr, "paymentctl: REPO_BACKEND %q is not shared with the API server\n", cfg.Backend)
+		return 1
+	}
+
+	ctl := &ctl{
+		uc:  app.NewUsecase(cfg, store.Repo),
+		out: printer{w: stdout, json: *output == "json"},
+	}
+	err = cmd(ctl, ctx, args[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprint(stderr, usage)
+		return 2
+	case errors.Is(err, errDiscrepancies), errors.Is(err, errInvalidPayout):
+		return 1
+	case err != nil:
+		fmt.Fprintf(stderr, "paymentctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type ctl struct {
+	uc  *usecase.PaymentUsecase
+	out printer
+}
+
+var commands = map[string]func(c *ctl, ctx context.Context, args []string) error{
+	"wallet": (*ctl).wallet,
+	"tx":     (*ctl).tx,
+	"adjust": (*ctl).adjust,
+	"refund": (*ctl).refund,
+	"ledger": (*ctl).ledger,
+	"payout": (*ctl).payout,
+}
+
+func (c *ctl) wallet(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	var wallet *usecase.GetWalletResponse
+	var err error
+	switch args[0] {
+	case "get":
+		wallet, err = c.uc.GetWallet(ctx, args[1])
+	case "freeze":
+		wallet, err = c.uc.FreezeWallet(ctx, args[1])
+	case "unfreeze":
+		wallet, err = c.uc.UnfreezeWallet(ctx, args[1])
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.wallet(wallet)
+}
+
+func (c *ctl) tx(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "get":
+		if len(args) != 2 {
+			return errUsage
+		}
+		t, err := c.uc.GetTransactionDetails(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return c.out.transactions([]usecase.TransactionDetails{*t})
+	case "list":
+		flags := flag.NewFlagSet("tx list", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		var req usecase.ListTransactionsRequest
+		flags.StringVar(&req.UserID, "user", "", "")
+		flags.StringVar(&req.Type, "type", "", "")
+		flags.IntVar(&req.Limit, "limit", usecase.DefaultListLimit, "")
+		if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+			return errUsage
+		}
+		transactions, err := c.uc.ListTransactions(ctx, req)
+		if err != nil {
+			return err
+		}
+		return c.out.transactions(transactions)
+	default:
+		return errUsage
+	}
+}
+
+func (c *ctl) adjust(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("adjust", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var req usecase.AdjustmentRequest
+	var amount string
+	flags.StringVar(&req.UserID, "user", "", "")
+	flags.StringVar(&amount, "amount", "", "")
+	flags.StringVar(&req.Reference, "reference", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || req.UserID == "" || amount == "" {
+		return errUsage
+	}
+	var err error
+	req.Amount, err = strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", amount)
+	}
+
+	t, err := c.uc.AdjustBalance(ctx, req)
+	if err != nil {
+		return err
+	}
+	return c.out.transactions([]usecase.TransactionDetails{*t})
+}
+
+func (c *ctl) refund(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	t, err := c.uc.RefundTransfer(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.out.transactions([]usecase.TransactionDetails{*t})
+}
+
+func (c *ctl) ledger(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errUsage
+	}
+	report, err := c.uc.CheckLedger(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.out.ledger(report); err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		return errDiscrepancies
+	}
+	return nil
+}
+
+func (c *ctl) payout(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("payout", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var senderID, format, results string
+	flags.StringVar(&senderID, "sender", "", "")
+	dryRun := flags.Bool("dry-run", false, "")
+	flags.StringVar(&format, "format", "", "")
+	flags.StringVar(&results, "results", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || senderID == "" {
+		return errUsage
+	}
+	name := flags.Arg(0)
+	if format == "" {
+		format = payout.FormatOf(name)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, invalid, err := payout.Read(f, format)
+	if err != nil {
+		return err
+	}
+	req := usecase.PayoutRequest{SenderID: senderID, Rows: rows, Invalid: invalid}
+
+	if *dryRun {
+		summary, err := c.uc.PreviewPayout(ctx, req)
+		if err != nil {
+			return err
+		}
+		if err := c.out.payoutSummary(summary); err != nil {
+			return err
+		}
+		if len(summary.Errors) > 0 {
+			return errInvalidPayout
+		}
+		return nil
+	}
+
+	result, err := c.uc.ExecutePayout(ctx, req)
+	var invalidErr *usecase.InvalidPayoutError
+	if errors.As(err, &invalidErr) {
+		if err := c.out.payoutSummary(invalidErr.Summary); err != nil {
+			return err
+		}
+		return errInvalidPayout
+	}
+	if err != nil {
+		return err
+	}
+	if results != "" {
+		if err := writeResults(results, result.Results); err != nil {
+			return err
+		}
+	}
+	return c.out.payoutResult(result)
+}
+
+func writeResults(name string, results []usecase.PayoutRowResult) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := payout.WriteResultsCSV(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
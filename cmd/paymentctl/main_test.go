@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"payment-service/internal/app"
+	"payment-service/internal/config"
+	"payment-service/internal/usecase"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConfig returns the config of a migrated SQLite database holding
+// alice (1000) and bob (0) and a transfer "ref-1" of 300 from alice to bob.
+func newTestConfig(t *testing.T) (config.Config, string, string) {
+	ctx := t.Context()
+	cfg := config.Config{Backend: "sqlite", SQLitePath: filepath.Join(t.TempDir(), "payment.db")}
+	store, err := app.Open(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	m, err := store.Migrator()
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	uc := app.NewUsecase(cfg, store.Repo)
+	alice, err := uc.CreateWallet(ctx, usecase.CreateWalletRequest{Username: "alice", Balance: 1000})
+	require.NoError(t, err)
+	bob, err := uc.CreateWallet(ctx, usecase.CreateWalletRequest{Username: "bob"})
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, usecase.TransferRequest{SenderID: alice.UserID, ReceiverID: bob.UserID, Amount: 300, Reference: "ref-1"})
+	require.NoError(t, err)
+	return cfg, alice.UserID, bob.UserID
+}
+
+func runCtl(t *testing.T, cfg config.Config, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	load := func(*config.Flags) (config.Config, error) { return cfg, nil }
+	code := run(context.Background(), args, load, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_ConfigurationFlags(t *testing.T) {
+	cfg, _, bob := newTestConfig(t)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("REPO_BACKEND", "memory")
+	t.Setenv("SQLITE_PATH", "")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"--backend", "sqlite", "--sqlite-path", cfg.SQLitePath, "wallet", "get", bob}
+	code := run(context.Background(), args, config.Load, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	require.Contains(t, stdout.String(), bob, "the flags select the database over the environment")
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"--backend", "mysql", "wallet", "get", bob}, config.Load, &stdout, &stderr)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr.String(), "invalid configuration")
+}
+
+func TestRun(t *testing.T) {
+	cfg, alice, bob := newTestConfig(t)
+
+	code, out, _ := runCtl(t, cfg, "wallet", "freeze", bob)
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "frozen")
+
+	code, out, _ = runCtl(t, cfg, "-o", "json", "wallet", "get", bob)
+	require.Equal(t, 0, code)
+	var wallet usecase.GetWalletResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &wallet))
+	require.Equal(t, "frozen", wallet.Status)
+	require.Equal(t, int64(300), wallet.Balance)
+
+	// Refunds are operator corrections and go through on frozen wallets.
+	code, _, stderr := runCtl(t, cfg, "refund", "ref-1")
+	require.Equal(t, 0, code, stderr)
+	code, _, stderr = runCtl(t, cfg, "refund", "ref-1")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "already been refunded")
+
+	code, _, stderr = runCtl(t, cfg, "adjust", "--user", alice, "--amount", "-25", "--reference", "adj-1")
+	require.Equal(t, 0, code, stderr)
+
+	code, out, _ = runCtl(t, cfg, "-o", "json", "tx", "list", "--user", alice)
+	require.Equal(t, 0, code)
+	var transactions []usecase.TransactionDetails
+	require.NoError(t, json.Unmarshal([]byte(out), &transactions))
+	var types []string
+	for _, tx := range transactions {
+		types = append(types, tx.Type)
+	}
+	require.ElementsMatch(t, []string{"opening", "transfer", "refund", "adjustment"}, types)
+
+	code, out, _ = runCtl(t, cfg, "ledger", "check")
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "Checked 2 wallets holding 975 in total (expected 975), 0 discrepancies")
+}
+
+func TestRun_LedgerDiscrepancy(t *testing.T) {
+	cfg, _, bob := newTestConfig(t)
+	store, err := app.Open(cfg)
+	require.NoError(t, err)
+	_, err = store.DB.Exec(`UPDATE wallets SET balance = balance + 5 WHERE user_id = $1`, bob)
+	require.NoError(t, err)
+	store.Close()
+
+	code, out, _ := runCtl(t, cfg, "ledger", "check")
+	require.Equal(t, 1, code)
+	require.Contains(t, out, "1 discrepancies")
+	require.Contains(t, out, bob)
+}
+
+func TestRun_Usage(t *testing.T) {
+	cfg, _, _ := newTestConfig(t)
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"wallet", "get"},
+		{"adjust", "--user", "x"},
+		{"-o", "yaml", "ledger", "check"},
+	} {
+		code, _, stderr := runCtl(t, cfg, args...)
+		require.Equal(t, 2, code, strings.Join(args, " "))
+		require.NotEmpty(t, stderr)
+	}
+}
+
+func TestRun_Payout(t *testing.T) {
+	cfg, alice, bob := newTestConfig(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "payroll.csv")
+	require.NoError(t, os.WriteFile(file, []byte("receiver,amount,reference,note\n"+
+		bob+",100,payroll-1,October\n"+
+		bob+",50,ref-1\n"+
+		"nobody,x,payroll-3\n"), 0o600))
+
+	code, out, _ := runCtl(t, cfg, "payout", "--sender", alice, "--dry-run", file)
+	require.Equal(t, 1, code)
+	require.Contains(t, out, "Payout of 3 rows to 1 receivers")
+	require.Contains(t, out, "reference ref-1 has already been used")
+	require.Contains(t, out, `amount "x" is not an integer`)
+
+	require.NoError(t, os.WriteFile(file, []byte(bob+",100,payroll-1,October\n"+bob+",50,payroll-2\n"), 0o600))
+	code, out, _ = runCtl(t, cfg, "payout", "--sender", alice, "--dry-run", file)
+	require.Equal(t, 0, code)
+	require.Contains(t, out, "150 in total (balance 700), 0 errors")
+
+	results := filepath.Join(dir, "results.csv")
+	code, out, stderr := runCtl(t, cfg, "payout", "--sender", alice, "--results", results, file)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, out, "Paid 2 of 2 rows")
+	written, err := os.ReadFile(results)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(written), "line,receiver,amount,reference,note,status,transaction_id,error\n1,"+bob+",100,payroll-1,October,completed,"))
+
+	code, _, _ = runCtl(t, cfg, "payout", file)
+	require.Equal(t, 2, code, "--sender is required")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"payment-service/internal/usecase"
+)
+
+// printer writes command results as aligned tables or as indented JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p printer) table(write func(w io.Writer)) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	write(tw)
+	return tw.Flush()
+}
+
+func (p printer) wallet(wallet *usecase.GetWalletResponse) error {
+	if p.json {
+		return p.encode(wallet)
+	}
+	return p.table(func(w io.Writer) {
+		fmt.Fprintln(w, "USER ID\tWALLET ID\tBALANCE\tSTATUS\tVERSION\tUPDATED AT")
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\n", wallet.UserID, wallet.WalletID, wallet.Balance,
+			wallet.Status, wallet.Version, formatTime(wallet.UpdatedAt))
+	})
+}
+
+func (p printer) transactions(transactions []usecase.TransactionDetails) error {
+	if p.json {
+		return p.encode(transactions)
+	}
+	return p.table(func(w io.Writer) {
+		fmt.Fprintln(w, "REFERENCE\tTYPE\tFROM\tTO\tAMOUNT\tSTATUS\tCREATED AT")
+		for _, t := range transactions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", t.Reference, t.Type, orDash(t.SenderID),
+				orDash(t.ReceiverID), t.Amount, t.Status, formatTime(t.CreatedAt))
+		}
+	})
+}
+
+func (p printer) ledger(report *usecase.LedgerReport) error {
+	if p.json {
+		return p.encode(report)
+	}
+	return p.table(func(w io.Writer) {
+		fmt.Fprintf(w, "Checked %d wallets holding %d in total (expected %d), %d discrepancies\n",
+			report.Wallets, report.TotalBalance, report.ExpectedTotal, len(report.Discrepancies))
+		if len(report.Discrepancies) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "USER ID\tWALLET ID\tBALANCE\tEXPECTED\tDIFFERENCE")
+		for _, d := range report.Discrepancies {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%+d\n", d.UserID, d.WalletID, d.Balance, d.Expected, d.Balance-d.Expected)
+		}
+	})
+}
+
+func (p printer) payoutSummary(summary *usecase.PayoutSummary) error {
+	if p.json {
+		return p.encode(summary)
+	}
+	return p.table(func(w io.Writer) {
+		fmt.Fprintf(w, "Payout of %d rows to %d receivers from %s, %d in total (balance %d), %d errors, %d already paid\n",
+			summary.Rows, summary.Receivers, summary.SenderID, summary.TotalAmount, summary.SenderBalance,
+			len(summary.Errors), summary.AlreadyPaid)
+		if len(summary.Errors) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "LINE\tCODE\tMESSAGE")
+		for _, e := range summary.Errors {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", orDash(lineString(e.Line)), e.Code, e.Message)
+		}
+	})
+}
+
+func (p printer) payoutResult(result *usecase.PayoutResult) error {
+	if p.json {
+		return p.encode(result)
+	}
+	return p.table(func(w io.Writer) {
+		fmt.Fprintf(w, "Paid %d of %d rows from %s, %d already paid, %d failed\n", result.Completed, result.Rows,
+			result.SenderID, result.AlreadyPaid, result.Failed)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "LINE\tRECEIVER\tAMOUNT\tREFERENCE\tSTATUS\tTRANSACTION ID\tERROR")
+		for _, r := range result.Results {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", r.Line, r.ReceiverID, r.Amount, r.Reference,
+				r.Status, orDash(r.TransactionID), orDash(r.Error))
+		}
+	})
+}
+
+// lineString formats the line of a payout error, which is empty for errors
+// of the file as a whole.
+func lineString(line int) string {
+	if line == 0 {
+		return ""
+	}
+	return strconv.Itoa(line)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
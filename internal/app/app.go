@@ -0,0 +1,123 @@
+// Package app opens the repository selected by a config.Config and builds the
+// usecase on top of it, so that every binary is wired the same way.
+package app
+
+import (
+	"database/sql"
+	"fmt"
+
+	"payment-service/internal/config"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"payment-service/migrations"
+)
+
+// Store is an open repository. DB is nil for the memory backend.
+type Store struct {
+	Repo      domain.TransactionRepository
+	Reports   domain.ReconciliationRepository
+	Batches   domain.BatchRepository
+	Schedules domain.ScheduleRepository
+	Requests  domain.PaymentRequestRepository
+	Splits    domain.SplitRepository
+	DB        *sql.DB
+	Dialect   migrate.Dialect
+}
+
+// Open connects to the backend selected by cfg.Backend. The memory backend
+// starts out empty.
+func Open(cfg config.Config) (*Store, error) {
+	switch cfg.Backend {
+	case "postgres":
+		db, err := repository.OpenPostgres(cfg.Postgres.DSN())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		configurePool(db, cfg.Pool)
+		return &Store{
+			Repo:      repository.NewPostgresRepo(db),
+			Reports:   repository.NewReconciliationRepo(db),
+			Batches:   repository.NewBatchRepo(db),
+			Schedules: repository.NewScheduleRepo(db),
+			Requests:  repository.NewPaymentRequestRepo(db),
+			Splits:    repository.NewSplitRepo(db),
+			DB:        db,
+			Dialect:   migrate.Postgres,
+		}, nil
+	case "sqlite":
+		db, err := repository.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		configurePool(db, cfg.Pool)
+		return &Store{
+			Repo:      repository.NewSQLiteRepo(db),
+			Reports:   repository.NewReconciliationRepo(db),
+			Batches:   repository.NewBatchRepo(db),
+			Schedules: repository.NewScheduleRepo(db),
+			Requests:  repository.NewPaymentRequestRepo(db),
+			Splits:    repository.NewSplitRepo(db),
+			DB:        db,
+			Dialect:   migrate.SQLite,
+		}, nil
+	case "memory":
+		return &Store{
+			Repo:      repository.NewMemoryRepo(),
+			Reports:   repository.NewMemoryReconciliationRepo(),
+			Batches:   repository.NewMemoryBatchRepo(),
+			Schedules: repository.NewMemoryScheduleRepo(),
+			Requests:  repository.NewMemoryPaymentRequestRepo(),
+			Splits:    repository.NewMemorySplitRepo(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+}
+
+// configurePool applies the pool settings that are set, leaving the others at
+// the database/sql defaults.
+func configurePool(db *sql.DB, pool config.Pool) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
+
+// Persistent reports whether the store outlives the process.
+func (s *Store) Persistent() bool {
+	return s.DB != nil
+}
+
+// Migrator returns a migrator for the embedded migrations.
+func (s *Store) Migrator() (*migrate.Migrator, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("the memory backend has no migrations")
+	}
+	return migrate.New(s.DB, s.Dialect, migrations.FS)
+}
+
+func (s *Store) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
+
+// NewUsecase builds the PaymentUsecase for repo with the options selected by
+// cfg followed by opts.
+func NewUsecase(cfg config.Config, repo domain.TransactionRepository, opts ...usecase.Option) *usecase.PaymentUsecase {
+	if cfg.OptimisticLocking {
+		opts = append(opts, usecase.WithOptimisticLocking())
+	}
+	return usecase.NewPaymentUsecase(repo, opts...)
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCServer is a Worker serving Server on Listener. The listener is opened
+// by the caller so that a port already in use fails startup instead of the
+// worker.
+//
+// Lifecycle cancels workers after the HTTP server has drained, so in-flight
+// RPCs are drained second, within what is left of the shutdown timeout. RPCs
+// and streams still open when it runs out are cancelled by Stop.
+type GRPCServer struct {
+	Server   *grpc.Server
+	Listener net.Listener
+
+	mu       sync.Mutex
+	serveErr error
+}
+
+func (s *GRPCServer) Run(ctx context.Context) {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Server.Serve(s.Listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		s.Server.GracefulStop()
+		<-serveErr
+	case err := <-serveErr:
+		slog.Error("gRPC server stopped", "error", err)
+		s.mu.Lock()
+		s.serveErr = err
+		s.mu.Unlock()
+	}
+}
+
+// Stop closes all connections and cancels the RPCs in flight, also ending a
+// graceful stop in progress.
+func (s *GRPCServer) Stop() {
+	s.Server.Stop()
+}
+
+// Health returns an error once the server stopped serving on its own, so that
+// readiness fails instead of the gRPC port silently going away.
+func (s *GRPCServer) Health(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.serveErr != nil {
+		return fmt.Errorf("gRPC server stopped: %w", s.serveErr)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestGRPCServer_HealthReportsServeFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &GRPCServer{Server: grpc.NewServer(), Listener: ln}
+
+	done := make(chan struct{})
+	go func() {
+		srv.Run(context.Background())
+		close(done)
+	}()
+	require.NoError(t, srv.Health(t.Context()))
+
+	// Closing the listener makes Serve fail as an accept error would.
+	require.NoError(t, ln.Close())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Serve failed")
+	}
+	require.ErrorContains(t, srv.Health(t.Context()), "gRPC server stopped")
+}
+
+func TestGRPCServer_HealthyAfterShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &GRPCServer{Server: grpc.NewServer(), Listener: ln}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+	require.NoError(t, srv.Health(t.Context()), "a graceful stop is not a failure")
+}
+
+func TestGRPCServer_StopsOpenStreamsAfterShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// The stream stays open until the server cancels it.
+	opened := make(chan struct{})
+	streamDone := make(chan struct{})
+	desc := grpc.StreamDesc{
+		StreamName:    "Watch",
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			defer close(streamDone)
+			close(opened)
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		},
+	}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{ServiceName: "test.Watcher", HandlerType: (*any)(nil), Streams: []grpc.StreamDesc{desc}}, struct{}{})
+	srv := &GRPCServer{Server: server, Listener: ln}
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	httpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	lifecycle := &Lifecycle{
+		Server:          &http.Server{Handler: http.NotFoundHandler()},
+		Listen:          func(s *http.Server) error { return s.Serve(httpLn) },
+		Workers:         []Worker{srv},
+		ShutdownTimeout: 100 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- lifecycle.Run(ctx) }()
+
+	_, err = conn.NewStream(t.Context(), &desc, "/test.Watcher/Watch")
+	require.NoError(t, err)
+	select {
+	case <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not opened")
+	}
+
+	cancel()
+	select {
+	case err := <-runErr:
+		require.ErrorContains(t, err, "did not stop in time")
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown waited for the open stream")
+	}
+	select {
+	case <-streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the open stream was not cancelled")
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"payment-service/internal/delivery"
+)
+
+// ReadinessChecks returns the checks of the store for /readyz: the database
+// answers a ping and its schema is at least at the latest migration embedded
+// in the binary. A newer schema passes so that instances of the previous
+// release stay ready while a rolling deploy migrates the database. The memory
+// backend has no checks.
+func (s *Store) ReadinessChecks() ([]delivery.HealthCheck, error) {
+	if s.DB == nil {
+		return nil, nil
+	}
+	m, err := s.Migrator()
+	if err != nil {
+		return nil, err
+	}
+
+	return []delivery.HealthCheck{
+		{Name: "database", Check: s.DB.PingContext},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			version, err := m.Version(ctx)
+			if err != nil {
+				return err
+			}
+			if version < m.Latest() {
+				return fmt.Errorf("schema is at version %d, expected %d", version, m.Latest())
+			}
+			return nil
+		}},
+	}, nil
+}
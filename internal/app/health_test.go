@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"payment-service/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessChecks(t *testing.T) {
+	cfg := config.Default()
+	cfg.Backend = "sqlite"
+	cfg.SQLitePath = filepath.Join(t.TempDir(), "payment.db")
+	store, err := Open(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	checks, err := store.ReadinessChecks()
+	require.NoError(t, err)
+	results := func() map[string]error {
+		errs := make(map[string]error)
+		for _, c := range checks {
+			errs[c.Name] = c.Check(context.Background())
+		}
+		return errs
+	}
+
+	errs := results()
+	require.NoError(t, errs["database"])
+	require.ErrorContains(t, errs["migrations"], "schema is at version 0")
+
+	m, err := store.Migrator()
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	errs = results()
+	require.NoError(t, errs["database"])
+	require.NoError(t, errs["migrations"])
+
+	memory, err := Open(config.Config{Backend: "memory"})
+	require.NoError(t, err)
+	checks, err = memory.ReadinessChecks()
+	require.NoError(t, err)
+	require.Empty(t, checks)
+}
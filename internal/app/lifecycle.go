@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Worker is a background job that runs until its context is cancelled. Run
+// should finish the unit of work in progress before returning.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// Stopper is a Worker that can be stopped abruptly. Lifecycle calls Stop once
+// the shutdown timeout has run out, so that a worker whose draining does not
+// end, such as a server holding an open stream, does not keep running during
+// the rest of the shutdown.
+type Stopper interface {
+	Worker
+	Stop()
+}
+
+// Lifecycle runs the HTTP server and the background workers of cmd/api and
+// shuts them down in order once the context passed to Run is cancelled:
+//
+//  1. the server stops accepting connections and in-flight requests are
+//     drained,
+//  2. the workers are cancelled and awaited.
+//
+// Both steps share ShutdownTimeout; Stoppers still running when it runs out
+// are stopped. Closing the store is left to the caller, after Run has
+// returned.
+type Lifecycle struct {
+	Server *http.Server
+	// Listen starts Server and defaults to ListenAndServe.
+	Listen          func(*http.Server) error
+	Workers         []Worker
+	ShutdownTimeout time.Duration
+}
+
+// Run blocks until ctx is cancelled or the server fails, then shuts down. It
+// returns the error that made the server stop, if any, or the error of an
+// unclean shutdown.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	for _, w := range l.Workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			w.Run(workerCtx)
+		}()
+	}
+
+	listen := l.Listen
+	if listen == nil {
+		listen = (*http.Server).ListenAndServe
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- listen(l.Server)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down, draining in-flight requests")
+	case err = <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		slog.Info("server stopped", "error", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), l.ShutdownTimeout)
+	defer cancel()
+
+	if shutdownErr := l.Server.Shutdown(shutdownCtx); shutdownErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to drain requests: %w", shutdownErr))
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		for _, w := range l.Workers {
+			if s, ok := w.(Stopper); ok {
+				s.Stop()
+			}
+		}
+		err = errors.Join(err, errors.New("background workers did not stop in time"))
+	}
+	return err
+}
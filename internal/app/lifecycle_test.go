@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type workerFunc func(ctx context.Context)
+
+func (f workerFunc) Run(ctx context.Context) { f(ctx) }
+
+func TestLifecycle_DrainsRequestsBeforeStoppingWorkers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var workerStopped atomic.Bool
+	var stoppedBeforeResponse atomic.Bool
+
+	lifecycle := &Lifecycle{
+		Server: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			stoppedBeforeResponse.Store(workerStopped.Load())
+			w.Write([]byte("done"))
+		})},
+		Listen: func(srv *http.Server) error { return srv.Serve(ln) },
+		Workers: []Worker{workerFunc(func(ctx context.Context) {
+			<-ctx.Done()
+			workerStopped.Store(true)
+		})},
+		ShutdownTimeout: 5 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- lifecycle.Run(ctx) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	// Give Shutdown time to close the listener while the request is still
+	// in flight.
+	time.Sleep(50 * time.Millisecond)
+	_, err = net.Dial("tcp", ln.Addr().String())
+	require.Error(t, err, "listener should be closed")
+
+	close(release)
+	require.Equal(t, "done", <-body)
+	require.NoError(t, <-runErr)
+	require.False(t, stoppedBeforeResponse.Load(), "workers stopped before requests were drained")
+	require.True(t, workerStopped.Load())
+}
+
+func TestLifecycle_ShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	lifecycle := &Lifecycle{
+		Server: &http.Server{Handler: http.NotFoundHandler()},
+		Listen: func(srv *http.Server) error { return srv.Serve(ln) },
+		Workers: []Worker{workerFunc(func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+		})},
+		ShutdownTimeout: 20 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorContains(t, lifecycle.Run(ctx), "did not stop in time")
+}
@@ -0,0 +1,506 @@
+// Package config loads the settings shared by the payment-service binaries
+// (cmd/api and cmd/paymentctl).
+//
+// Settings are layered, each layer overriding the previous one: the defaults,
+// an optional YAML file (see the config.example.yaml in the repository root),
+// environment variables and finally command line flags. Secrets can be read
+// from files with the *_FILE variables or the *_file YAML keys, so they do not
+// have to appear in the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Backend selects the repository: postgres, sqlite or memory.
+	Backend string `yaml:"backend"`
+
+	Server     Server   `yaml:"server"`
+	Postgres   Postgres `yaml:"postgres"`
+	SQLitePath string   `yaml:"sqlite_path"`
+	// Pool applies to the postgres and sqlite backends.
+	Pool Pool `yaml:"pool"`
+
+	OptimisticLocking bool `yaml:"optimistic_locking"`
+	MigrateOnStartup  bool `yaml:"migrate_on_startup"`
+
+	// ReconcileInterval is how often cmd/api runs the reconciliation job.
+	// Zero disables the scheduled runs.
+	ReconcileInterval time.Duration `yaml:"reconcile_interval"`
+	// AdminToken guards the /admin endpoints, which are not mounted when it
+	// is empty.
+	AdminToken     string `yaml:"admin_token"`
+	AdminTokenFile string `yaml:"admin_token_file"`
+
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	API       API       `yaml:"api"`
+	Batch     Batch     `yaml:"batch"`
+	Schedule  Schedule  `yaml:"schedule"`
+}
+
+// Server configures the HTTP and gRPC listeners. TLS is enabled on both when
+// TLSCertFile and TLSKeyFile are set.
+type Server struct {
+	Addr string `yaml:"addr"`
+	// GRPCAddr is where the gRPC PaymentService listens. Empty disables it.
+	GRPCAddr    string `yaml:"grpc_addr"`
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// ShutdownTimeout bounds draining in-flight requests and stopping the
+	// background workers on SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Postgres holds the connection settings of the postgres backend. The SSL*
+// fields map to the lib/pq parameters of the same name.
+type Postgres struct {
+	Host         string `yaml:"host"`
+	Port         string `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	Name         string `yaml:"name"`
+
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
+}
+
+// Pool configures the database/sql connection pool. Zero values keep the
+// database/sql defaults.
+type Pool struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// Log configures the structured logs of cmd/api, see internal/logging.
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json or text.
+	Format string `yaml:"format"`
+}
+
+// Tracing configures OpenTelemetry tracing, see internal/tracing.
+type Tracing struct {
+	// Exporter is none, stdout or otlp.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the URL of the OTLP/HTTP collector, e.g.
+	// http://localhost:4318. An http URL disables TLS.
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+	// SampleRatio is the fraction of new traces that are recorded. Requests
+	// carrying a traceparent follow the caller's sampling decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// RateLimit configures the per-route token buckets of cmd/api, see
+// internal/ratelimit.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry
+	// instead of the connection. Only enable it behind a proxy that sets the
+	// header.
+	TrustProxy bool          `yaml:"trust_proxy"`
+	Routes     []RoutePolicy `yaml:"routes"`
+}
+
+// RoutePolicy lists the limits applied to one route, given as "METHOD
+// /pattern" like the chi routes without the version prefix, e.g. "POST
+// /transfer" for /v1/transfer and the legacy /transfer. A request must pass
+// every limit.
+type RoutePolicy struct {
+	Route string `yaml:"route"`
+	// UserField is the JSON field of the request body holding the user
+	// that limits with key "user" apply to, e.g. sender_id.
+	UserField string          `yaml:"user_field"`
+	Limits    []RateLimitRule `yaml:"limits"`
+}
+
+// RateLimitRule allows Requests per Period for each value of Key (ip, user or
+// api_key), with bursts of up to Burst requests. Burst defaults to Requests.
+type RateLimitRule struct {
+	Key      string        `yaml:"key"`
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+}
+
+// API configures the versions of the HTTP API. The routes are served under
+// /v1; LegacyRoutes also serves them at their unversioned paths, announcing
+// DeprecatedSince and Sunset in the Deprecation and Sunset headers.
+type API struct {
+	LegacyRoutes    bool      `yaml:"legacy_routes"`
+	DeprecatedSince time.Time `yaml:"deprecated_since"`
+	// Sunset is when the legacy routes are removed. Zero omits the header.
+	Sunset time.Time `yaml:"sunset"`
+}
+
+// Batch configures the transfer batches of cmd/api.
+type Batch struct {
+	// DefaultMode applies to batches submitted without a mode:
+	// all_or_nothing or best_effort.
+	DefaultMode string `yaml:"default_mode"`
+	MaxItems    int    `yaml:"max_items"`
+	// PollInterval is how often pending batches are picked up. Zero disables
+	// processing on this instance, leaving it to the other replicas.
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Schedule configures the scheduled transfers of cmd/api.
+type Schedule struct {
+	// PollInterval is how often due schedules are run. Zero disables running
+	// them on this instance, leaving it to the other replicas.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// RetryInterval and MaxRetries apply to occurrences the sender cannot
+	// cover, of schedules that retry them.
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	MaxRetries    int           `yaml:"max_retries"`
+}
+
+var (
+	sslModes         = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logLevels        = []string{"debug", "info", "warn", "error"}
+	logFormats       = []string{"json", "text"}
+	tracingExporters = []string{"none", "stdout", "otlp"}
+	rateLimitKeys    = []string{"ip", "user", "api_key"}
+	batchModes       = []string{"all_or_nothing", "best_effort"}
+)
+
+// maxBatchItems bounds batch max_items. An all-or-nothing batch holds the
+// locks of all its wallets until it commits.
+const maxBatchItems = 10000
+
+// Default returns the configuration used when nothing is set, matching
+// docker-compose except for the database password, which has no default.
+func Default() Config {
+	return Config{
+		Backend: "postgres",
+		Server:  Server{Addr: ":8080", GRPCAddr: ":9090", ShutdownTimeout: 30 * time.Second},
+		Postgres: Postgres{
+			Host:    "localhost",
+			Port:    "5432",
+			User:    "user_payment",
+			Name:    "db_payment",
+			SSLMode: "disable",
+		},
+		SQLitePath:        "payment.db",
+		ReconcileInterval: time.Hour,
+		Log:               Log{Level: "info", Format: "json"},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "payment-service",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Routes: []RoutePolicy{
+				{Route: "POST /transfer", UserField: "sender_id", Limits: defaultMoneyLimits()},
+				{Route: "POST /topup", UserField: "user_id", Limits: defaultMoneyLimits()},
+				{Route: "POST /transfers/batch", UserField: "sender_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 30, Period: time.Minute, Burst: 5},
+					{Key: "user", Requests: 10, Period: time.Minute, Burst: 5},
+					{Key: "api_key", Requests: 120, Period: time.Minute, Burst: 10},
+				}},
+				{Route: "POST /schedules", UserField: "sender_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 30, Period: time.Minute, Burst: 5},
+					{Key: "user", Requests: 10, Period: time.Minute, Burst: 5},
+					{Key: "api_key", Requests: 120, Period: time.Minute, Burst: 10},
+				}},
+				{Route: "POST /payment-requests", UserField: "requester_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 60, Period: time.Minute, Burst: 10},
+					{Key: "user", Requests: 10, Period: time.Minute, Burst: 5},
+					{Key: "api_key", Requests: 300, Period: time.Minute, Burst: 20},
+				}},
+				// Accepting a payment request makes a transfer.
+				{Route: "POST /payment-requests/{id}/accept", UserField: "payer_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 120, Period: time.Minute, Burst: 20},
+					{Key: "user", Requests: 30, Period: time.Minute, Burst: 10},
+					{Key: "api_key", Requests: 600, Period: time.Minute, Burst: 50},
+				}},
+				{Route: "POST /splits", UserField: "organizer_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 60, Period: time.Minute, Burst: 10},
+					{Key: "user", Requests: 10, Period: time.Minute, Burst: 5},
+					{Key: "api_key", Requests: 300, Period: time.Minute, Burst: 20},
+				}},
+				// Paying a share makes a transfer.
+				{Route: "POST /splits/{id}/pay", UserField: "participant_id", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 120, Period: time.Minute, Burst: 20},
+					{Key: "user", Requests: 30, Period: time.Minute, Burst: 10},
+					{Key: "api_key", Requests: 600, Period: time.Minute, Burst: 50},
+				}},
+				// Payout files are not JSON, so they are only limited by
+				// client.
+				{Route: "POST /payouts", Limits: []RateLimitRule{
+					{Key: "ip", Requests: 10, Period: time.Minute, Burst: 5},
+					{Key: "api_key", Requests: 60, Period: time.Minute, Burst: 5},
+				}},
+			},
+		},
+		API: API{
+			LegacyRoutes:    true,
+			DeprecatedSince: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+			Sunset:          time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC),
+		},
+		Batch:    Batch{DefaultMode: "all_or_nothing", MaxItems: 1000, PollInterval: time.Second},
+		Schedule: Schedule{PollInterval: 10 * time.Second, RetryInterval: time.Hour, MaxRetries: 3},
+	}
+}
+
+// defaultMoneyLimits are the default limits of the routes that move money.
+func defaultMoneyLimits() []RateLimitRule {
+	return []RateLimitRule{
+		{Key: "ip", Requests: 120, Period: time.Minute, Burst: 20},
+		{Key: "user", Requests: 30, Period: time.Minute, Burst: 10},
+		{Key: "api_key", Requests: 600, Period: time.Minute, Burst: 50},
+	}
+}
+
+// DSN returns the lib/pq connection URL.
+func (p Postgres) DSN() string {
+	params := url.Values{}
+	params.Set("sslmode", p.SSLMode)
+	for key, value := range map[string]string{
+		"sslrootcert": p.SSLRootCert,
+		"sslcert":     p.SSLCert,
+		"sslkey":      p.SSLKey,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	user := url.User(p.User)
+	if p.Password != "" {
+		user = url.UserPassword(p.User, p.Password)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     user,
+		Host:     net.JoinHostPort(p.Host, p.Port),
+		Path:     "/" + p.Name,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// TLS reports whether the server should serve HTTPS.
+func (s Server) TLS() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// Load builds the configuration from the defaults, the YAML file named by the
+// -config flag or CONFIG_FILE, the environment and the flags registered with
+// flags, which may be nil. The result is validated.
+func Load(flags *Flags) (Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if flags != nil && flags.file != "" {
+		path = flags.file
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.apply(&cfg, value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+	}
+
+	if flags != nil {
+		for _, set := range flags.set {
+			if err := set.apply(&cfg, set.value); err != nil {
+				return Config{}, fmt.Errorf("invalid -%s: %w", set.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile merges the YAML file at path into cfg. Unknown keys are rejected
+// so that typos do not go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return resolveSecretFiles(cfg)
+}
+
+// resolveSecretFiles replaces secrets given as files in the YAML file with
+// the files' contents.
+func resolveSecretFiles(cfg *Config) error {
+	for _, secret := range []struct{ file, value *string }{
+		{&cfg.Postgres.PasswordFile, &cfg.Postgres.Password},
+		{&cfg.AdminTokenFile, &cfg.AdminToken},
+	} {
+		if *secret.file == "" {
+			continue
+		}
+		value, err := readSecret(*secret.file)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+		*secret.file = ""
+	}
+	return nil
+}
+
+// readSecret returns the contents of path without the trailing newline most
+// secret stores add.
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Backend {
+	case "postgres":
+		p := c.Postgres
+		if p.Host == "" || p.Port == "" || p.User == "" || p.Name == "" {
+			errs = append(errs, errors.New("postgres host, port, user and name are required"))
+		}
+		if !slices.Contains(sslModes, p.SSLMode) {
+			errs = append(errs, fmt.Errorf("postgres sslmode must be one of %s, got %q", strings.Join(sslModes, ", "), p.SSLMode))
+		}
+		if (p.SSLCert == "") != (p.SSLKey == "") {
+			errs = append(errs, errors.New("postgres sslcert and sslkey must be set together"))
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			errs = append(errs, errors.New("sqlite_path is required"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("backend must be postgres, sqlite or memory, got %q", c.Backend))
+	}
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server addr is required"))
+	}
+	if c.Server.GRPCAddr != "" && c.Server.GRPCAddr == c.Server.Addr {
+		errs = append(errs, errors.New("server grpc_addr must differ from addr"))
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server tls_cert_file and tls_key_file must be set together"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server shutdown_timeout must be positive"))
+	}
+
+	if c.Pool.MaxOpenConns < 0 || c.Pool.MaxIdleConns < 0 || c.Pool.ConnMaxLifetime < 0 || c.Pool.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("pool settings must not be negative"))
+	}
+	if c.Pool.MaxOpenConns > 0 && c.Pool.MaxIdleConns > c.Pool.MaxOpenConns {
+		errs = append(errs, errors.New("pool max_idle_conns must not exceed max_open_conns"))
+	}
+	if c.ReconcileInterval < 0 {
+		errs = append(errs, errors.New("reconcile_interval must not be negative"))
+	}
+
+	if !slices.Contains(logLevels, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("log level must be one of %s, got %q", strings.Join(logLevels, ", "), c.Log.Level))
+	}
+	if !slices.Contains(logFormats, c.Log.Format) {
+		errs = append(errs, fmt.Errorf("log format must be one of %s, got %q", strings.Join(logFormats, ", "), c.Log.Format))
+	}
+
+	t := c.Tracing
+	if !slices.Contains(tracingExporters, t.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing exporter must be one of %s, got %q", strings.Join(tracingExporters, ", "), t.Exporter))
+	}
+	if t.Exporter == "otlp" {
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing endpoint must be an http or https URL, got %q", t.Endpoint))
+		}
+	}
+	if t.ServiceName == "" {
+		errs = append(errs, errors.New("tracing service_name is required"))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing sample_ratio must be between 0 and 1"))
+	}
+
+	for _, p := range c.RateLimit.Routes {
+		if method, path, ok := strings.Cut(p.Route, " "); !ok || method == "" || !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("rate_limit route must look like \"POST /transfer\", got %q", p.Route))
+		}
+		for _, l := range p.Limits {
+			if !slices.Contains(rateLimitKeys, l.Key) {
+				errs = append(errs, fmt.Errorf("rate_limit key of %s must be one of %s, got %q", p.Route, strings.Join(rateLimitKeys, ", "), l.Key))
+			}
+			if l.Key == "user" && p.UserField == "" {
+				errs = append(errs, fmt.Errorf("rate_limit route %s needs a user_field for its user limit", p.Route))
+			}
+			if l.Requests <= 0 || l.Period <= 0 || l.Burst < 0 {
+				errs = append(errs, fmt.Errorf("rate_limit limits of %s need positive requests and period", p.Route))
+			}
+		}
+	}
+
+	if !slices.Contains(batchModes, c.Batch.DefaultMode) {
+		errs = append(errs, fmt.Errorf("batch default_mode must be one of %s, got %q", strings.Join(batchModes, ", "), c.Batch.DefaultMode))
+	}
+	if c.Batch.MaxItems <= 0 || c.Batch.MaxItems > maxBatchItems {
+		errs = append(errs, fmt.Errorf("batch max_items must be between 1 and %d", maxBatchItems))
+	}
+	if c.Batch.PollInterval < 0 {
+		errs = append(errs, errors.New("batch poll_interval must not be negative"))
+	}
+	if c.Schedule.PollInterval < 0 {
+		errs = append(errs, errors.New("schedule poll_interval must not be negative"))
+	}
+	if c.Schedule.RetryInterval <= 0 {
+		errs = append(errs, errors.New("schedule retry_interval must be positive"))
+	}
+	if c.Schedule.MaxRetries < 0 {
+		errs = append(errs, errors.New("schedule max_retries must not be negative"))
+	}
+
+	if c.API.LegacyRoutes && !c.API.Sunset.IsZero() && !c.API.Sunset.After(c.API.DeprecatedSince) {
+		errs = append(errs, errors.New("api sunset must be after deprecated_since"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// setting is a configuration value that can be set from the environment and,
+// when flag is not empty, from the command line.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+// settings lists every environment variable and flag in the order they are
+// applied. A *_FILE variable comes after the plain one and wins over it.
+var settings = []setting{
+	{"REPO_BACKEND", "backend", "repository backend: postgres, sqlite or memory", stringValue(func(c *Config) *string { return &c.Backend })},
+	{"HTTP_ADDR", "addr", "HTTP listen address", stringValue(func(c *Config) *string { return &c.Server.Addr })},
+	{"GRPC_ADDR", "grpc-addr", "gRPC listen address, empty disables the gRPC server", stringValue(func(c *Config) *string { return &c.Server.GRPCAddr })},
+	{"TLS_CERT_FILE", "tls-cert", "TLS certificate file, enables HTTPS together with -tls-key", stringValue(func(c *Config) *string { return &c.Server.TLSCertFile })},
+	{"TLS_KEY_FILE", "tls-key", "TLS private key file", stringValue(func(c *Config) *string { return &c.Server.TLSKeyFile })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time to drain requests and stop workers on shutdown", durationValue(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+
+	{"DB_HOST", "db-host", "PostgreSQL host", stringValue(func(c *Config) *string { return &c.Postgres.Host })},
+	{"DB_PORT", "db-port", "PostgreSQL port", stringValue(func(c *Config) *string { return &c.Postgres.Port })},
+	{"DB_USER", "db-user", "PostgreSQL user", stringValue(func(c *Config) *string { return &c.Postgres.User })},
+	{"DB_PASSWORD", "", "", stringValue(func(c *Config) *string { return &c.Postgres.Password })},
+	{"DB_PASSWORD_FILE", "db-password-file", "file containing the PostgreSQL password", secretFile(func(c *Config) *string { return &c.Postgres.Password })},
+	{"DB_NAME", "db-name", "PostgreSQL database", stringValue(func(c *Config) *string { return &c.Postgres.Name })},
+	{"DB_SSLMODE", "db-sslmode", "PostgreSQL sslmode: disable, allow, prefer, require, verify-ca or verify-full", stringValue(func(c *Config) *string { return &c.Postgres.SSLMode })},
+	{"DB_SSLROOTCERT", "db-sslrootcert", "CA certificate used to verify the PostgreSQL server", stringValue(func(c *Config) *string { return &c.Postgres.SSLRootCert })},
+	{"DB_SSLCERT", "db-sslcert", "client certificate for PostgreSQL", stringValue(func(c *Config) *string { return &c.Postgres.SSLCert })},
+	{"DB_SSLKEY", "db-sslkey", "client certificate key for PostgreSQL", stringValue(func(c *Config) *string { return &c.Postgres.SSLKey })},
+	{"SQLITE_PATH", "sqlite-path", "SQLite database file", stringValue(func(c *Config) *string { return &c.SQLitePath })},
+
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum number of open database connections (0 is unlimited)", intValue(func(c *Config) *int { return &c.Pool.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum number of idle database connections", intValue(func(c *Config) *int { return &c.Pool.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection (0 is unlimited)", durationValue(func(c *Config) *time.Duration { return &c.Pool.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum idle time of a database connection (0 is unlimited)", durationValue(func(c *Config) *time.Duration { return &c.Pool.ConnMaxIdleTime })},
+
+	{"OPTIMISTIC_LOCKING", "optimistic-locking", "condition balance updates on the wallet version", boolValue(func(c *Config) *bool { return &c.OptimisticLocking })},
+	{"MIGRATE_ON_STARTUP", "migrate", "apply pending migrations on startup", boolValue(func(c *Config) *bool { return &c.MigrateOnStartup })},
+	{"RECONCILE_INTERVAL", "reconcile-interval", "interval of the reconciliation job (0 disables it)", durationValue(func(c *Config) *time.Duration { return &c.ReconcileInterval })},
+	{"ADMIN_TOKEN", "", "", stringValue(func(c *Config) *string { return &c.AdminToken })},
+	{"ADMIN_TOKEN_FILE", "admin-token-file", "file containing the bearer token for /admin", secretFile(func(c *Config) *string { return &c.AdminToken })},
+
+	{"LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", stringValue(func(c *Config) *string { return &c.Log.Level })},
+	{"LOG_FORMAT", "log-format", "log format: json or text", stringValue(func(c *Config) *string { return &c.Log.Format })},
+
+	{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, stdout or otlp", stringValue(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "otlp-endpoint", "URL of the OTLP/HTTP trace collector", stringValue(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"OTEL_SERVICE_NAME", "service-name", "service name reported in traces", stringValue(func(c *Config) *string { return &c.Tracing.ServiceName })},
+	{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "fraction of new traces to record, from 0 to 1", floatValue(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+
+	{"RATE_LIMIT_ENABLED", "rate-limit", "rate limit the routes listed in the rate_limit config", boolValue(func(c *Config) *bool { return &c.RateLimit.Enabled })},
+	{"RATE_LIMIT_TRUST_PROXY", "rate-limit-trust-proxy", "take the client IP from X-Forwarded-For", boolValue(func(c *Config) *bool { return &c.RateLimit.TrustProxy })},
+
+	{"BATCH_DEFAULT_MODE", "batch-default-mode", "mode of transfer batches submitted without one: all_or_nothing or best_effort", stringValue(func(c *Config) *string { return &c.Batch.DefaultMode })},
+	{"BATCH_MAX_ITEMS", "batch-max-items", "maximum number of items of a transfer batch", intValue(func(c *Config) *int { return &c.Batch.MaxItems })},
+	{"BATCH_POLL_INTERVAL", "batch-poll-interval", "how often pending transfer batches are processed (0 disables processing)", durationValue(func(c *Config) *time.Duration { return &c.Batch.PollInterval })},
+	{"SCHEDULE_POLL_INTERVAL", "schedule-poll-interval", "how often due scheduled transfers are run (0 disables running them)", durationValue(func(c *Config) *time.Duration { return &c.Schedule.PollInterval })},
+	{"SCHEDULE_RETRY_INTERVAL", "schedule-retry-interval", "delay before retrying a scheduled transfer the sender could not cover", durationValue(func(c *Config) *time.Duration { return &c.Schedule.RetryInterval })},
+	{"SCHEDULE_MAX_RETRIES", "schedule-max-retries", "retries of a scheduled transfer the sender could not cover before it is skipped", intValue(func(c *Config) *int { return &c.Schedule.MaxRetries })},
+
+	{"API_LEGACY_ROUTES", "api-legacy-routes", "also serve the /v1 routes at their deprecated unversioned paths", boolValue(func(c *Config) *bool { return &c.API.LegacyRoutes })},
+	{"API_DEPRECATED_SINCE", "api-deprecated-since", "date announced in the Deprecation header of the legacy routes (YYYY-MM-DD)", dateValue(func(c *Config) *time.Time { return &c.API.DeprecatedSince })},
+	{"API_SUNSET", "api-sunset", "date announced in the Sunset header of the legacy routes (YYYY-MM-DD)", dateValue(func(c *Config) *time.Time { return &c.API.Sunset })},
+}
+
+func stringValue(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func secretFile(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, path string) error {
+		value, err := readSecret(path)
+		if err != nil {
+			return err
+		}
+		*field(c) = value
+		return nil
+	}
+}
+
+func intValue(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolValue(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func floatValue(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func durationValue(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// dateValue accepts a date as YYYY-MM-DD, meaning midnight UTC, or an RFC 3339
+// timestamp.
+func dateValue(field func(*Config) *time.Time) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%q is neither YYYY-MM-DD nor RFC 3339", value)
+		}
+		*field(c) = t
+		return nil
+	}
+}
+
+// Flags holds the configuration flags given on the command line. Secrets can
+// only be passed as files, never as flag values.
+type Flags struct {
+	file string
+	set  []flagValue
+}
+
+type flagValue struct {
+	setting
+	value string
+}
+
+// RegisterFlags adds -config and a flag for every setting to fs. Only flags
+// that are set override the other layers.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.file, "config", "", "YAML configuration file (overrides CONFIG_FILE)")
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		fs.Func(s.flag, s.usage+" ($"+s.env+")", func(value string) error {
+			// Catch malformed values while parsing so that flag prints
+			// the usage.
+			if err := s.apply(&Config{}, value); err != nil {
+				return err
+			}
+			f.set = append(f.set, flagValue{setting: s, value: value})
+			return nil
+		})
+	}
+	return f
+}
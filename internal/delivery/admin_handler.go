@@ -0,0 +1,84 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// AdminHandler serves the operator endpoints under /admin. They must be
+// mounted behind RequireAdminToken.
+type AdminHandler struct {
+	reconciliation *usecase.ReconciliationUsecase
+}
+
+func NewAdminHandler(reconciliation *usecase.ReconciliationUsecase) *AdminHandler {
+	return &AdminHandler{reconciliation: reconciliation}
+}
+
+// RunReconciliation checks the ledger now and returns the stored report.
+func (h *AdminHandler) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.reconciliation.Run(r.Context(), domain.ReconciliationTriggerManual)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (h *AdminHandler) ListReconciliations(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			respondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+	}
+
+	resp, err := h.reconciliation.ListReports(r.Context(), limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *AdminHandler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.reconciliation.GetReport(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, domain.ErrReportNotFound) {
+		respondWithError(w, http.StatusNotFound, "reconciliation report not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// RequireAdminToken rejects requests that do not carry
+// "Authorization: Bearer <token>".
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				respondWithError(w, http.StatusUnauthorized, "invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
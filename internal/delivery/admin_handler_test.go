@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminRouter() http.Handler {
+	uc := usecase.NewReconciliationUsecase(repository.NewMemoryRepo(), repository.NewMemoryReconciliationRepo())
+	h := NewAdminHandler(uc)
+
+	r := chi.NewRouter()
+	r.Use(RequireAdminToken("secret"))
+	r.Post("/admin/reconciliations", h.RunReconciliation)
+	r.Get("/admin/reconciliations", h.ListReconciliations)
+	r.Get("/admin/reconciliations/{id}", h.GetReconciliation)
+	return r
+}
+
+func adminRequest(t *testing.T, router http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminHandler_Reconciliations(t *testing.T) {
+	router := newAdminRouter()
+
+	require.Equal(t, http.StatusUnauthorized, adminRequest(t, router, http.MethodPost, "/admin/reconciliations", "").Code)
+	require.Equal(t, http.StatusUnauthorized, adminRequest(t, router, http.MethodPost, "/admin/reconciliations", "wrong").Code)
+
+	rec := adminRequest(t, router, http.MethodPost, "/admin/reconciliations", "secret")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created usecase.ReconciliationResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "manual", created.Trigger)
+	require.Equal(t, "ok", created.Status)
+
+	rec = adminRequest(t, router, http.MethodGet, "/admin/reconciliations/"+created.ID, "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest(t, router, http.MethodGet, "/admin/reconciliations?limit=5", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed []usecase.ReconciliationResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+
+	require.Equal(t, http.StatusNotFound, adminRequest(t, router, http.MethodGet, "/admin/reconciliations/missing", "secret").Code)
+	require.Equal(t, http.StatusBadRequest, adminRequest(t, router, http.MethodGet, "/admin/reconciliations?limit=x", "secret").Code)
+}
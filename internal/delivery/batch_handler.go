@@ -0,0 +1,74 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"github.com/go-chi/chi"
+)
+
+// BatchHandler serves the transfer batch endpoints. Batches are processed
+// asynchronously, so submitting one returns 202 Accepted and the batch is
+// polled with GetBatch.
+type BatchHandler struct {
+	uc *usecase.BatchUsecase
+}
+
+func NewBatchHandler(uc *usecase.BatchUsecase) *BatchHandler {
+	return &BatchHandler{uc: uc}
+}
+
+func (h *BatchHandler) SubmitBatch(w http.ResponseWriter, r *http.Request) {
+	var req usecase.BatchTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.uc.SubmitBatch(r.Context(), req)
+	if err != nil {
+		respondWithBatchError(w, err)
+		return
+	}
+
+	// POST .../transfers/batch is answered with .../batches/{id}, keeping the
+	// version prefix of the request.
+	w.Header().Set("Location", path.Join(path.Dir(path.Dir(r.URL.Path)), "batches", resp.BatchID))
+	respondWithJSON(w, http.StatusAccepted, resp)
+}
+
+func (h *BatchHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.uc.GetBatch(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithBatchError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// respondWithBatchError answers with the status of a usecase error of the
+// batch endpoints. Unexpected errors are not described to the client.
+func respondWithBatchError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrEmptyBatch), errors.Is(err, usecase.ErrBatchTooLarge),
+		errors.Is(err, usecase.ErrInvalidBatchMode), errors.Is(err, usecase.ErrInvalidBatchReference),
+		errors.Is(err, usecase.ErrInvalidAmount), errors.Is(err, usecase.ErrSameUser):
+		status = http.StatusBadRequest
+	case errors.Is(err, usecase.ErrBatchReferenceExists):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrBatchNotFound):
+		status = http.StatusNotFound
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchRouter(t *testing.T) (http.Handler, *usecase.BatchUsecase) {
+	t.Helper()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := payments.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	uc := usecase.NewBatchUsecase(payments, repository.NewMemoryBatchRepo(), usecase.DefaultBatchPolicy)
+	h := NewBatchHandler(uc)
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/transfers/batch", h.SubmitBatch)
+		r.Get("/batches/{id}", h.GetBatch)
+	})
+	return r, uc
+}
+
+func batchRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBatchHandler(t *testing.T) {
+	router, uc := newBatchRouter(t)
+	body := `{"reference":"payroll","sender_id":"alice","mode":"best_effort","items":[{"receiver_id":"bob","amount":10},{"receiver_id":"carol","amount":5}]}`
+
+	rec := batchRequest(router, http.MethodPost, "/v1/transfers/batch", body)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var submitted usecase.BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &submitted))
+	require.Equal(t, "pending", submitted.Status)
+	require.EqualValues(t, 15, submitted.TotalAmount)
+	require.Equal(t, "/v1/batches/"+submitted.BatchID, rec.Header().Get("Location"))
+
+	rec = batchRequest(router, http.MethodPost, "/v1/transfers/batch", body)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.JSONEq(t, `{"error":"batch reference already exists","code":"reference_exists"}`, rec.Body.String())
+
+	_, err := uc.ProcessNext(t.Context())
+	require.NoError(t, err)
+
+	rec = batchRequest(router, http.MethodGet, "/v1/batches/"+submitted.BatchID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var processed usecase.BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &processed))
+	require.Equal(t, "partially_completed", processed.Status)
+	require.Equal(t, "completed", processed.Items[0].Status)
+	require.NotEmpty(t, processed.Items[0].TransactionID)
+	require.Equal(t, "wallet_not_found", processed.Items[1].Error)
+
+	rec = batchRequest(router, http.MethodGet, "/v1/batches/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"batch_not_found"`)
+}
+
+func TestBatchHandler_Validation(t *testing.T) {
+	router, _ := newBatchRouter(t)
+	for _, tc := range []struct {
+		body string
+		code string
+	}{
+		{`{"reference":`, "invalid_request"},
+		{`{"reference":"b","sender_id":"alice","items":[]}`, "invalid_batch"},
+		{`{"reference":"b","sender_id":"alice","mode":"atomic","items":[{"receiver_id":"bob","amount":1}]}`, "invalid_batch"},
+		{`{"reference":"b","sender_id":"alice","items":[{"receiver_id":"bob","amount":-1}]}`, "invalid_amount"},
+		{`{"reference":"b","sender_id":"alice","items":[{"receiver_id":"alice","amount":1}]}`, "same_user"},
+	} {
+		rec := batchRequest(router, http.MethodPost, "/v1/transfers/batch", tc.body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, tc.body)
+		require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, tc.body)
+	}
+}
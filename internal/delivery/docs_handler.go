@@ -0,0 +1,46 @@
+package delivery
+
+import (
+	"net/http"
+)
+
+// swaggerUI renders the document at /openapi.json with Swagger UI loaded from
+// a CDN, so that the binary does not have to ship its assets.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Payment Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves the OpenAPI document of the HTTP API and a Swagger UI
+// page for it.
+type DocsHandler struct {
+	spec []byte
+}
+
+func NewDocsHandler(spec []byte) *DocsHandler {
+	return &DocsHandler{spec: spec}
+}
+
+func (h *DocsHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.spec)
+}
+
+func (h *DocsHandler) SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUI))
+}
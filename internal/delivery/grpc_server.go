@@ -0,0 +1,154 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	paymentv1 "payment-service/api/payment/v1"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GrpcServer serves the PaymentService of api/payment/v1 with the same
+// usecase as HttpHandler.
+type GrpcServer struct {
+	paymentv1.UnimplementedPaymentServiceServer
+	uc *usecase.PaymentUsecase
+}
+
+func NewGrpcServer(uc *usecase.PaymentUsecase) *GrpcServer {
+	return &GrpcServer{uc: uc}
+}
+
+func (s *GrpcServer) Transfer(ctx context.Context, req *paymentv1.TransferRequest) (*paymentv1.TransferResponse, error) {
+	resp, err := s.uc.TransferFunds(ctx, usecase.TransferRequest{
+		SenderID:        req.GetSenderId(),
+		ReceiverID:      req.GetReceiverId(),
+		Amount:          req.GetAmount(),
+		Reference:       req.GetReference(),
+		ExpectedVersion: expectedVersion(req.ExpectedVersion),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &paymentv1.TransferResponse{
+		TransactionId: resp.TransactionID,
+		Reference:     resp.Reference,
+		Amount:        resp.Amount,
+		Status:        resp.Status,
+		CreatedAt:     timestamppb.New(resp.CreatedAt),
+	}, nil
+}
+
+func (s *GrpcServer) TopUp(ctx context.Context, req *paymentv1.TopUpRequest) (*paymentv1.TopUpResponse, error) {
+	resp, err := s.uc.TopUpWallet(ctx, usecase.TopUpRequest{
+		UserID:          req.GetUserId(),
+		Amount:          req.GetAmount(),
+		ExpectedVersion: expectedVersion(req.ExpectedVersion),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &paymentv1.TopUpResponse{UserId: resp.UserID, Amount: resp.Amount, Balance: resp.Balance}, nil
+}
+
+func (s *GrpcServer) GetTransaction(ctx context.Context, req *paymentv1.GetTransactionRequest) (*paymentv1.Transaction, error) {
+	if req.GetReference() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reference is required")
+	}
+
+	details, err := s.uc.GetTransactionDetails(ctx, req.GetReference())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcTransaction(details), nil
+}
+
+func (s *GrpcServer) GetWallet(ctx context.Context, req *paymentv1.GetWalletRequest) (*paymentv1.Wallet, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user ID is required")
+	}
+
+	wallet, err := s.uc.GetWallet(ctx, req.GetUserId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &paymentv1.Wallet{
+		WalletId:  wallet.WalletID,
+		UserId:    wallet.UserID,
+		Balance:   wallet.Balance,
+		Version:   int32(wallet.Version),
+		Status:    wallet.Status,
+		CreatedAt: timestamppb.New(wallet.CreatedAt),
+		UpdatedAt: timestamppb.New(wallet.UpdatedAt),
+	}, nil
+}
+
+func (s *GrpcServer) ListTransactions(ctx context.Context, req *paymentv1.ListTransactionsRequest) (*paymentv1.ListTransactionsResponse, error) {
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	details, err := s.uc.ListTransactions(ctx, usecase.ListTransactionsRequest{
+		UserID: req.GetUserId(),
+		Type:   req.GetType(),
+		Limit:  int(req.GetLimit()),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &paymentv1.ListTransactionsResponse{Transactions: make([]*paymentv1.Transaction, 0, len(details))}
+	for i := range details {
+		resp.Transactions = append(resp.Transactions, grpcTransaction(&details[i]))
+	}
+	return resp, nil
+}
+
+func grpcTransaction(d *usecase.TransactionDetails) *paymentv1.Transaction {
+	return &paymentv1.Transaction{
+		TransactionId: d.TransactionID,
+		Reference:     d.Reference,
+		Type:          d.Type,
+		SenderId:      d.SenderID,
+		ReceiverId:    d.ReceiverID,
+		Amount:        d.Amount,
+		Status:        d.Status,
+		CreatedAt:     timestamppb.New(d.CreatedAt),
+	}
+}
+
+func expectedVersion(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	version := int(*v)
+	return &version
+}
+
+// grpcError maps usecase and domain errors to a gRPC status. Internal errors
+// are not described to the client.
+func grpcError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, domain.ErrWalletNotFound), errors.Is(err, domain.ErrTransactionNotFound):
+		code = codes.NotFound
+	case errors.Is(err, usecase.ErrInvalidAmount), errors.Is(err, usecase.ErrSameUser), errors.Is(err, usecase.ErrEmptyUsername):
+		code = codes.InvalidArgument
+	case errors.Is(err, usecase.ErrInsufficientBalance), errors.Is(err, usecase.ErrVersionMismatch), errors.Is(err, usecase.ErrWalletFrozen):
+		code = codes.FailedPrecondition
+	case errors.Is(err, usecase.ErrReferenceExists), errors.Is(err, domain.ErrDuplicateReference), errors.Is(err, domain.ErrUserExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, domain.ErrTxConflict), errors.Is(err, domain.ErrVersionConflict):
+		code = codes.Aborted
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+	return status.Error(code, err.Error())
+}
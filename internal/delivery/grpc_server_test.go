@@ -0,0 +1,121 @@
+package delivery
+
+import (
+	"context"
+	"net"
+	paymentv1 "payment-service/api/payment/v1"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func newGrpcClient(t *testing.T) paymentv1.PaymentServiceClient {
+	t.Helper()
+	uc := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	paymentv1.RegisterPaymentServiceServer(srv, NewGrpcServer(uc))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return paymentv1.NewPaymentServiceClient(conn)
+}
+
+func TestGrpcServer(t *testing.T) {
+	ctx := t.Context()
+	client := newGrpcClient(t)
+
+	transfer, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "bob", Amount: 30, Reference: "ref-1"})
+	require.NoError(t, err)
+	require.Equal(t, "ref-1", transfer.GetReference())
+	require.NotEmpty(t, transfer.GetTransactionId())
+
+	topUp, err := client.TopUp(ctx, &paymentv1.TopUpRequest{UserId: "bob", Amount: 20})
+	require.NoError(t, err)
+	require.EqualValues(t, 150, topUp.GetBalance())
+
+	tx, err := client.GetTransaction(ctx, &paymentv1.GetTransactionRequest{Reference: "ref-1"})
+	require.NoError(t, err)
+	require.Equal(t, "transfer", tx.GetType())
+	require.Equal(t, "alice", tx.GetSenderId())
+	require.Equal(t, "bob", tx.GetReceiverId())
+
+	wallet, err := client.GetWallet(ctx, &paymentv1.GetWalletRequest{UserId: "alice"})
+	require.NoError(t, err)
+	require.EqualValues(t, 70, wallet.GetBalance())
+
+	list, err := client.ListTransactions(ctx, &paymentv1.ListTransactionsRequest{UserId: "bob", Type: "topup"})
+	require.NoError(t, err)
+	require.Len(t, list.GetTransactions(), 1)
+	require.EqualValues(t, 20, list.GetTransactions()[0].GetAmount())
+}
+
+func TestGrpcServer_StatusCodes(t *testing.T) {
+	ctx := t.Context()
+	client := newGrpcClient(t)
+	_, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "bob", Amount: 10, Reference: "taken"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"invalid amount", func() error {
+			_, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "bob", Amount: 0, Reference: "r1"})
+			return err
+		}, codes.InvalidArgument},
+		{"same user", func() error {
+			_, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "alice", Amount: 1, Reference: "r2"})
+			return err
+		}, codes.InvalidArgument},
+		{"insufficient balance", func() error {
+			_, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "bob", Amount: 1000, Reference: "r3"})
+			return err
+		}, codes.FailedPrecondition},
+		{"version mismatch", func() error {
+			_, err := client.TopUp(ctx, &paymentv1.TopUpRequest{UserId: "alice", Amount: 1, ExpectedVersion: proto.Int32(99)})
+			return err
+		}, codes.FailedPrecondition},
+		{"reference exists", func() error {
+			_, err := client.Transfer(ctx, &paymentv1.TransferRequest{SenderId: "alice", ReceiverId: "bob", Amount: 1, Reference: "taken"})
+			return err
+		}, codes.AlreadyExists},
+		{"unknown wallet", func() error {
+			_, err := client.GetWallet(ctx, &paymentv1.GetWalletRequest{UserId: "carol"})
+			return err
+		}, codes.NotFound},
+		{"unknown transaction", func() error {
+			_, err := client.GetTransaction(ctx, &paymentv1.GetTransactionRequest{Reference: "missing"})
+			return err
+		}, codes.NotFound},
+		{"missing user", func() error {
+			_, err := client.GetWallet(ctx, &paymentv1.GetWalletRequest{})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, status.Code(tt.call()))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck is a named dependency check run by /readyz. Check must return
+// promptly once its context is done.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthHandler serves the Kubernetes style probes: /healthz answers as long
+// as the process serves HTTP, /readyz only when every check passes.
+type HealthHandler struct {
+	timeout time.Duration
+	checks  []HealthCheck
+}
+
+// NewHealthHandler returns a handler that gives all checks together at most
+// timeout.
+func NewHealthHandler(timeout time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{timeout: timeout, checks: checks}
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, HealthResponse{Status: healthOK})
+}
+
+// Ready runs the checks concurrently and answers 503 if any of them fails or
+// does not finish in time.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: healthOK, Checks: make(map[string]CheckResult, len(h.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[c.Name] = result
+			if result.Status != healthOK {
+				resp.Status = healthFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if resp.Status != healthOK {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, resp)
+}
+
+// runCheck runs c, reporting it as failed when it outlives ctx even if the
+// check itself ignores the context.
+func runCheck(ctx context.Context, c HealthCheck) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: healthOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = healthFail
+		result.Error = err.Error()
+	}
+	return result
+}
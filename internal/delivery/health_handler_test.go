@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveHealth(t *testing.T, handler http.HandlerFunc) (int, HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var resp HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestHealthHandler(t *testing.T) {
+	ok := HealthCheck{Name: "database", Check: func(ctx context.Context) error { return nil }}
+	failing := HealthCheck{Name: "migrations", Check: func(ctx context.Context) error { return errors.New("schema is at version 3, expected 4") }}
+	hanging := HealthCheck{Name: "reconciler", Check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	h := NewHealthHandler(50*time.Millisecond, ok, failing, hanging)
+	code, resp := serveHealth(t, h.Live)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", resp.Status)
+
+	code, resp = serveHealth(t, h.Ready)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "fail", resp.Status)
+	require.Equal(t, "ok", resp.Checks["database"].Status)
+	require.Equal(t, "schema is at version 3, expected 4", resp.Checks["migrations"].Error)
+	require.Equal(t, context.DeadlineExceeded.Error(), resp.Checks["reconciler"].Error)
+
+	code, resp = serveHealth(t, NewHealthHandler(time.Second, ok).Ready)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", resp.Status)
+	require.Len(t, resp.Checks, 1)
+}
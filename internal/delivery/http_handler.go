@@ -2,8 +2,13 @@ package delivery
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"payment-service/internal/domain"
 	"payment-service/internal/usecase"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 )
@@ -19,13 +24,20 @@ func NewHttpHandler(uc *usecase.PaymentUsecase) *HttpHandler {
 func (h *HttpHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 	var req usecase.TransferRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
 		return
 	}
 
-	resp, err := h.uc.TransferFunds(req)
+	expected, err := parseIfMatch(r.Header.Get("If-Match"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	req.ExpectedVersion = expected
+
+	resp, err := h.uc.TransferFunds(r.Context(), req)
+	if err != nil {
+		respondWithPaymentError(w, err)
 		return
 	}
 
@@ -35,13 +47,13 @@ func (h *HttpHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 func (h *HttpHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
 	refID := chi.URLParam(r, "refId")
 	if refID == "" {
-		respondWithError(w, http.StatusBadRequest, "reference ID is required")
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "reference ID is required")
 		return
 	}
 
-	resp, err := h.uc.GetTransactionByRef(refID)
+	resp, err := h.uc.GetTransactionByRef(r.Context(), refID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "transaction not found")
+		respondWithErrorCode(w, http.StatusNotFound, usecase.ErrorCode(domain.ErrTransactionNotFound), "transaction not found")
 		return
 	}
 
@@ -51,13 +63,20 @@ func (h *HttpHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
 func (h *HttpHandler) TopUp(w http.ResponseWriter, r *http.Request) {
 	var req usecase.TopUpRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
 		return
 	}
 
-	resp, err := h.uc.TopUpWallet(req)
+	expected, err := parseIfMatch(r.Header.Get("If-Match"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	req.ExpectedVersion = expected
+
+	resp, err := h.uc.TopUpWallet(r.Context(), req)
+	if err != nil {
+		respondWithPaymentError(w, err)
 		return
 	}
 
@@ -67,13 +86,25 @@ func (h *HttpHandler) TopUp(w http.ResponseWriter, r *http.Request) {
 func (h *HttpHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "userId")
 	if userID == "" {
-		respondWithError(w, http.StatusBadRequest, "user ID is required")
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "user ID is required")
 		return
 	}
 
-	resp, err := h.uc.GetWallet(userID)
+	resp, err := h.uc.GetWallet(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "wallet not found")
+		respondWithErrorCode(w, http.StatusNotFound, usecase.ErrorCode(domain.ErrWalletNotFound), "wallet not found")
+		return
+	}
+
+	etag := walletETag(resp.Version)
+	w.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-Match"); match != "" && !etagMatches(match, etag) {
+		respondWithErrorCode(w, http.StatusPreconditionFailed, usecase.ErrorCode(usecase.ErrVersionMismatch), usecase.ErrVersionMismatch.Error())
+		return
+	}
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && etagMatches(noneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -89,3 +120,79 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
 }
+
+// codeInvalidRequest is the error code of malformed requests.
+const codeInvalidRequest = "invalid_request"
+
+// respondWithErrorCode adds a stable, machine readable errorCode to the error
+// response, usually usecase.ErrorCode of the error, so that clients need not
+// parse messages.
+func respondWithErrorCode(w http.ResponseWriter, code int, errorCode, message string) {
+	respondWithJSON(w, code, map[string]string{"error": message, "code": errorCode})
+}
+
+// errorStatus maps usecase errors of the mutating endpoints to a status code.
+// Conflicts that outlasted the retries answer 503, so that clients try again;
+// unexpected errors answer 500.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrWalletFrozen):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrTxConflict), errors.Is(err, domain.ErrVersionConflict):
+		return http.StatusServiceUnavailable
+	case usecase.ErrorCode(err) == "internal":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// respondWithPaymentError answers with the status of a usecase error of the
+// transfer and top up endpoints. Unexpected errors are not described to the
+// client.
+func respondWithPaymentError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
+
+// walletETag derives the strong entity tag of a wallet from its version.
+func walletETag(version int) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// matches etag. Weak tags compare by their opaque value.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch extracts the wallet version expected by an If-Match header on
+// a mutating request. An empty header or "*" means no expectation.
+func parseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	tag := strings.TrimPrefix(header, "W/")
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) {
+		return nil, fmt.Errorf("invalid If-Match header")
+	}
+	version, err := strconv.Atoi(tag[2 : len(tag)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid If-Match header")
+	}
+	return &version, nil
+}
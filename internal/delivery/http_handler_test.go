@@ -0,0 +1,58 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepo is a memory repository whose transactions cannot be started.
+type failingRepo struct {
+	domain.TransactionRepository
+	err error
+}
+
+func (r *failingRepo) BeginTx(ctx context.Context) (interface{}, error) {
+	return nil, r.err
+}
+
+func TestHttpHandler_ServerErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"conflict", fmt.Errorf("%w: deadlock detected", domain.ErrTxConflict), http.StatusServiceUnavailable, "tx_conflict"},
+		{"internal", errors.New(`pq: relation "wallets" does not exist`), http.StatusInternalServerError, "internal"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &failingRepo{TransactionRepository: repository.NewMemoryRepo(), err: tc.err}
+			h := NewHttpHandler(usecase.NewPaymentUsecase(repo, usecase.WithRetryPolicy(usecase.RetryPolicy{MaxAttempts: 1})))
+			r := chi.NewRouter()
+			r.Post("/v1/transfer", h.Transfer)
+			r.Post("/v1/topup", h.TopUp)
+
+			for target, body := range map[string]string{
+				"/v1/transfer": `{"sender_id":"alice","receiver_id":"bob","amount":10,"reference":"ref-1"}`,
+				"/v1/topup":    `{"user_id":"alice","amount":10}`,
+			} {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+				require.Equal(t, tc.status, rec.Code, target)
+				require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, target)
+				require.NotContains(t, rec.Body.String(), "pq:", target)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package delivery
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/api"
+	"payment-service/internal/config"
+	"payment-service/internal/ratelimit"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+// newDocumentedRouter mounts every documented route the way cmd/api does. Top
+// ups of a user are limited to two per minute so that a 429 can be provoked.
+func newDocumentedRouter(t *testing.T) http.Handler {
+	t.Helper()
+	repo := repository.NewMemoryRepo()
+	uc := usecase.NewPaymentUsecase(repo)
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		_, err := uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	_, err := uc.FreezeWallet(t.Context(), "carol")
+	require.NoError(t, err)
+
+	handler := NewHttpHandler(uc)
+	health := NewHealthHandler(time.Second)
+	admin := NewAdminHandler(usecase.NewReconciliationUsecase(repo, repository.NewMemoryReconciliationRepo()))
+	batches := NewBatchHandler(usecase.NewBatchUsecase(uc, repository.NewMemoryBatchRepo(), usecase.DefaultBatchPolicy))
+	payouts := NewPayoutHandler(uc)
+	schedules := NewScheduleHandler(usecase.NewScheduleUsecase(uc, repository.NewMemoryScheduleRepo(), usecase.DefaultSchedulePolicy))
+	requests := NewPaymentRequestHandler(usecase.NewPaymentRequestUsecase(uc, repository.NewMemoryPaymentRequestRepo()))
+	splits := NewSplitHandler(usecase.NewSplitUsecase(uc, repository.NewMemorySplitRepo()))
+	docs := NewDocsHandler(api.OpenAPI)
+	limiter := ratelimit.New(config.RateLimit{
+		Enabled: true,
+		Routes: []config.RoutePolicy{{
+			Route:     "POST /topup",
+			UserField: "user_id",
+			Limits:    []config.RateLimitRule{{Key: "user", Requests: 2, Period: time.Minute}},
+		}},
+	}, ratelimit.NewMemoryStore())
+
+	r := chi.NewRouter()
+	r.Get("/openapi.json", docs.OpenAPI)
+	r.Get("/docs", docs.SwaggerUI)
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", health.Ready)
+	r.Route("/v1", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(limiter.Middleware)
+			r.Post("/transfer", handler.Transfer)
+			r.Post("/topup", handler.TopUp)
+			r.Get("/transaction/{refId}", handler.GetTransaction)
+			r.Get("/wallet/{userId}", handler.GetWallet)
+			r.Post("/transfers/batch", batches.SubmitBatch)
+			r.Get("/batches/{id}", batches.GetBatch)
+			r.Post("/payouts", payouts.UploadPayout)
+			r.Post("/schedules", schedules.CreateSchedule)
+			r.Get("/schedules/{id}", schedules.GetSchedule)
+			r.Post("/schedules/{id}/pause", schedules.PauseSchedule)
+			r.Post("/schedules/{id}/resume", schedules.ResumeSchedule)
+			r.Post("/schedules/{id}/cancel", schedules.CancelSchedule)
+			r.Post("/payment-requests", requests.CreatePaymentRequest)
+			r.Get("/payment-requests", requests.ListPaymentRequests)
+			r.Get("/payment-requests/{id}", requests.GetPaymentRequest)
+			r.Post("/payment-requests/{id}/accept", requests.AcceptPaymentRequest)
+			r.Post("/payment-requests/{id}/decline", requests.DeclinePaymentRequest)
+			r.Post("/splits", splits.CreateSplit)
+			r.Get("/splits/{id}", splits.GetSplit)
+			r.Post("/splits/{id}/pay", splits.PayShare)
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(RequireAdminToken("secret"))
+			r.Post("/reconciliations", admin.RunReconciliation)
+			r.Get("/reconciliations", admin.ListReconciliations)
+			r.Get("/reconciliations/{id}", admin.GetReconciliation)
+		})
+	})
+	return r
+}
+
+// TestOpenAPI_ResponsesMatchSpec serves requests covering the documented
+// responses and checks that each request and response validates against
+// api/openapi.json, including that the status code is documented.
+func TestOpenAPI_ResponsesMatchSpec(t *testing.T) {
+	ctx := t.Context()
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.PlainBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("text/html")
+	doc, err := openapi3.NewLoader().LoadFromData(api.OpenAPI)
+	require.NoError(t, err)
+	specRouter, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+	router := newDocumentedRouter(t)
+
+	admin := map[string]string{"Authorization": "Bearer secret"}
+	csv := map[string]string{"Content-Type": "text/csv"}
+	csvResults := map[string]string{"Content-Type": "text/csv", "Accept": "text/csv"}
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		body    string
+		want    int
+		// invalid requests are not validated against the spec, only their
+		// responses.
+		invalid bool
+	}{
+		{"spec", http.MethodGet, "/openapi.json", nil, "", http.StatusOK, false},
+		{"swagger ui", http.MethodGet, "/docs", nil, "", http.StatusOK, false},
+		{"live", http.MethodGet, "/healthz", nil, "", http.StatusOK, false},
+		{"ready", http.MethodGet, "/readyz", nil, "", http.StatusOK, false},
+		{"transfer", http.MethodPost, "/v1/transfer", nil, `{"sender_id":"alice","receiver_id":"bob","amount":10,"reference":"ref-1"}`, http.StatusOK, false},
+		{"transfer insufficient balance", http.MethodPost, "/v1/transfer", nil, `{"sender_id":"alice","receiver_id":"bob","amount":1000,"reference":"ref-2"}`, http.StatusBadRequest, false},
+		{"transfer unknown wallet", http.MethodPost, "/v1/transfer", nil, `{"sender_id":"alice","receiver_id":"erin","amount":1,"reference":"ref-3"}`, http.StatusNotFound, false},
+		{"transfer frozen wallet", http.MethodPost, "/v1/transfer", nil, `{"sender_id":"alice","receiver_id":"carol","amount":1,"reference":"ref-4"}`, http.StatusConflict, false},
+		{"transfer stale version", http.MethodPost, "/v1/transfer", map[string]string{"If-Match": `"v99"`}, `{"sender_id":"alice","receiver_id":"bob","amount":1,"reference":"ref-5"}`, http.StatusPreconditionFailed, false},
+		{"top up", http.MethodPost, "/v1/topup", nil, `{"user_id":"dave","amount":5}`, http.StatusOK, false},
+		{"top up again", http.MethodPost, "/v1/topup", nil, `{"user_id":"dave","amount":5}`, http.StatusOK, false},
+		{"top up rate limited", http.MethodPost, "/v1/topup", nil, `{"user_id":"dave","amount":5}`, http.StatusTooManyRequests, false},
+		{"transaction", http.MethodGet, "/v1/transaction/ref-1", nil, "", http.StatusOK, false},
+		{"unknown transaction", http.MethodGet, "/v1/transaction/missing", nil, "", http.StatusNotFound, false},
+		{"wallet", http.MethodGet, "/v1/wallet/alice", nil, "", http.StatusOK, false},
+		{"wallet not modified", http.MethodGet, "/v1/wallet/alice", map[string]string{"If-None-Match": `"v2"`}, "", http.StatusNotModified, false},
+		{"unknown wallet", http.MethodGet, "/v1/wallet/erin", nil, "", http.StatusNotFound, false},
+		{"submit batch", http.MethodPost, "/v1/transfers/batch", nil, `{"reference":"batch-1","sender_id":"alice","mode":"best_effort","items":[{"receiver_id":"bob","amount":1},{"receiver_id":"dave","amount":2}]}`, http.StatusAccepted, false},
+		{"batch reference reused", http.MethodPost, "/v1/transfers/batch", nil, `{"reference":"batch-1","sender_id":"alice","items":[{"receiver_id":"bob","amount":1}]}`, http.StatusConflict, false},
+		{"empty batch", http.MethodPost, "/v1/transfers/batch", nil, `{"reference":"batch-2","sender_id":"alice","items":[]}`, http.StatusBadRequest, true},
+		{"unknown batch", http.MethodGet, "/v1/batches/missing", nil, "", http.StatusNotFound, false},
+		{"create schedule", http.MethodPost, "/v1/schedules", nil, `{"reference":"allowance","sender_id":"alice","receiver_id":"bob","amount":5,"recurrence":"FREQ=MONTHLY"}`, http.StatusCreated, false},
+		{"schedule reference reused", http.MethodPost, "/v1/schedules", nil, `{"reference":"allowance","sender_id":"alice","receiver_id":"bob","amount":5}`, http.StatusConflict, false},
+		{"invalid recurrence", http.MethodPost, "/v1/schedules", nil, `{"reference":"hourly","sender_id":"alice","receiver_id":"bob","amount":5,"recurrence":"FREQ=HOURLY"}`, http.StatusBadRequest, false},
+		{"unknown schedule", http.MethodGet, "/v1/schedules/missing", nil, "", http.StatusNotFound, false},
+		{"pause unknown schedule", http.MethodPost, "/v1/schedules/missing/pause", nil, "", http.StatusNotFound, false},
+		{"request payment", http.MethodPost, "/v1/payment-requests", nil, `{"requester_id":"bob","payer_id":"alice","amount":5,"note":"lunch"}`, http.StatusCreated, false},
+		{"payment request expired", http.MethodPost, "/v1/payment-requests", nil, `{"requester_id":"bob","payer_id":"alice","amount":5,"expires_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, false},
+		{"list payment requests", http.MethodGet, "/v1/payment-requests?user_id=alice&limit=5", nil, "", http.StatusOK, false},
+		{"list payment requests without user", http.MethodGet, "/v1/payment-requests", nil, "", http.StatusBadRequest, true},
+		{"unknown payment request", http.MethodGet, "/v1/payment-requests/missing", nil, "", http.StatusNotFound, false},
+		{"accept unknown payment request", http.MethodPost, "/v1/payment-requests/missing/accept", nil, `{"payer_id":"alice"}`, http.StatusNotFound, false},
+		{"decline without payer", http.MethodPost, "/v1/payment-requests/missing/decline", nil, `{}`, http.StatusBadRequest, true},
+		{"split bill", http.MethodPost, "/v1/splits", nil, `{"organizer_id":"bob","description":"pizza","total":10,"participants":[{"participant_id":"bob"},{"participant_id":"alice"},{"participant_id":"dave"}]}`, http.StatusCreated, false},
+		{"split by percentage", http.MethodPost, "/v1/splits", nil, `{"organizer_id":"bob","total":10,"method":"percentage","participants":[{"participant_id":"alice","percentage":62.5},{"participant_id":"dave","percentage":37.5}]}`, http.StatusCreated, false},
+		{"split percentages off", http.MethodPost, "/v1/splits", nil, `{"organizer_id":"bob","total":10,"method":"percentage","participants":[{"participant_id":"alice","percentage":50}]}`, http.StatusBadRequest, false},
+		{"unknown split", http.MethodGet, "/v1/splits/missing", nil, "", http.StatusNotFound, false},
+		{"pay unknown split", http.MethodPost, "/v1/splits/missing/pay", nil, `{"participant_id":"alice"}`, http.StatusNotFound, false},
+		{"payout dry run", http.MethodPost, "/v1/payouts?sender_id=bob&dry_run=true", csv, "receiver,amount,reference\nalice,1,payout-1\n", http.StatusOK, false},
+		{"payout", http.MethodPost, "/v1/payouts?sender_id=bob", nil, `[{"receiver_id":"alice","amount":1,"reference":"payout-1","note":"bonus"}]`, http.StatusOK, false},
+		{"payout csv results", http.MethodPost, "/v1/payouts?sender_id=bob", csvResults, "alice,1,payout-2\n", http.StatusOK, false},
+		{"payout invalid rows", http.MethodPost, "/v1/payouts?sender_id=bob", csv, "alice,1,payout-1\ncarol,1,payout-3\n", http.StatusUnprocessableEntity, false},
+		{"payout unknown sender", http.MethodPost, "/v1/payouts?sender_id=erin", csv, "alice,1,payout-4\n", http.StatusNotFound, false},
+		{"run reconciliation", http.MethodPost, "/v1/admin/reconciliations", admin, "", http.StatusCreated, false},
+		{"list reconciliations", http.MethodGet, "/v1/admin/reconciliations?limit=5", admin, "", http.StatusOK, false},
+		{"invalid limit", http.MethodGet, "/v1/admin/reconciliations?limit=0", admin, "", http.StatusBadRequest, true},
+		{"unknown reconciliation", http.MethodGet, "/v1/admin/reconciliations/missing", admin, "", http.StatusNotFound, false},
+		{"no admin token", http.MethodGet, "/v1/admin/reconciliations", nil, "", http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		newRequest := func() *http.Request {
+			req := httptest.NewRequest(tt.method, "http://localhost:8080"+tt.path, bytes.NewBufferString(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			return req
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, newRequest())
+		require.Equal(t, tt.want, rec.Code, "%s: %s", tt.name, rec.Body.String())
+
+		req := newRequest()
+		route, pathParams, err := specRouter.FindRoute(req)
+		require.NoError(t, err, tt.name)
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+		}
+		if !tt.invalid {
+			require.NoError(t, openapi3filter.ValidateRequest(ctx, input), tt.name)
+		}
+		require.NoError(t, openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.Code,
+			Header:                 rec.Header(),
+			Body:                   io.NopCloser(rec.Body),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}), tt.name)
+	}
+}
@@ -0,0 +1,135 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// PaymentRequestHandler serves the endpoints through which users request
+// money from each other.
+type PaymentRequestHandler struct {
+	uc *usecase.PaymentRequestUsecase
+}
+
+func NewPaymentRequestHandler(uc *usecase.PaymentRequestUsecase) *PaymentRequestHandler {
+	return &PaymentRequestHandler{uc: uc}
+}
+
+// paymentRequestAnswer is the body of the accept and decline endpoints,
+// naming the payer answering the request.
+type paymentRequestAnswer struct {
+	PayerID string `json:"payer_id"`
+}
+
+func (h *PaymentRequestHandler) CreatePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	var req usecase.CreatePaymentRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.uc.CreatePaymentRequest(r.Context(), req)
+	if err != nil {
+		respondWithPaymentRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, resp.PaymentRequestID))
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// ListPaymentRequests lists the requests made by or to the user_id of the
+// query, newest first.
+func (h *PaymentRequestHandler) ListPaymentRequests(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "limit must be a positive integer")
+			return
+		}
+	}
+
+	resp, err := h.uc.ListPaymentRequests(r.Context(), r.URL.Query().Get("user_id"), limit)
+	if err != nil {
+		respondWithPaymentRequestError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *PaymentRequestHandler) GetPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.uc.GetPaymentRequest(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithPaymentRequestError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *PaymentRequestHandler) AcceptPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	h.answer(w, r, h.uc.AcceptPaymentRequest)
+}
+
+func (h *PaymentRequestHandler) DeclinePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	h.answer(w, r, h.uc.DeclinePaymentRequest)
+}
+
+// answer answers with the payment request that call returns for the id of
+// the route and the payer of the body.
+func (h *PaymentRequestHandler) answer(w http.ResponseWriter, r *http.Request, call func(context.Context, string, string) (*usecase.PaymentRequestResponse, error)) {
+	var req paymentRequestAnswer
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if req.PayerID == "" {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "payer ID is required")
+		return
+	}
+
+	resp, err := call(r.Context(), chi.URLParam(r, "id"), req.PayerID)
+	if err != nil {
+		respondWithPaymentRequestError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// respondWithPaymentRequestError answers with the status of a usecase error
+// of the payment request endpoints, including the errors of the transfer
+// made by accepting a request. Unexpected errors are not described to the
+// client.
+func respondWithPaymentRequestError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrInvalidPaymentRequest), errors.Is(err, usecase.ErrInvalidAmount),
+		errors.Is(err, usecase.ErrSameUser), errors.Is(err, usecase.ErrInsufficientBalance):
+		status = http.StatusBadRequest
+	case errors.Is(err, usecase.ErrNotPaymentRequestPayer):
+		status = http.StatusForbidden
+	case errors.Is(err, usecase.ErrPaymentRequestState), errors.Is(err, usecase.ErrReferenceExists),
+		errors.Is(err, usecase.ErrWalletFrozen):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrPaymentRequestNotFound), errors.Is(err, domain.ErrWalletNotFound):
+		status = http.StatusNotFound
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
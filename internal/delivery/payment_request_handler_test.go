@@ -0,0 +1,118 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newPaymentRequestRouter(t *testing.T) (http.Handler, *usecase.PaymentUsecase) {
+	t.Helper()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := payments.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	h := NewPaymentRequestHandler(usecase.NewPaymentRequestUsecase(payments, repository.NewMemoryPaymentRequestRepo()))
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/payment-requests", h.CreatePaymentRequest)
+		r.Get("/payment-requests", h.ListPaymentRequests)
+		r.Get("/payment-requests/{id}", h.GetPaymentRequest)
+		r.Post("/payment-requests/{id}/accept", h.AcceptPaymentRequest)
+		r.Post("/payment-requests/{id}/decline", h.DeclinePaymentRequest)
+	})
+	return r, payments
+}
+
+func paymentRequestCall(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPaymentRequestHandler(t *testing.T) {
+	router, _ := newPaymentRequestRouter(t)
+
+	rec := paymentRequestCall(router, http.MethodPost, "/v1/payment-requests", `{"requester_id":"bob","payer_id":"alice","amount":40,"note":"lunch"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created usecase.PaymentRequestResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "pending", created.Status)
+	require.Equal(t, "/v1/payment-requests/"+created.PaymentRequestID, rec.Header().Get("Location"))
+	target := "/v1/payment-requests/" + created.PaymentRequestID
+
+	for _, step := range []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{}`, http.StatusBadRequest, "invalid_request"},
+		{`{"payer_id":"bob"}`, http.StatusForbidden, "not_payer"},
+		{`{"payer_id":"alice"}`, http.StatusOK, "accepted"},
+	} {
+		rec = paymentRequestCall(router, http.MethodPost, target+"/accept", step.body)
+		require.Equal(t, step.status, rec.Code, step.body)
+		require.Contains(t, rec.Body.String(), `"`+step.want+`"`, step.body)
+	}
+
+	rec = paymentRequestCall(router, http.MethodPost, target+"/decline", `{"payer_id":"alice"}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"invalid_payment_request_state"`)
+
+	for _, user := range []string{"alice", "bob"} {
+		rec = paymentRequestCall(router, http.MethodGet, "/v1/payment-requests?user_id="+user, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list []usecase.PaymentRequestResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list, 1, user)
+		require.Equal(t, "payment-request-"+created.PaymentRequestID, list[0].TransactionReference)
+	}
+
+	rec = paymentRequestCall(router, http.MethodGet, "/v1/payment-requests/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"payment_request_not_found"`)
+}
+
+func TestPaymentRequestHandler_Validation(t *testing.T) {
+	router, _ := newPaymentRequestRouter(t)
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+		code                 string
+	}{
+		{http.MethodPost, "/v1/payment-requests", `{"amount":`, http.StatusBadRequest, "invalid_request"},
+		{http.MethodPost, "/v1/payment-requests", `{"requester_id":"bob","payer_id":"alice","amount":0}`, http.StatusBadRequest, "invalid_amount"},
+		{http.MethodPost, "/v1/payment-requests", `{"requester_id":"bob","payer_id":"alice","amount":5,"expires_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, "invalid_payment_request"},
+		{http.MethodPost, "/v1/payment-requests", `{"requester_id":"bob","payer_id":"carol","amount":5}`, http.StatusNotFound, "wallet_not_found"},
+		{http.MethodGet, "/v1/payment-requests", "", http.StatusBadRequest, "invalid_payment_request"},
+		{http.MethodGet, "/v1/payment-requests?user_id=bob&limit=0", "", http.StatusBadRequest, "invalid_request"},
+	} {
+		rec := paymentRequestCall(router, tc.method, tc.target, tc.body)
+		require.Equal(t, tc.status, rec.Code, tc.target+" "+tc.body)
+		require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, tc.target+" "+tc.body)
+	}
+}
+
+func TestPaymentRequestHandler_ReferenceTaken(t *testing.T) {
+	router, payments := newPaymentRequestRouter(t)
+	rec := paymentRequestCall(router, http.MethodPost, "/v1/payment-requests", `{"requester_id":"bob","payer_id":"alice","amount":40}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created usecase.PaymentRequestResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	_, err := payments.TransferFunds(t.Context(), usecase.TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 5, Reference: "payment-request-" + created.PaymentRequestID})
+	require.NoError(t, err)
+
+	rec = paymentRequestCall(router, http.MethodPost, "/v1/payment-requests/"+created.PaymentRequestID+"/accept", `{"payer_id":"alice"}`)
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"code":"reference_exists"`)
+}
@@ -0,0 +1,118 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"payment-service/internal/domain"
+	"payment-service/internal/payout"
+	"payment-service/internal/usecase"
+	"strconv"
+	"strings"
+)
+
+// maxPayoutFileSize bounds the body of a payout file upload.
+const maxPayoutFileSize = 10 << 20
+
+// PayoutHandler serves bulk payout file uploads. The file is the request
+// body, a CSV or a JSON array depending on Content-Type; see
+// internal/payout for the formats.
+type PayoutHandler struct {
+	uc *usecase.PaymentUsecase
+}
+
+func NewPayoutHandler(uc *usecase.PaymentUsecase) *PayoutHandler {
+	return &PayoutHandler{uc: uc}
+}
+
+// payoutErrorResponse is the error response of a payout file with invalid
+// rows, listing all of them.
+type payoutErrorResponse struct {
+	Error  string                   `json:"error"`
+	Code   string                   `json:"code"`
+	Errors []usecase.PayoutRowError `json:"errors"`
+}
+
+// UploadPayout validates the payout file of the sender_id query parameter
+// and executes it. With dry_run=true only the summary is returned. The
+// results are CSV when the client accepts text/csv.
+func (h *PayoutHandler) UploadPayout(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	senderID := query.Get("sender_id")
+	if senderID == "" {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "sender_id is required")
+		return
+	}
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxPayoutFileSize)
+	rows, invalid, err := payout.Read(body, payout.FormatOf(r.Header.Get("Content-Type")))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithErrorCode(w, http.StatusRequestEntityTooLarge, codeInvalidRequest, "payout file is too large")
+			return
+		}
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	req := usecase.PayoutRequest{SenderID: senderID, Rows: rows, Invalid: invalid}
+
+	if dryRun {
+		summary, err := h.uc.PreviewPayout(r.Context(), req)
+		if err != nil {
+			respondWithPayoutError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, summary)
+		return
+	}
+
+	result, err := h.uc.ExecutePayout(r.Context(), req)
+	if err != nil {
+		respondWithPayoutError(w, err)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="payout-results.csv"`)
+		w.WriteHeader(http.StatusOK)
+		payout.WriteResultsCSV(w, result.Results)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// respondWithPayoutError answers with the status of a usecase error of the
+// payout endpoint. Invalid rows are listed with 422 Unprocessable Entity.
+func respondWithPayoutError(w http.ResponseWriter, err error) {
+	var invalid *usecase.InvalidPayoutError
+	if errors.As(err, &invalid) {
+		respondWithJSON(w, http.StatusUnprocessableEntity, payoutErrorResponse{
+			Error:  usecase.ErrInvalidPayout.Error(),
+			Code:   usecase.ErrorCode(err),
+			Errors: invalid.Summary.Errors,
+		})
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrInvalidPayout), errors.Is(err, usecase.ErrEmptyPayout):
+		status = http.StatusBadRequest
+	case errors.Is(err, domain.ErrWalletNotFound):
+		status = http.StatusNotFound
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newPayoutRouter(t *testing.T) http.Handler {
+	t.Helper()
+	uc := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob", "carol"} {
+		_, err := uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	h := NewPayoutHandler(uc)
+
+	r := chi.NewRouter()
+	r.Post("/v1/payouts", h.UploadPayout)
+	return r
+}
+
+func payoutRequest(router http.Handler, target, contentType, accept, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPayoutHandler(t *testing.T) {
+	router := newPayoutRouter(t)
+	file := "receiver,amount,reference,note\nbob,30,payroll-bob,October\ncarol,20,payroll-carol\n"
+
+	rec := payoutRequest(router, "/v1/payouts?sender_id=alice&dry_run=true", "text/csv", "", file)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.JSONEq(t, `{"sender_id":"alice","rows":2,"receivers":2,"total_amount":50,"already_paid":0,"sender_balance":100,"errors":[]}`, rec.Body.String())
+
+	rec = payoutRequest(router, "/v1/payouts?sender_id=alice", "text/csv", "text/csv", file)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, `attachment; filename="payout-results.csv"`, rec.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[1], "2,bob,30,payroll-bob,October,completed,"), lines[1])
+
+	rec = payoutRequest(router, "/v1/payouts?sender_id=alice", "application/json", "",
+		`[{"receiver_id":"bob","amount":10,"reference":"bonus-bob"}]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result usecase.PayoutResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, 1, result.Completed)
+	require.NotEmpty(t, result.Results[0].TransactionID)
+
+	rec = payoutRequest(router, "/v1/payouts?sender_id=alice", "text/csv", "", file)
+	require.Equal(t, http.StatusOK, rec.Code, "uploading a paid file again pays nothing")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, 2, result.AlreadyPaid)
+	require.Zero(t, result.Completed)
+	require.Equal(t, "already_paid", result.Results[0].Status)
+
+	rec = payoutRequest(router, "/v1/payouts?sender_id=alice", "text/csv", "",
+		"bob,30,payroll-carol\ncarol,50,payroll-dave\n")
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.JSONEq(t, `{"error":"payout file has invalid rows","code":"invalid_payout","errors":[
+		{"code":"insufficient_balance","message":"total amount 80 exceeds the sender balance 40"},
+		{"line":1,"code":"reference_exists","message":"reference payroll-carol has already been used"}
+	]}`, rec.Body.String())
+}
+
+func TestPayoutHandler_Errors(t *testing.T) {
+	router := newPayoutRouter(t)
+	for _, tc := range []struct {
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"/v1/payouts", "bob,1,r\n", http.StatusBadRequest, "invalid_request"},
+		{"/v1/payouts?sender_id=alice&dry_run=maybe", "bob,1,r\n", http.StatusBadRequest, "invalid_request"},
+		{"/v1/payouts?sender_id=alice", "", http.StatusBadRequest, "invalid_payout"},
+		{"/v1/payouts?sender_id=dave", "bob,1,r\n", http.StatusNotFound, "wallet_not_found"},
+	} {
+		rec := payoutRequest(router, tc.target, "text/csv", "", tc.body)
+		require.Equal(t, tc.status, rec.Code, tc.target)
+		require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, tc.target)
+	}
+}
@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"github.com/go-chi/chi"
+)
+
+// ScheduleHandler serves the scheduled transfer endpoints. The transfers are
+// made in the background by worker.ScheduleRunner.
+type ScheduleHandler struct {
+	uc *usecase.ScheduleUsecase
+}
+
+func NewScheduleHandler(uc *usecase.ScheduleUsecase) *ScheduleHandler {
+	return &ScheduleHandler{uc: uc}
+}
+
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req usecase.CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.uc.CreateSchedule(r.Context(), req)
+	if err != nil {
+		respondWithScheduleError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, resp.ScheduleID))
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, h.uc.GetSchedule)
+}
+
+func (h *ScheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, h.uc.PauseSchedule)
+}
+
+func (h *ScheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, h.uc.ResumeSchedule)
+}
+
+func (h *ScheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, h.uc.CancelSchedule)
+}
+
+// respond answers with the schedule that call returns for the id of the
+// route.
+func (h *ScheduleHandler) respond(w http.ResponseWriter, r *http.Request, call func(context.Context, string) (*usecase.ScheduleResponse, error)) {
+	resp, err := call(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithScheduleError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// respondWithScheduleError answers with the status of a usecase error of the
+// schedule endpoints. Unexpected errors are not described to the client.
+func respondWithScheduleError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrInvalidSchedule), errors.Is(err, usecase.ErrInvalidScheduleReference),
+		errors.Is(err, usecase.ErrInvalidAmount), errors.Is(err, usecase.ErrSameUser):
+		status = http.StatusBadRequest
+	case errors.Is(err, usecase.ErrScheduleReferenceExists), errors.Is(err, usecase.ErrScheduleState):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrScheduleNotFound), errors.Is(err, domain.ErrWalletNotFound):
+		status = http.StatusNotFound
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
@@ -0,0 +1,107 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newScheduleRouter(t *testing.T) (http.Handler, *usecase.ScheduleUsecase) {
+	t.Helper()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := payments.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	uc := usecase.NewScheduleUsecase(payments, repository.NewMemoryScheduleRepo(), usecase.DefaultSchedulePolicy)
+	h := NewScheduleHandler(uc)
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/schedules", h.CreateSchedule)
+		r.Get("/schedules/{id}", h.GetSchedule)
+		r.Post("/schedules/{id}/pause", h.PauseSchedule)
+		r.Post("/schedules/{id}/resume", h.ResumeSchedule)
+		r.Post("/schedules/{id}/cancel", h.CancelSchedule)
+	})
+	return r, uc
+}
+
+func scheduleRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestScheduleHandler(t *testing.T) {
+	router, _ := newScheduleRouter(t)
+	start := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	body := `{"reference":"allowance","sender_id":"alice","receiver_id":"bob","amount":10,"start_at":"` + start + `","recurrence":"FREQ=MONTHLY"}`
+
+	rec := scheduleRequest(router, http.MethodPost, "/v1/schedules", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created usecase.ScheduleResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "active", created.Status)
+	require.Equal(t, "retry", created.OnInsufficientBalance)
+	require.Equal(t, "/v1/schedules/"+created.ScheduleID, rec.Header().Get("Location"))
+
+	rec = scheduleRequest(router, http.MethodPost, "/v1/schedules", body)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.JSONEq(t, `{"error":"schedule reference already exists","code":"reference_exists"}`, rec.Body.String())
+
+	for _, step := range []struct {
+		action string
+		status int
+		want   string
+	}{
+		{"pause", http.StatusOK, "paused"},
+		{"pause", http.StatusConflict, "invalid_schedule_state"},
+		{"resume", http.StatusOK, "active"},
+		{"cancel", http.StatusOK, "cancelled"},
+		{"resume", http.StatusConflict, "invalid_schedule_state"},
+	} {
+		rec = scheduleRequest(router, http.MethodPost, "/v1/schedules/"+created.ScheduleID+"/"+step.action, "")
+		require.Equal(t, step.status, rec.Code, step.action)
+		require.Contains(t, rec.Body.String(), `"`+step.want+`"`, step.action)
+	}
+
+	rec = scheduleRequest(router, http.MethodGet, "/v1/schedules/"+created.ScheduleID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got usecase.ScheduleResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, "cancelled", got.Status)
+	require.Nil(t, got.NextRunAt)
+
+	rec = scheduleRequest(router, http.MethodGet, "/v1/schedules/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"schedule_not_found"`)
+}
+
+func TestScheduleHandler_Validation(t *testing.T) {
+	router, _ := newScheduleRouter(t)
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"reference":`, http.StatusBadRequest, "invalid_request"},
+		{`{"reference":"r","sender_id":"alice","receiver_id":"bob","amount":10,"recurrence":"FREQ=HOURLY"}`, http.StatusBadRequest, "invalid_schedule"},
+		{`{"reference":"r","sender_id":"alice","receiver_id":"bob","amount":10,"start_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, "invalid_schedule"},
+		{`{"reference":"r","sender_id":"alice","receiver_id":"alice","amount":10}`, http.StatusBadRequest, "same_user"},
+		{`{"reference":"r","sender_id":"alice","receiver_id":"carol","amount":10}`, http.StatusNotFound, "wallet_not_found"},
+	} {
+		rec := scheduleRequest(router, http.MethodPost, "/v1/schedules", tc.body)
+		require.Equal(t, tc.status, rec.Code, tc.body)
+		require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, tc.body)
+	}
+}
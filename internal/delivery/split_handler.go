@@ -0,0 +1,99 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"github.com/go-chi/chi"
+)
+
+// SplitHandler serves the endpoints through which a bill is split between
+// participants and collected into the organizer's wallet.
+type SplitHandler struct {
+	uc *usecase.SplitUsecase
+}
+
+func NewSplitHandler(uc *usecase.SplitUsecase) *SplitHandler {
+	return &SplitHandler{uc: uc}
+}
+
+// splitPayment is the body of the pay endpoint, naming the participant
+// paying their share.
+type splitPayment struct {
+	ParticipantID string `json:"participant_id"`
+}
+
+func (h *SplitHandler) CreateSplit(w http.ResponseWriter, r *http.Request) {
+	var req usecase.CreateSplitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.uc.CreateSplit(r.Context(), req)
+	if err != nil {
+		respondWithSplitError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, resp.SplitID))
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (h *SplitHandler) GetSplit(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.uc.GetSplit(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithSplitError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *SplitHandler) PayShare(w http.ResponseWriter, r *http.Request) {
+	var req splitPayment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body")
+		return
+	}
+	if req.ParticipantID == "" {
+		respondWithErrorCode(w, http.StatusBadRequest, codeInvalidRequest, "participant ID is required")
+		return
+	}
+
+	resp, err := h.uc.PayShare(r.Context(), chi.URLParam(r, "id"), req.ParticipantID)
+	if err != nil {
+		respondWithSplitError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// respondWithSplitError answers with the status of a usecase error of the
+// split endpoints, including the errors of the transfer paying a share.
+// Unexpected errors are not described to the client.
+func respondWithSplitError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrInvalidSplit), errors.Is(err, usecase.ErrInvalidAmount),
+		errors.Is(err, usecase.ErrInsufficientBalance):
+		status = http.StatusBadRequest
+	case errors.Is(err, usecase.ErrNotSplitParticipant):
+		status = http.StatusForbidden
+	case errors.Is(err, usecase.ErrWalletFrozen):
+		status = http.StatusConflict
+	case errors.Is(err, domain.ErrSplitNotFound), errors.Is(err, domain.ErrWalletNotFound):
+		status = http.StatusNotFound
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "internal error"
+	}
+	respondWithErrorCode(w, status, usecase.ErrorCode(err), message)
+}
@@ -0,0 +1,98 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func newSplitRouter(t *testing.T) http.Handler {
+	t.Helper()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob", "carol"} {
+		_, err := payments.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	h := NewSplitHandler(usecase.NewSplitUsecase(payments, repository.NewMemorySplitRepo()))
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/splits", h.CreateSplit)
+		r.Get("/splits/{id}", h.GetSplit)
+		r.Post("/splits/{id}/pay", h.PayShare)
+	})
+	return r
+}
+
+func splitRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSplitHandler(t *testing.T) {
+	router := newSplitRouter(t)
+
+	rec := splitRequest(router, http.MethodPost, "/v1/splits",
+		`{"organizer_id":"alice","description":"pizza","total":100,"participants":[{"participant_id":"alice"},{"participant_id":"bob"},{"participant_id":"carol"}]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created usecase.SplitResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "/v1/splits/"+created.SplitID, rec.Header().Get("Location"))
+	require.Equal(t, int64(34), created.Collected)
+	require.Equal(t, int64(66), created.Outstanding)
+	target := "/v1/splits/" + created.SplitID
+
+	for _, step := range []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{}`, http.StatusBadRequest, "invalid_request"},
+		{`{"participant_id":"dave"}`, http.StatusForbidden, "not_participant"},
+		{`{"participant_id":"bob"}`, http.StatusOK, `"status":"open"`},
+		{`{"participant_id":"carol"}`, http.StatusOK, `"status":"settled"`},
+	} {
+		rec = splitRequest(router, http.MethodPost, target+"/pay", step.body)
+		require.Equal(t, step.status, rec.Code, step.body)
+		require.Contains(t, rec.Body.String(), step.want, step.body)
+	}
+
+	rec = splitRequest(router, http.MethodGet, target, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got usecase.SplitResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, int64(100), got.Collected)
+	require.Zero(t, got.Outstanding)
+
+	rec = splitRequest(router, http.MethodGet, "/v1/splits/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"split_not_found"`)
+}
+
+func TestSplitHandler_Validation(t *testing.T) {
+	router := newSplitRouter(t)
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"organizer_id":`, http.StatusBadRequest, "invalid_request"},
+		{`{"organizer_id":"alice","total":100,"participants":[]}`, http.StatusBadRequest, "invalid_split"},
+		{`{"organizer_id":"alice","total":100,"method":"percentage","participants":[{"participant_id":"bob","percentage":60},{"participant_id":"carol","percentage":60}]}`, http.StatusBadRequest, "invalid_split"},
+		{`{"organizer_id":"alice","method":"amount","participants":[{"participant_id":"bob","amount":-5}]}`, http.StatusBadRequest, "invalid_amount"},
+		{`{"organizer_id":"erin","total":100,"participants":[{"participant_id":"bob"}]}`, http.StatusNotFound, "wallet_not_found"},
+	} {
+		rec := splitRequest(router, http.MethodPost, "/v1/splits", tc.body)
+		require.Equal(t, tc.status, rec.Code, tc.body)
+		require.Contains(t, rec.Body.String(), `"code":"`+tc.code+`"`, tc.body)
+	}
+}
@@ -0,0 +1,26 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecated marks the responses of legacy routes with the Deprecation header
+// of RFC 9745 and the Sunset header of RFC 8594, and links to the same path
+// under successorPrefix, e.g. /v1, as the successor version. A zero sunset
+// omits the Sunset header.
+func Deprecated(since, sunset time.Time, successorPrefix string) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", deprecation)
+			if !sunset.IsZero() {
+				h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			h.Add("Link", "<"+successorPrefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
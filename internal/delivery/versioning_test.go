@@ -0,0 +1,27 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecated(t *testing.T) {
+	since := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	Deprecated(since, sunset, "/v1")(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wallet/alice%20b", nil))
+	require.Equal(t, "@1792281600", rec.Header().Get("Deprecation"))
+	require.Equal(t, "Sun, 18 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+	require.Equal(t, `</v1/wallet/alice%20b>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = httptest.NewRecorder()
+	Deprecated(since, time.Time{}, "/v1")(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transfer", nil))
+	require.NotEmpty(t, rec.Header().Get("Deprecation"))
+	require.NotContains(t, rec.Header(), "Sunset")
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Batch modes. An all-or-nothing batch moves every item in one database
+// transaction, or none of them if any item fails. A best-effort batch
+// transfers the items one by one and lets each fail on its own.
+const (
+	BatchModeAllOrNothing = "all_or_nothing"
+	BatchModeBestEffort   = "best_effort"
+)
+
+// Batch statuses. A batch is pending until a worker claims it, processing
+// while its items are transferred and then completed, partially_completed
+// (best effort only) or failed.
+const (
+	BatchStatusPending            = "pending"
+	BatchStatusProcessing         = "processing"
+	BatchStatusCompleted          = "completed"
+	BatchStatusPartiallyCompleted = "partially_completed"
+	BatchStatusFailed             = "failed"
+)
+
+const (
+	BatchItemStatusPending   = "pending"
+	BatchItemStatusCompleted = "completed"
+	BatchItemStatusFailed    = "failed"
+)
+
+// BatchItem is one transfer of a batch from the batch's sender. Reference is
+// derived from the batch reference, so that processing an item twice cannot
+// move the money twice. Error is the error code of a failed item.
+type BatchItem struct {
+	ReceiverID    string
+	Amount        int64
+	Reference     string
+	Status        string
+	Error         string
+	TransactionID string
+}
+
+// Batch is a set of transfers from SenderID processed asynchronously.
+// Reference is unique among batches. Error is the error code that failed an
+// all-or-nothing batch.
+//
+// LockedUntil is set while a worker processes the batch. Version is
+// incremented by every update.
+type Batch struct {
+	ID          string
+	Reference   string
+	SenderID    string
+	Mode        string
+	Status      string
+	Error       string
+	Items       []BatchItem
+	LockedUntil time.Time
+	Version     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type BatchRepository interface {
+	// CreateBatch fails with ErrDuplicateBatchReference when another batch
+	// uses the same reference.
+	CreateBatch(ctx context.Context, batch *Batch) error
+	GetBatch(ctx context.Context, id string) (*Batch, error)
+	// ClaimBatch marks the oldest pending batch, or processing batch whose
+	// lock expired before now, as processing locked until lockUntil and
+	// returns it, or ErrBatchNotFound when there is none. A batch is claimed
+	// by one caller at a time.
+	ClaimBatch(ctx context.Context, now, lockUntil time.Time) (*Batch, error)
+	// UpdateBatch stores the status, error, items and lock of batch and
+	// increments its version. It fails with ErrBatchConflict when the
+	// batch's version changed since it was read.
+	UpdateBatch(ctx context.Context, batch *Batch) error
+}
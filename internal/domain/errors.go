@@ -0,0 +1,63 @@
+package domain
+
+import "errors"
+
+var (
+	ErrWalletNotFound         = errors.New("wallet not found")
+	ErrTransactionNotFound    = errors.New("transaction not found")
+	ErrReportNotFound         = errors.New("reconciliation report not found")
+	ErrBatchNotFound          = errors.New("batch not found")
+	ErrScheduleNotFound       = errors.New("schedule not found")
+	ErrPaymentRequestNotFound = errors.New("payment request not found")
+	ErrSplitNotFound          = errors.New("split not found")
+
+	// ErrMalformedID is returned by the Postgres repositories when an ID is
+	// not a valid UUID, so that no row can have it.
+	ErrMalformedID = errors.New("malformed id")
+
+	// ErrUserExists is returned by CreateWallet when the user ID or username
+	// is already taken.
+	ErrUserExists = errors.New("user already exists")
+
+	// ErrDuplicateReference is returned by CreateTransaction when another
+	// transaction already uses the same reference.
+	ErrDuplicateReference = errors.New("duplicate transaction reference")
+
+	// ErrDuplicateBatchReference is returned by CreateBatch when another
+	// batch already uses the same reference.
+	ErrDuplicateBatchReference = errors.New("duplicate batch reference")
+
+	// ErrDuplicateScheduleReference is returned by CreateSchedule when
+	// another schedule already uses the same reference.
+	ErrDuplicateScheduleReference = errors.New("duplicate schedule reference")
+
+	// ErrNegativeBalance is returned when a balance update would take a
+	// wallet below zero.
+	ErrNegativeBalance = errors.New("wallet balance cannot be negative")
+
+	// ErrTxConflict is returned by repositories when the database aborted a
+	// transaction because of a serialization failure or a deadlock. The whole
+	// transaction can safely be retried.
+	ErrTxConflict = errors.New("transaction conflict")
+
+	// ErrVersionConflict is returned by conditional wallet updates when the
+	// wallet version no longer matches the one the caller read.
+	ErrVersionConflict = errors.New("wallet version conflict")
+
+	// ErrBatchConflict is returned by UpdateBatch when the batch was changed
+	// since the caller read it, for example because its lock expired and
+	// another worker claimed it.
+	ErrBatchConflict = errors.New("batch version conflict")
+
+	// ErrScheduleConflict is returned by UpdateSchedule when the schedule
+	// was changed since the caller read it.
+	ErrScheduleConflict = errors.New("schedule version conflict")
+
+	// ErrPaymentRequestConflict is returned by UpdatePaymentRequest when the
+	// payment request was changed since the caller read it.
+	ErrPaymentRequestConflict = errors.New("payment request version conflict")
+
+	// ErrSplitConflict is returned by UpdateSplit when the split was changed
+	// since the caller read it.
+	ErrSplitConflict = errors.New("split version conflict")
+)
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Payment request statuses. A pending request is accepted or declined by its
+// payer, or expires at ExpiresAt. Expiry is not stored: a pending request
+// past its ExpiresAt is reported as expired.
+const (
+	PaymentRequestStatusPending  = "pending"
+	PaymentRequestStatusAccepted = "accepted"
+	PaymentRequestStatusDeclined = "declined"
+	PaymentRequestStatusExpired  = "expired"
+)
+
+// PaymentRequest asks PayerID to transfer Amount to RequesterID. Accepting
+// it transfers the money under a reference derived from ID, so that the
+// transfer is made at most once. Version is incremented by every update.
+type PaymentRequest struct {
+	ID          string
+	RequesterID string
+	PayerID     string
+	Amount      int64
+	Note        string
+	Status      string
+	ExpiresAt   time.Time
+	Version     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type PaymentRequestRepository interface {
+	CreatePaymentRequest(ctx context.Context, request *PaymentRequest) error
+	GetPaymentRequest(ctx context.Context, id string) (*PaymentRequest, error)
+	// ListPaymentRequests returns at most limit requests made by or to
+	// userID, newest first. A limit of 0 returns all of them.
+	ListPaymentRequests(ctx context.Context, userID string, limit int) ([]PaymentRequest, error)
+	// UpdatePaymentRequest stores request if its Version is still the stored
+	// one and increments the Version. It fails with
+	// ErrPaymentRequestConflict otherwise.
+	UpdatePaymentRequest(ctx context.Context, request *PaymentRequest) error
+}
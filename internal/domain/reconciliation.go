@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ReconciliationTriggerScheduled = "scheduled"
+	ReconciliationTriggerManual    = "manual"
+)
+
+const (
+	ReconciliationStatusOK       = "ok"
+	ReconciliationStatusMismatch = "mismatch"
+)
+
+// LedgerMismatch is a wallet whose balance differs from the balance
+// recomputed from its transactions.
+type LedgerMismatch struct {
+	WalletID string
+	UserID   string
+	Balance  int64
+	Expected int64
+}
+
+// ReconciliationReport is the stored result of a reconciliation run.
+// ExpectedTotal is the money that entered minus the money that left the
+// system; it must equal TotalBalance.
+type ReconciliationReport struct {
+	ID             string
+	Trigger        string
+	Status         string
+	WalletsChecked int
+	TotalBalance   int64
+	ExpectedTotal  int64
+	Mismatches     []LedgerMismatch
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+type ReconciliationRepository interface {
+	CreateReport(ctx context.Context, report *ReconciliationReport) error
+	GetReport(ctx context.Context, id string) (*ReconciliationReport, error)
+	// ListReports returns the most recent reports first.
+	ListReports(ctx context.Context, limit int) ([]ReconciliationReport, error)
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Schedule statuses. An active schedule runs its occurrences until it is
+// paused, cancelled or has none left, when it is completed.
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusPaused    = "paused"
+	ScheduleStatusCancelled = "cancelled"
+	ScheduleStatusCompleted = "completed"
+)
+
+// What a schedule does when the sender cannot cover an occurrence: retry it
+// later or skip to the next occurrence.
+const (
+	ScheduleRetry = "retry"
+	ScheduleSkip  = "skip"
+)
+
+// Schedule is a future-dated or recurring transfer from SenderID to
+// ReceiverID. Recurrence is a recurrence.Rule in canonical form, empty for a
+// one-off transfer at StartAt.
+//
+// Occurrence is the number of the next occurrence, counting from 1, and
+// NextRunAt is when it is due, or when it is retried after Attempts failed
+// attempts. Occurrence n is paid with the reference "<Reference>-<n>", so
+// that running it twice cannot move the money twice. Completed and Skipped
+// count the occurrences paid and given up; LastError is the error code of
+// the last failed attempt.
+//
+// LockedUntil is set while a worker runs the schedule. Version is incremented
+// by every update.
+type Schedule struct {
+	ID                    string
+	Reference             string
+	SenderID              string
+	ReceiverID            string
+	Amount                int64
+	Recurrence            string
+	StartAt               time.Time
+	OnInsufficientBalance string
+	Status                string
+	Occurrence            int
+	NextRunAt             time.Time
+	Attempts              int
+	Completed             int
+	Skipped               int
+	LastError             string
+	LockedUntil           time.Time
+	Version               int
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+type ScheduleRepository interface {
+	// CreateSchedule fails with ErrDuplicateScheduleReference when another
+	// schedule uses the same reference.
+	CreateSchedule(ctx context.Context, schedule *Schedule) error
+	GetSchedule(ctx context.Context, id string) (*Schedule, error)
+	// ClaimDueSchedule locks the active schedule that has been due the
+	// longest at now until lockUntil and returns it, or ErrScheduleNotFound
+	// when none is due. A locked schedule is not claimed again before its
+	// lock expires.
+	ClaimDueSchedule(ctx context.Context, now, lockUntil time.Time) (*Schedule, error)
+	// UpdateSchedule stores schedule if its Version is still the stored one
+	// and increments the Version. It fails with ErrScheduleConflict
+	// otherwise.
+	UpdateSchedule(ctx context.Context, schedule *Schedule) error
+}
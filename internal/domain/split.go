@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// How the total of a split is divided: in equal shares, by the amount of
+// each participant or by their percentage of the total.
+const (
+	SplitMethodEqual      = "equal"
+	SplitMethodAmount     = "amount"
+	SplitMethodPercentage = "percentage"
+)
+
+// Split statuses. A split is open until every share is paid, when it is
+// settled.
+const (
+	SplitStatusOpen    = "open"
+	SplitStatusSettled = "settled"
+)
+
+const (
+	SplitShareStatusPending = "pending"
+	SplitShareStatusPaid    = "paid"
+)
+
+// SplitShare is what ParticipantID owes the organizer of a split. Reference
+// is derived from the split ID, so that paying the share twice cannot move
+// the money twice; it is empty for the share of the organizer, which is
+// paid from the start.
+type SplitShare struct {
+	ParticipantID string
+	Amount        int64
+	Reference     string
+	Status        string
+}
+
+// Split is a bill of Total that OrganizerID collects from the participants
+// of Shares into their wallet. Version is incremented by every update.
+type Split struct {
+	ID          string
+	OrganizerID string
+	Description string
+	Total       int64
+	Method      string
+	Status      string
+	Shares      []SplitShare
+	Version     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type SplitRepository interface {
+	CreateSplit(ctx context.Context, split *Split) error
+	GetSplit(ctx context.Context, id string) (*Split, error)
+	// UpdateSplit stores the status and shares of split if its Version is
+	// still the stored one and increments the Version. It fails with
+	// ErrSplitConflict otherwise.
+	UpdateSplit(ctx context.Context, split *Split) error
+}
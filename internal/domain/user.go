@@ -0,0 +1,6 @@
+package domain
+
+type User struct {
+	ID       string
+	Username string
+}
@@ -1,19 +1,42 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// Wallet statuses. Frozen wallets cannot send or receive transfers or be
+// topped up.
+const (
+	WalletStatusActive = "active"
+	WalletStatusFrozen = "frozen"
+)
 
 type Wallet struct {
 	ID        string
 	UserID    string
 	Balance   int64
 	Version   int
+	Status    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Transaction types. Every balance change is recorded as a transaction: a
+// transfer moves money from SenderID to ReceiverID, while the other types
+// have only one side and leave SenderID (credit) or ReceiverID (debit) empty.
+const (
+	TransactionTypeTransfer   = "transfer"
+	TransactionTypeTopUp      = "topup"
+	TransactionTypeAdjustment = "adjustment"
+	TransactionTypeRefund     = "refund"
+	TransactionTypeOpening    = "opening"
+)
+
 type Transaction struct {
 	ID         string
 	Reference  string
+	Type       string
 	SenderID   string
 	ReceiverID string
 	Amount     int64
@@ -21,14 +44,46 @@ type Transaction struct {
 	CreatedAt  time.Time
 }
 
+// TransactionFilter selects transactions for ListTransactions. Empty fields
+// match everything. Results are ordered newest first.
+type TransactionFilter struct {
+	UserID string // sender or receiver
+	Type   string
+	Limit  int
+}
+
+// WalletLedger is a wallet's balance next to the sums of the completed
+// transactions crediting and debiting it.
+type WalletLedger struct {
+	WalletID string
+	UserID   string
+	Balance  int64
+	Credits  int64
+	Debits   int64
+}
+
+// Ledger is a consistent snapshot of all wallets. Credits and Debits sum the
+// completed one-sided transactions, i.e. the money that entered and left the
+// system; transfers and refunds only move money between wallets.
+type Ledger struct {
+	Wallets []WalletLedger
+	Credits int64
+	Debits  int64
+}
+
 type TransactionRepository interface {
-	GetWalletForUpdate(tx interface{}, userID string) (*Wallet, error)
-	UpdateWalletBalance(tx interface{}, walletID string, amount int64) error
-	CreateTransaction(tx interface{}, transaction *Transaction) error
-	GetTransactionByRef(refID string) (*Transaction, error)
-	BeginTx() (interface{}, error)
+	GetWalletForUpdate(ctx context.Context, tx interface{}, userID string) (*Wallet, error)
+	UpdateWalletBalance(ctx context.Context, tx interface{}, walletID string, amount int64) error
+	UpdateWalletBalanceIfVersion(ctx context.Context, tx interface{}, walletID string, amount int64, expectedVersion int) error
+	CreateTransaction(ctx context.Context, tx interface{}, transaction *Transaction) error
+	GetTransactionByRef(ctx context.Context, refID string) (*Transaction, error)
+	BeginTx(ctx context.Context) (interface{}, error)
 	CommitTx(tx interface{}) error
 	RollbackTx(tx interface{}) error
-	TopUpWallet(tx interface{}, userID string, amount int64) error
-	GetWalletByUserID(userID string) (*Wallet, error)
+	TopUpWallet(ctx context.Context, tx interface{}, userID string, amount int64) error
+	GetWalletByUserID(ctx context.Context, userID string) (*Wallet, error)
+	CreateWallet(ctx context.Context, tx interface{}, user *User, wallet *Wallet) error
+	SetWalletStatus(ctx context.Context, tx interface{}, walletID string, status string) error
+	ListTransactions(ctx context.Context, filter TransactionFilter) ([]Transaction, error)
+	GetLedger(ctx context.Context) (*Ledger, error)
 }
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-service/internal/config"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		accepted bool
+	}{
+		{"supplied", "req-123", true},
+		{"missing", "", false},
+		{"control characters", "req\n123", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.NotEmpty(t, seen)
+			require.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+			if tt.accepted {
+				require.Equal(t, tt.header, seen)
+			} else {
+				require.NotEqual(t, tt.header, seen)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, config.Log{Level: "info", Format: "json"})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(AccessLog(logger))
+	r.Get("/wallet/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "handled")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/wallet/alice-id", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotContains(t, out.String(), "alice-id")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var handled, access map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &handled))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	require.Equal(t, "req-1", handled["request_id"])
+	require.Equal(t, "request", access["msg"])
+	require.Equal(t, "req-1", access["request_id"])
+	require.Equal(t, "GET", access["method"])
+	require.Equal(t, "/wallet/{userId}", access["route"])
+	require.EqualValues(t, http.StatusNotFound, access["status"])
+	require.Contains(t, access, "duration_ms")
+}
+
+func TestNew_Level(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, config.Log{Level: "warn", Format: "text"})
+	require.NoError(t, err)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	require.NotContains(t, out.String(), "hidden")
+	require.Contains(t, out.String(), "level=WARN msg=shown")
+
+	_, err = New(&out, config.Log{Level: "info", Format: "xml"})
+	require.Error(t, err)
+}
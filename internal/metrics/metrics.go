@@ -0,0 +1,181 @@
+// Package metrics exposes Prometheus metrics for the HTTP API, the payment
+// usecase, the background workers and the database pool.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"payment-service/internal/worker"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "payment"
+
+// Metrics owns a registry with the Go runtime and process collectors and the
+// service's own metrics. It implements usecase.Metrics and worker.Metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	transfers      *prometheus.CounterVec
+	transferAmount prometheus.Counter
+	topUps         *prometheus.CounterVec
+	topUpAmount    prometheus.Counter
+	retries        *prometheus.CounterVec
+	lockWait       prometheus.Histogram
+
+	workerRuns *prometheus.CounterVec
+}
+
+var (
+	_ usecase.Metrics = (*Metrics)(nil)
+	_ worker.Metrics  = (*Metrics)(nil)
+)
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, chi route pattern and status code.",
+		}, []string{"method", "route", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and chi route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Transfers by status (completed or failed) and error code.",
+		}, []string{"status", "code"}),
+		transferAmount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_amount_total",
+			Help:      "Amount moved by completed transfers, in minor units.",
+		}),
+		topUps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "topups_total",
+			Help:      "Top ups by status (completed or failed) and error code.",
+		}, []string{"status", "code"}),
+		topUpAmount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "topup_amount_total",
+			Help:      "Amount added by completed top ups, in minor units.",
+		}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tx_retries_total",
+			Help:      "Transactions retried after a conflict, by reason (tx_conflict or version_conflict).",
+		}, []string{"reason"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "wallet_lock_wait_seconds",
+			Help:      "Time spent acquiring wallet row locks.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}),
+		workerRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "worker_runs_total",
+			Help:      "Runs of the background workers that did work, by worker and status (completed or failed).",
+		}, []string{"worker", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.transfers, m.transferAmount, m.topUps, m.topUpAmount,
+		m.retries, m.lockWait,
+		m.workerRuns,
+	)
+	return m
+}
+
+// RegisterDB exports the pool statistics of db (sql.DB.Stats) as
+// go_sql_* metrics labelled db_name="payment".
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records every request under its chi route pattern, e.g.
+// "/wallet/{userId}", so that path parameters do not create new series.
+// Requests that match no route are recorded as "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *Metrics) TransferFinished(amount int64, err error) {
+	m.transfers.WithLabelValues(status(err), usecase.ErrorCode(err)).Inc()
+	if err == nil {
+		m.transferAmount.Add(float64(amount))
+	}
+}
+
+func (m *Metrics) TopUpFinished(amount int64, err error) {
+	m.topUps.WithLabelValues(status(err), usecase.ErrorCode(err)).Inc()
+	if err == nil {
+		m.topUpAmount.Add(float64(amount))
+	}
+}
+
+func (m *Metrics) Retried(err error) {
+	reason := "tx_conflict"
+	if errors.Is(err, domain.ErrVersionConflict) {
+		reason = "version_conflict"
+	}
+	m.retries.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) LockWaited(d time.Duration) {
+	m.lockWait.Observe(d.Seconds())
+}
+
+// WorkerRan labels the run with the worker's name in the form of its
+// readiness check, e.g. "batch_processor".
+func (m *Metrics) WorkerRan(name string, err error) {
+	m.workerRuns.WithLabelValues(strings.ReplaceAll(name, " ", "_"), status(err)).Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "completed"
+}
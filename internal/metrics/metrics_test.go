@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	m := New()
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/wallet/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/wallet/a", "/wallet/b", "/ok", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/wallet/{userId}", "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/ok", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	require.Equal(t, 3, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestUsecaseMetrics(t *testing.T) {
+	ctx := t.Context()
+	m := New()
+	uc := usecase.NewPaymentUsecase(repository.NewMemoryRepo(), usecase.WithMetrics(m))
+	alice, err := uc.CreateWallet(ctx, usecase.CreateWalletRequest{Username: "alice", Balance: 1000})
+	require.NoError(t, err)
+	bob, err := uc.CreateWallet(ctx, usecase.CreateWalletRequest{Username: "bob"})
+	require.NoError(t, err)
+
+	_, err = uc.TransferFunds(ctx, usecase.TransferRequest{SenderID: alice.UserID, ReceiverID: bob.UserID, Amount: 300, Reference: "ref-1"})
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, usecase.TransferRequest{SenderID: bob.UserID, ReceiverID: alice.UserID, Amount: 5000, Reference: "ref-2"})
+	require.ErrorIs(t, err, usecase.ErrInsufficientBalance)
+	_, err = uc.TopUpWallet(ctx, usecase.TopUpRequest{UserID: bob.UserID, Amount: 50})
+	require.NoError(t, err)
+	m.Retried(domain.ErrVersionConflict)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.transfers.WithLabelValues("completed", "ok")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.transfers.WithLabelValues("failed", "insufficient_balance")))
+	require.Equal(t, 300.0, testutil.ToFloat64(m.transferAmount))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.topUps.WithLabelValues("completed", "ok")))
+	require.Equal(t, 50.0, testutil.ToFloat64(m.topUpAmount))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.retries.WithLabelValues("version_conflict")))
+	require.Equal(t, 1, testutil.CollectAndCount(m.lockWait))
+}
+
+func TestWorkerRan(t *testing.T) {
+	m := New()
+	m.WorkerRan("batch processor", nil)
+	m.WorkerRan("batch processor", errors.New("database is down"))
+	m.WorkerRan("reconciler", nil)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.workerRuns.WithLabelValues("batch_processor", "completed")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.workerRuns.WithLabelValues("batch_processor", "failed")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.workerRuns.WithLabelValues("reconciler", "completed")))
+}
+
+func TestHandler_ExposesDBStats(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	m := New()
+	m.RegisterDB(db)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(body), `go_sql_max_open_connections{db_name="payment"}`))
+	require.True(t, strings.Contains(string(body), "go_goroutines"))
+}
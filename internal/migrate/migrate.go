@@ -0,0 +1,375 @@
+// Package migrate applies the versioned SQL migrations in the migrations
+// directory and records them in a schema_migrations table.
+//
+// Migration files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Each migration runs in its own transaction
+// together with the bookkeeping row, so a failed migration leaves no trace.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// advisoryLockID identifies the Postgres advisory lock held while migrating,
+// so that concurrently starting instances apply migrations one at a time.
+const advisoryLockID int64 = 7265246341
+
+const createVersionTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// initialisedTable is created by the first migration. A database that has it
+// but no applied migrations was initialised from the migrations directory
+// before schema_migrations existed.
+const initialisedTable = "users"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations found in fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if dialect != Postgres && dialect != SQLite {
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Load reads the migrations in the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, name := range names {
+		match := fileNamePattern.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", name, err)
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every migration that has not been applied yet, in version order,
+// and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.adoptInitialised(ctx, conn); err != nil {
+			return fmt.Errorf("failed to adopt existing schema: %w", err)
+		}
+		for _, migration := range m.migrations {
+			ok, err := m.up(ctx, conn, migration)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if ok {
+				applied = append(applied, migration)
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, at most steps of them,
+// and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+			if !statuses[i].Applied {
+				continue
+			}
+			migration := statuses[i].Migration
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			ok, err := m.down(ctx, conn, migration)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if ok {
+				reverted = append(reverted, migration)
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	exists, err := m.versionTableExists(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		statuses := make([]Status, len(m.migrations))
+		for i, migration := range m.migrations {
+			statuses[i] = Status{Migration: migration}
+		}
+		return statuses, nil
+	}
+	return m.status(ctx, conn)
+}
+
+// Version returns the highest applied migration version, or 0 if none has
+// been applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	exists, err := m.versionTableExists(ctx, conn)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// withLock runs fn on a dedicated connection while holding the migration
+// lock. On Postgres this is a session advisory lock; SQLite transactions are
+// already serialized by BEGIN IMMEDIATE, and up/down re-check the version
+// table inside their transaction.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, createVersionTableSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// adoptInitialised records the first migration as applied, without running
+// it, on databases that were initialised from it before schema_migrations
+// existed: docker-compose used to pass the migrations directory to the
+// Postgres image's docker-entrypoint-initdb.d. Running it again would insert
+// its demo rows twice.
+func (m *Migrator) adoptInitialised(ctx context.Context, conn *sql.Conn) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	exists, err := m.tableExists(ctx, tx, initialisedTable)
+	if err != nil || !exists {
+		return err
+	}
+	first := m.migrations[0]
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		first.Version, first.Name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) up(ctx context.Context, conn *sql.Conn, migration Migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, migration.Version).Scan(&count)
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		migration.Version, migration.Name, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (m *Migrator) down(ctx context.Context, conn *sql.Conn, migration Migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		// Reverted concurrently by another runner.
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		at, ok := appliedAt[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: at}
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) versionTableExists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	return m.tableExists(ctx, conn, "schema_migrations")
+}
+
+// queryRower is implemented by *sql.Conn and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (m *Migrator) tableExists(ctx context.Context, q queryRower, name string) (bool, error) {
+	var exists bool
+	var err error
+	switch m.dialect {
+	case Postgres:
+		err = q.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists)
+	case SQLite:
+		err = q.QueryRowContext(ctx, `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = $1`, name).Scan(&exists)
+	}
+	return exists, err
+}
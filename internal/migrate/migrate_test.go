@@ -0,0 +1,303 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"payment-service/migrations"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "migrate.db")+"?_txlock=immediate&_pragma=foreign_keys(1)")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int
+		wantErr string
+	}{
+		{
+			name: "Sorted by version",
+			fsys: fstest.MapFS{
+				"0000010_b.up.sql":   {Data: []byte("SELECT 1")},
+				"0000002_a.up.sql":   {Data: []byte("SELECT 1")},
+				"0000002_a.down.sql": {Data: []byte("SELECT 1")},
+			},
+			want: []int{2, 10},
+		},
+		{
+			name:    "Invalid name",
+			fsys:    fstest.MapFS{"init.sql": {Data: []byte("SELECT 1")}},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "Missing up file",
+			fsys:    fstest.MapFS{"0000001_a.down.sql": {Data: []byte("SELECT 1")}},
+			wantErr: "has no up file",
+		},
+		{
+			name: "Conflicting names",
+			fsys: fstest.MapFS{
+				"0000001_a.up.sql":   {Data: []byte("SELECT 1")},
+				"0000001_b.down.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var versions []int
+			for _, m := range got {
+				versions = append(versions, m.Version)
+			}
+			require.Equal(t, tt.want, versions)
+		})
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, fstest.MapFS{
+		"0000001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"0000001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0000002_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"0000002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	require.NoError(t, err)
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+
+	// A second run is a no-op.
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	version, err = m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, 2, reverted[0].Version)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Applied)
+	require.False(t, statuses[0].AppliedAt.IsZero())
+	require.False(t, statuses[1].Applied)
+
+	_, err = db.Exec(`SELECT * FROM b`)
+	require.Error(t, err)
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, fstest.MapFS{
+		"0000001_a.up.sql": {Data: []byte("CREATE TABLE a (id INT); INSERT INTO missing VALUES (1);")},
+	})
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx)
+	require.ErrorContains(t, err, "migration 1_a")
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+	_, err = db.Exec(`SELECT * FROM a`)
+	require.Error(t, err)
+}
+
+// TestMigrator_EmbeddedMigrations runs the real migration set all the way up,
+// down and up again on SQLite.
+func TestMigrator_EmbeddedMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, migrations.FS)
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, m.Latest())
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), version)
+}
+
+// TestMigrator_BackfillsOpeningBalances checks that migration 3 records
+// balances that are not explained by transfers as opening transactions.
+func TestMigrator_BackfillsOpeningBalances(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, m.Latest()-2)
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`INSERT INTO users (id, username) VALUES ('u1', 'alice'), ('u2', 'bob'), ('u3', 'carol')`,
+		`INSERT INTO wallets (id, user_id, balance) VALUES ('w1', 'u1', 700), ('w2', 'u2', 300), ('w3', 'u3', 0)`,
+		`INSERT INTO transactions (id, reference_id, sender_id, receiver_id, amount, status) VALUES ('t1', 'ref-1', 'u1', 'u2', 300, 'completed')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	rows, err := db.Query(`SELECT receiver_id, amount FROM transactions WHERE type = 'opening' ORDER BY receiver_id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	openings := make(map[string]int64)
+	for rows.Next() {
+		var userID string
+		var amount int64
+		require.NoError(t, rows.Scan(&userID, &amount))
+		openings[userID] = amount
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, map[string]int64{"u1": 1000}, openings)
+}
+
+// TestMigrator_KeepsLedgerHistory checks that reverting migration 3 fails
+// instead of deleting transactions the previous schema cannot hold.
+func TestMigrator_KeepsLedgerHistory(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, m.Latest()-3)
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`INSERT INTO users (id, username) VALUES ('u1', 'alice')`,
+		`INSERT INTO wallets (id, user_id, balance) VALUES ('w1', 'u1', 500)`,
+		`INSERT INTO transactions (id, reference_id, type, receiver_id, amount, status) VALUES ('t1', 'topup-1', 'topup', 'u1', 500, 'completed')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	_, err = m.Down(ctx, 1)
+	require.ErrorContains(t, err, "transactions_other_than_transfers_must_be_removed_first")
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, version)
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&count))
+	require.Equal(t, 1, count, "the top up is kept")
+
+	_, err = db.Exec(`DELETE FROM transactions WHERE id = 't1'`)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+}
+
+// TestMigrator_RemovesUntouchedDemoData checks that migration 10 removes the
+// demo users of the original init migration unless they were used.
+func TestMigrator_RemovesUntouchedDemoData(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		`INSERT INTO users (id, username) VALUES
+			('11111111-1111-1111-1111-111111111111', 'alice'),
+			('22222222-2222-2222-2222-222222222222', 'bob'),
+			('u3', 'carol')`,
+		`INSERT INTO wallets (id, user_id, balance) VALUES
+			('aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa', '11111111-1111-1111-1111-111111111111', 1000000),
+			('bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb', '22222222-2222-2222-2222-222222222222', 49000),
+			('w3', 'u3', 1000)`,
+		`INSERT INTO transactions (id, reference_id, type, sender_id, receiver_id, amount, status) VALUES
+			('t1', 'OPENING-aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa', 'opening', NULL, '11111111-1111-1111-1111-111111111111', 1000000, 'completed'),
+			('t2', 'OPENING-bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb', 'opening', NULL, '22222222-2222-2222-2222-222222222222', 50000, 'completed'),
+			('t3', 'ref-1', 'transfer', '22222222-2222-2222-2222-222222222222', 'u3', 1000, 'completed')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	var users []string
+	rows, err := db.Query(`SELECT username FROM users ORDER BY username`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var username string
+		require.NoError(t, rows.Scan(&username))
+		users = append(users, username)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"bob", "carol"}, users, "bob made a transfer and is kept")
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM transactions`).Scan(&count))
+	require.Equal(t, 2, count, "only alice's opening balance is removed")
+}
+
+// TestMigrator_AdoptsInitialisedDatabase checks that a database initialised
+// from the first migration before schema_migrations existed is migrated
+// without running that migration again.
+func TestMigrator_AdoptsInitialisedDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	m, err := New(db, SQLite, migrations.FS)
+	require.NoError(t, err)
+
+	initSQL, err := fs.ReadFile(migrations.FS, "0000001_init_schema.up.sql")
+	require.NoError(t, err)
+	_, err = db.Exec(string(initSQL))
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, applied[0].Version, "the first migration is not run again")
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), version)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count))
+	require.Zero(t, count, "the untouched demo users are removed")
+}
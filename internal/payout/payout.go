@@ -0,0 +1,195 @@
+// Package payout reads bulk payout files for usecase.PaymentUsecase and
+// writes their results.
+//
+// A CSV file has the columns receiver, amount, reference and note, the last
+// one optional, with an optional header line:
+//
+//	receiver,amount,reference,note
+//	user-123,250000,payroll-2026-10-user-123,October salary
+//
+// A JSON file is an array of objects with the fields receiver_id, amount,
+// reference and note. Amounts are integers in minor units.
+package payout
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"payment-service/internal/usecase"
+	"strconv"
+	"strings"
+)
+
+// Formats of payout files.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ErrUnknownFormat is returned by Read for formats other than FormatCSV and
+// FormatJSON.
+var ErrUnknownFormat = errors.New("payout file format must be csv or json")
+
+// ResultColumns is the header of the CSV written by WriteResultsCSV.
+var ResultColumns = []string{"line", "receiver", "amount", "reference", "note", "status", "transaction_id", "error"}
+
+// Read parses a payout file of the given format. Rows that cannot be parsed
+// are returned as errors with their line, so that they are reported together
+// with the validation errors of the other rows. The error is only set when
+// the file as a whole cannot be read.
+func Read(r io.Reader, format string) ([]usecase.PayoutRow, []usecase.PayoutRowError, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r)
+	case FormatJSON:
+		return ReadJSON(r)
+	default:
+		return nil, nil, ErrUnknownFormat
+	}
+}
+
+// FormatOf returns the format of a file by its name or content type, e.g.
+// "payroll.json" or "text/csv", and FormatCSV when it cannot tell.
+func FormatOf(nameOrType string) string {
+	s := strings.ToLower(nameOrType)
+	if strings.HasSuffix(s, ".json") || strings.Contains(s, "json") {
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// ReadCSV reads a CSV payout file. Line is the line of a row in the file.
+func ReadCSV(r io.Reader) ([]usecase.PayoutRow, []usecase.PayoutRowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var rows []usecase.PayoutRow
+	var invalid []usecase.PayoutRowError
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, invalid, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			invalid = append(invalid, malformed(parseErr.StartLine, "%v", parseErr.Err))
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if first && isHeader(record) {
+			continue
+		}
+
+		if len(record) < 3 || len(record) > 4 {
+			invalid = append(invalid, malformed(line, "expected 3 or 4 columns (receiver, amount, reference, note), got %d", len(record)))
+			continue
+		}
+		amount, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			invalid = append(invalid, usecase.PayoutRowError{
+				Line:    line,
+				Code:    usecase.ErrorCode(usecase.ErrInvalidAmount),
+				Message: fmt.Sprintf("amount %q is not an integer", record[1]),
+			})
+			continue
+		}
+		row := usecase.PayoutRow{
+			Line:       line,
+			ReceiverID: strings.TrimSpace(record[0]),
+			Amount:     amount,
+			Reference:  strings.TrimSpace(record[2]),
+		}
+		if len(record) == 4 {
+			row.Note = record[3]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// isHeader reports whether record is the optional header line.
+func isHeader(record []string) bool {
+	first := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff")))
+	return first == "receiver" || first == "receiver_id"
+}
+
+// ReadJSON reads a JSON array of rows. Line is the position of a row in the
+// array, counting from 1.
+func ReadJSON(r io.Reader) ([]usecase.PayoutRow, []usecase.PayoutRowError, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON payout file: %w", err)
+	}
+
+	var rows []usecase.PayoutRow
+	var invalid []usecase.PayoutRowError
+	for i, fields := range raw {
+		row := usecase.PayoutRow{Line: i + 1}
+		var err error
+		for _, field := range []struct {
+			name string
+			dest any
+		}{
+			{"receiver_id", &row.ReceiverID},
+			{"amount", &row.Amount},
+			{"reference", &row.Reference},
+			{"note", &row.Note},
+		} {
+			if value, ok := fields[field.name]; ok && json.Unmarshal(value, field.dest) != nil {
+				err = fmt.Errorf("%s has the wrong type", field.name)
+				break
+			}
+		}
+		if err != nil {
+			invalid = append(invalid, malformed(row.Line, "%v", err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, invalid, nil
+}
+
+func malformed(line int, format string, args ...any) usecase.PayoutRowError {
+	return usecase.PayoutRowError{
+		Line:    line,
+		Code:    usecase.ErrorCode(usecase.ErrMalformedRow),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// WriteResultsCSV writes the results of an executed payout file with
+// ResultColumns as the header. Cells taken from the uploaded file are passed
+// through spreadsheetSafe.
+func WriteResultsCSV(w io.Writer, results []usecase.PayoutRowResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write(ResultColumns)
+	for _, r := range results {
+		cw.Write([]string{
+			strconv.Itoa(r.Line),
+			spreadsheetSafe(r.ReceiverID),
+			strconv.FormatInt(r.Amount, 10),
+			spreadsheetSafe(r.Reference),
+			spreadsheetSafe(r.Note),
+			r.Status,
+			r.TransactionID,
+			spreadsheetSafe(r.Error),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// spreadsheetSafe prefixes cell with a quote when a spreadsheet would read it
+// as a formula, so that a note such as "=HYPERLINK(...)" in an uploaded file
+// is shown as text when ops open the results.
+func spreadsheetSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
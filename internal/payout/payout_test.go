@@ -0,0 +1,79 @@
+package payout
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"payment-service/internal/usecase"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := "\ufeffreceiver,amount,reference,note\n" +
+		"user-1,250000,payroll-1,October salary\n" +
+		"user-2, 100 ,payroll-2\n" +
+		"user-3,12.5,payroll-3\n" +
+		"user-4,100\n" +
+		"\"user-5,100,payroll-5\n"
+
+	rows, invalid, err := Read(strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+	require.Equal(t, []usecase.PayoutRow{
+		{Line: 2, ReceiverID: "user-1", Amount: 250000, Reference: "payroll-1", Note: "October salary"},
+		{Line: 3, ReceiverID: "user-2", Amount: 100, Reference: "payroll-2"},
+	}, rows)
+	require.Len(t, invalid, 3)
+	require.Equal(t, usecase.PayoutRowError{Line: 4, Code: "invalid_amount", Message: `amount "12.5" is not an integer`}, invalid[0])
+	require.Equal(t, 5, invalid[1].Line)
+	require.Equal(t, "invalid_request", invalid[1].Code)
+	require.Equal(t, 6, invalid[2].Line)
+}
+
+func TestReadJSON(t *testing.T) {
+	input := `[
+		{"receiver_id": "user-1", "amount": 100, "reference": "payroll-1", "note": "bonus"},
+		{"receiver_id": "user-2", "amount": "100", "reference": "payroll-2"}
+	]`
+
+	rows, invalid, err := Read(strings.NewReader(input), FormatOf("payroll.json"))
+	require.NoError(t, err)
+	require.Equal(t, []usecase.PayoutRow{{Line: 1, ReceiverID: "user-1", Amount: 100, Reference: "payroll-1", Note: "bonus"}}, rows)
+	require.Equal(t, []usecase.PayoutRowError{{Line: 2, Code: "invalid_request", Message: "amount has the wrong type"}}, invalid)
+
+	_, _, err = ReadJSON(strings.NewReader(`{"receiver_id": "user-1"}`))
+	require.Error(t, err)
+	_, _, err = Read(strings.NewReader(""), "xml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestFormatOf(t *testing.T) {
+	require.Equal(t, FormatJSON, FormatOf("application/json; charset=utf-8"))
+	require.Equal(t, FormatCSV, FormatOf("text/csv"))
+	require.Equal(t, FormatCSV, FormatOf("payroll.txt"))
+}
+
+func TestWriteResultsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteResultsCSV(&buf, []usecase.PayoutRowResult{
+		{PayoutRow: usecase.PayoutRow{Line: 2, ReceiverID: "user-1", Amount: 100, Reference: "p-1", Note: "a, b"}, Status: "completed", TransactionID: "tx-1"},
+		{PayoutRow: usecase.PayoutRow{Line: 3, ReceiverID: "user-2", Amount: 5, Reference: "p-2"}, Status: "failed", Error: "wallet_frozen"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "line,receiver,amount,reference,note,status,transaction_id,error\n"+
+		"2,user-1,100,p-1,\"a, b\",completed,tx-1,\n"+
+		"3,user-2,5,p-2,,failed,,wallet_frozen\n", buf.String())
+}
+
+func TestWriteResultsCSV_NeutralisesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteResultsCSV(&buf, []usecase.PayoutRowResult{
+		{PayoutRow: usecase.PayoutRow{Line: 2, ReceiverID: "@user-1", Amount: 100, Reference: "+p-1", Note: `=HYPERLINK("http://evil","x")`}, Status: "completed", TransactionID: "tx-1"},
+		{PayoutRow: usecase.PayoutRow{Line: 3, ReceiverID: "user-2", Amount: 5, Reference: "-p-2", Note: "\tnote"}, Status: "failed", Error: "\rcmd"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "line,receiver,amount,reference,note,status,transaction_id,error\n"+
+		"2,'@user-1,100,'+p-1,\"'=HYPERLINK(\"\"http://evil\"\",\"\"x\"\")\",completed,tx-1,\n"+
+		"3,user-2,5,'-p-2,'\tnote,failed,,\"'\rcmd\"\n", buf.String())
+}
@@ -0,0 +1,211 @@
+// Package ratelimit protects routes with token buckets keyed by client IP,
+// user and API key, as configured by config.RateLimit. Refused requests get
+// 429 Too Many Requests with Retry-After and the error code "rate_limited";
+// every limited response carries the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers of the IETF
+// httpapi-ratelimit-headers draft.
+package ratelimit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"payment-service/internal/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// APIKeyHeader identifies the client for limits with key "api_key".
+const APIKeyHeader = "X-API-Key"
+
+// maxBodyPeek bounds how much of the body is read to find the user of a
+// request.
+const maxBodyPeek = 1 << 20
+
+type Limiter struct {
+	store      Store
+	trustProxy bool
+	policies   map[string]policy
+	now        func() time.Time
+}
+
+type policy struct {
+	route     string
+	userField string
+	rules     []config.RateLimitRule
+}
+
+// New returns a limiter enforcing cfg with store. It limits nothing when
+// cfg.Enabled is false.
+func New(cfg config.RateLimit, store Store) *Limiter {
+	l := &Limiter{store: store, trustProxy: cfg.TrustProxy, policies: make(map[string]policy), now: time.Now}
+	if !cfg.Enabled {
+		return l
+	}
+	for _, p := range cfg.Routes {
+		l.policies[p.Route] = policy{route: p.Route, userField: p.UserField, rules: p.Limits}
+	}
+	return l
+}
+
+// Middleware enforces the policy of the matched route, looked up as the
+// request method and chi route pattern without its API version prefix, e.g.
+// "POST /transfer" for /v1/transfer. All versions of a route thus share its
+// buckets. Requests to routes without a policy are not limited. The route is
+// only known once chi has matched it, so use Middleware in a group
+// (chi.Router.Group or With) rather than on the root router.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if p, ok := l.policies[r.Method+" "+unversioned(rctx.RoutePattern())]; ok && !l.allow(w, r, p) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of every rule that applies to r and
+// writes the headers of the most restrictive one. It answers 429 and returns
+// false if any bucket is empty. A request refused by one rule still uses up
+// the tokens it took from the others.
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, p policy) bool {
+	now := l.now()
+	var (
+		policies []string
+		tightest *Result
+		limit    int
+		refused  *Result
+	)
+	for _, rule := range p.rules {
+		value := l.keyValue(r, rule.Key, p.userField)
+		if value == "" {
+			continue
+		}
+		burst := rule.Burst
+		if burst == 0 {
+			burst = rule.Requests
+		}
+		bucketLimit := Limit{Rate: float64(rule.Requests) / rule.Period.Seconds(), Burst: burst}
+
+		result, err := l.store.Take(r.Context(), p.route+"|"+rule.Key+"|"+value, bucketLimit, now)
+		if err != nil {
+			// Keep serving payments when the store is unavailable.
+			slog.WarnContext(r.Context(), "rate limit store failed, request not limited", "route", p.route, "error", err)
+			continue
+		}
+		policies = append(policies, strconv.Itoa(rule.Requests)+";w="+strconv.Itoa(int(rule.Period.Seconds()))+";burst="+strconv.Itoa(burst))
+		if tightest == nil || result.Remaining < tightest.Remaining {
+			tightest, limit = &result, burst
+		}
+		if !result.Allowed && (refused == nil || result.RetryAfter > refused.RetryAfter) {
+			refused = &result
+		}
+	}
+	if tightest == nil {
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+	h.Set("RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(tightest.Reset))
+	if refused == nil {
+		return true
+	}
+
+	h.Set("Retry-After", ceilSeconds(refused.RetryAfter))
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded", "code": "rate_limited"})
+	slog.InfoContext(r.Context(), "request rate limited", "route", p.route)
+	return false
+}
+
+// keyValue returns the value r is limited by for key, or "" when r has none.
+// API keys are hashed so that the store never holds them.
+func (l *Limiter) keyValue(r *http.Request, key, userField string) string {
+	switch key {
+	case "ip":
+		return l.clientIP(r)
+	case "api_key":
+		apiKey := r.Header.Get(APIKeyHeader)
+		if apiKey == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(apiKey))
+		return hex.EncodeToString(sum[:16])
+	case "user":
+		return bodyField(r, userField)
+	}
+	return ""
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bodyField returns the string field of the JSON body of r and leaves the
+// body for the handler to read again.
+func bodyField(r *http.Request, field string) string {
+	if r.Body == nil {
+		return ""
+	}
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, maxBodyPeek))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(peeked, &fields); err != nil {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(fields[field], &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// unversioned strips a version prefix such as /v1 from a route pattern.
+func unversioned(pattern string) string {
+	rest, ok := strings.CutPrefix(pattern, "/v")
+	if !ok {
+		return pattern
+	}
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits == 0 || !strings.HasPrefix(rest[digits:], "/") {
+		return pattern
+	}
+	return rest[digits:]
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
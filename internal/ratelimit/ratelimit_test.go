@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-service/internal/config"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+// newTestRouter serves POST /transfer, echoing the body, also as
+// /v1/transfer, and GET /wallet behind a limiter with a fixed clock.
+func newTestRouter(cfg config.RateLimit, store Store) http.Handler {
+	l := New(cfg, store)
+	l.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(l.Middleware)
+		r.Post("/transfer", func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(w, r.Body)
+		})
+		r.Get("/wallet/{userId}", func(w http.ResponseWriter, r *http.Request) {})
+		r.Post("/v1/transfer", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	return r
+}
+
+func transferPolicy(limits ...config.RateLimitRule) config.RateLimit {
+	return config.RateLimit{
+		Enabled: true,
+		Routes:  []config.RoutePolicy{{Route: "POST /transfer", UserField: "sender_id", Limits: limits}},
+	}
+}
+
+func transfer(t *testing.T, h http.Handler, remoteAddr, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/transfer", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_LimitsByIP(t *testing.T) {
+	h := newTestRouter(transferPolicy(config.RateLimitRule{Key: "ip", Requests: 60, Period: time.Minute, Burst: 2}), NewMemoryStore())
+
+	first := transfer(t, h, "10.0.0.1:1234", `{}`, nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, "2", first.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", first.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "60;w=60;burst=2", first.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1234", `{}`, nil).Code)
+	refused := transfer(t, h, "10.0.0.1:5678", `{}`, nil)
+	require.Equal(t, http.StatusTooManyRequests, refused.Code)
+	require.Equal(t, "1", refused.Header().Get("Retry-After"))
+	require.Equal(t, "0", refused.Header().Get("RateLimit-Remaining"))
+	require.JSONEq(t, `{"error":"rate limit exceeded","code":"rate_limited"}`, refused.Body.String())
+
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.2:1234", `{}`, nil).Code)
+
+	// Routes without a policy are not limited.
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wallet/alice", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestMiddleware_VersionsShareBuckets(t *testing.T) {
+	h := newTestRouter(transferPolicy(config.RateLimitRule{Key: "ip", Requests: 1, Period: time.Minute}), NewMemoryStore())
+
+	versioned := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transfer", strings.NewReader(`{}`))
+	req.RemoteAddr = "10.0.0.1:1"
+	h.ServeHTTP(versioned, req)
+	require.Equal(t, http.StatusOK, versioned.Code)
+	require.Equal(t, "1", versioned.Header().Get("RateLimit-Limit"))
+
+	require.Equal(t, http.StatusTooManyRequests, transfer(t, h, "10.0.0.1:1", `{}`, nil).Code)
+}
+
+func TestUnversioned(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"/v1/transfer":       "/transfer",
+		"/v12/wallet/{id}":   "/wallet/{id}",
+		"/transfer":          "/transfer",
+		"/v1":                "/v1",
+		"/version/{id}":      "/version/{id}",
+		"/vault/v1/transfer": "/vault/v1/transfer",
+	} {
+		require.Equal(t, want, unversioned(pattern), pattern)
+	}
+}
+
+func TestMiddleware_LimitsByUserAndAPIKey(t *testing.T) {
+	h := newTestRouter(transferPolicy(
+		config.RateLimitRule{Key: "user", Requests: 1, Period: time.Minute},
+		config.RateLimitRule{Key: "api_key", Requests: 10, Period: time.Minute},
+	), NewMemoryStore())
+	withKey := http.Header{APIKeyHeader: {"key-1"}}
+
+	ok := transfer(t, h, "10.0.0.1:1", `{"sender_id":"alice","amount":5}`, withKey)
+	require.Equal(t, http.StatusOK, ok.Code)
+	require.JSONEq(t, `{"sender_id":"alice","amount":5}`, ok.Body.String(), "the handler still reads the body")
+	require.Equal(t, "1;w=60;burst=1, 10;w=60;burst=10", ok.Header().Get("RateLimit-Policy"))
+	require.Equal(t, "0", ok.Header().Get("RateLimit-Remaining"))
+
+	require.Equal(t, http.StatusTooManyRequests, transfer(t, h, "10.0.0.2:1", `{"sender_id":"alice"}`, nil).Code)
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{"sender_id":"bob"}`, withKey).Code)
+
+	// Without a user or API key no limit applies.
+	rec := transfer(t, h, "10.0.0.1:1", `not json`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
+
+func TestMiddleware_TrustProxy(t *testing.T) {
+	cfg := transferPolicy(config.RateLimitRule{Key: "ip", Requests: 1, Period: time.Minute})
+	forwarded := func(ip string) http.Header { return http.Header{"X-Forwarded-For": {"203.0.113.9, " + ip}} }
+
+	h := newTestRouter(cfg, NewMemoryStore())
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{}`, forwarded("198.51.100.1")).Code)
+	require.Equal(t, http.StatusTooManyRequests, transfer(t, h, "10.0.0.1:1", `{}`, forwarded("198.51.100.2")).Code, "the header is ignored by default")
+
+	cfg.TrustProxy = true
+	h = newTestRouter(cfg, NewMemoryStore())
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{}`, forwarded("198.51.100.1")).Code)
+	require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{}`, forwarded("198.51.100.2")).Code)
+	require.Equal(t, http.StatusTooManyRequests, transfer(t, h, "10.0.0.3:1", `{}`, forwarded("198.51.100.2")).Code)
+}
+
+func TestMiddleware_DisabledAndStoreFailure(t *testing.T) {
+	cfg := transferPolicy(config.RateLimitRule{Key: "ip", Requests: 1, Period: time.Minute})
+
+	cfg.Enabled = false
+	h := newTestRouter(cfg, NewMemoryStore())
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{}`, nil).Code)
+	}
+
+	cfg.Enabled = true
+	h = newTestRouter(cfg, failingStore{})
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, transfer(t, h, "10.0.0.1:1", `{}`, nil).Code)
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: it refills at Rate tokens per second up to Burst
+// tokens, and every request takes one.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the state of a bucket after a request tried to take a token.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// RetryAfter is how long a refused request has to wait for a token.
+	RetryAfter time.Duration
+	// Reset is how long the bucket takes to refill completely.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Take must check and update the bucket of key
+// atomically, so that concurrent requests cannot both take the last token.
+//
+// MemoryStore only limits the process it runs in. A store shared by a
+// cluster, e.g. on Redis, implements Take as one script that reads the
+// tokens and timestamp of key, refills, takes and writes them back with an
+// expiry of Burst/Rate.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// MemoryStore is a Store in process memory. Buckets that have refilled
+// completely are dropped, so idle clients do not accumulate.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled completely.
+	full time.Time
+}
+
+// sweepInterval is how often MemoryStore drops full buckets.
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	burst := float64(limit.Burst)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+
+	var result Result
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / limit.Rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
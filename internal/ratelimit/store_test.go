@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	take := func() Result {
+		t.Helper()
+		result, err := store.Take(context.Background(), "k", limit, now)
+		require.NoError(t, err)
+		return result
+	}
+
+	require.Equal(t, Result{Allowed: true, Remaining: 1, Reset: time.Second}, take())
+	require.Equal(t, Result{Allowed: true, Remaining: 0, Reset: 2 * time.Second}, take())
+	require.Equal(t, Result{Allowed: false, Remaining: 0, RetryAfter: time.Second, Reset: 2 * time.Second}, take())
+
+	now = now.Add(500 * time.Millisecond)
+	refused := take()
+	require.False(t, refused.Allowed)
+	require.Equal(t, 500*time.Millisecond, refused.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, take().Allowed)
+
+	// Other keys have their own bucket.
+	other, err := store.Take(context.Background(), "other", limit, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, other.Remaining)
+}
+
+func TestMemoryStore_DropsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := store.Take(context.Background(), "idle", Limit{Rate: 1, Burst: 5}, now)
+	require.NoError(t, err)
+	require.Len(t, store.buckets, 1)
+
+	_, err = store.Take(context.Background(), "active", Limit{Rate: 1, Burst: 5}, now.Add(sweepInterval))
+	require.NoError(t, err)
+	require.Len(t, store.buckets, 1)
+	require.Contains(t, store.buckets, "active")
+}
@@ -0,0 +1,178 @@
+// Package recurrence parses the subset of iCalendar recurrence rules (RFC
+// 5545 RRULE) supported by scheduled transfers and computes their
+// occurrences.
+//
+// A rule is a list of NAME=VALUE parts separated by semicolons, optionally
+// prefixed with "RRULE:":
+//
+//	FREQ=MONTHLY;INTERVAL=1;COUNT=12
+//
+// FREQ is DAILY, WEEKLY, MONTHLY or YEARLY. INTERVAL, 1 by default, is the
+// number of periods between two occurrences. COUNT bounds the number of
+// occurrences and UNTIL, a UTC time such as 20271231T000000Z, the time of the
+// last one. The cron-like shorthands @daily, @weekly, @monthly and @yearly
+// stand for the plain rules of their frequency.
+//
+// Occurrences are counted from the start time, which is the first one.
+// Monthly and yearly rules keep its day of month and fall back to the last
+// day of shorter months, so a rule starting on January 31 pays on February
+// 28 (or 29) and March 31.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of a Rule.
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// untilLayout is the RFC 5545 UTC date-time format of UNTIL.
+const untilLayout = "20060102T150405Z"
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var shorthands = map[string]string{
+	"@daily":   Daily,
+	"@weekly":  Weekly,
+	"@monthly": Monthly,
+	"@yearly":  Yearly,
+}
+
+// Rule is a parsed recurrence rule. Count and Until are zero when the rule
+// does not end.
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+}
+
+// Parse parses a rule. Errors match ErrInvalidRule.
+func Parse(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if freq, ok := shorthands[strings.ToLower(s)]; ok {
+		return Rule{Freq: freq, Interval: 1}, nil
+	}
+
+	rule := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return Rule{}, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return Rule{}, fmt.Errorf("%w: %s is given twice", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = value
+			default:
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY, got %s", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positive(name, value)
+		case "COUNT":
+			rule.Count, err = positive(name, value)
+		case "UNTIL":
+			rule.Until, err = time.Parse(untilLayout, value)
+			if err != nil {
+				err = fmt.Errorf("UNTIL must be a UTC time such as 20271231T000000Z, got %s", value)
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", name)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+func positive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %s", name, value)
+	}
+	return n, nil
+}
+
+// String returns the rule in its canonical form, which Parse accepts.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrence returns the nth occurrence of r, counting from 1 for start.
+// It returns false when the rule ends before the nth occurrence.
+func (r Rule) Occurrence(start time.Time, n int) (time.Time, bool) {
+	if n < 1 || (r.Count > 0 && n > r.Count) {
+		return time.Time{}, false
+	}
+
+	steps := (n - 1) * r.Interval
+	var t time.Time
+	switch r.Freq {
+	case Daily:
+		t = start.AddDate(0, 0, steps)
+	case Weekly:
+		t = start.AddDate(0, 0, 7*steps)
+	case Monthly:
+		t = addMonths(start, steps)
+	case Yearly:
+		t = addMonths(start, 12*steps)
+	default:
+		return time.Time{}, false
+	}
+
+	if !r.Until.IsZero() && t.After(r.Until) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// addMonths adds months to t, keeping its day of month if the target month
+// has it and using the month's last day otherwise. time.AddDate would roll
+// over into the following month instead.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
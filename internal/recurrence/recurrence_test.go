@@ -0,0 +1,71 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=MONTHLY", "FREQ=MONTHLY"},
+		{"RRULE:FREQ=weekly;INTERVAL=2;COUNT=10", "FREQ=WEEKLY;INTERVAL=2;COUNT=10"},
+		{"FREQ=DAILY;UNTIL=20271231T000000Z", "FREQ=DAILY;UNTIL=20271231T000000Z"},
+		{"@monthly", "FREQ=MONTHLY"},
+	} {
+		rule, err := Parse(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.want, rule.String(), tc.in)
+	}
+
+	for _, in := range []string{
+		"",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;UNTIL=2027-12-31",
+		"FREQ=DAILY;COUNT=2;UNTIL=20271231T000000Z",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=MONTHLY;BYMONTHDAY=1",
+		"FREQ",
+	} {
+		_, err := Parse(in)
+		require.ErrorIs(t, err, ErrInvalidRule, in)
+	}
+}
+
+func TestRule_Occurrence(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	occurrences := func(rule string, n int) []string {
+		r, err := Parse(rule)
+		require.NoError(t, err)
+		var got []string
+		for i := 1; i <= n; i++ {
+			at, ok := r.Occurrence(start, i)
+			if !ok {
+				break
+			}
+			got = append(got, at.Format("2006-01-02"))
+		}
+		return got
+	}
+
+	require.Equal(t, []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}, occurrences("FREQ=MONTHLY", 4))
+	require.Equal(t, []string{"2026-01-31", "2026-03-31", "2026-05-31"}, occurrences("FREQ=MONTHLY;INTERVAL=2;COUNT=3", 5))
+	require.Equal(t, []string{"2026-01-31", "2026-02-14", "2026-02-28"}, occurrences("FREQ=WEEKLY;INTERVAL=2", 3))
+	require.Equal(t, []string{"2026-01-31", "2026-02-01"}, occurrences("FREQ=DAILY;UNTIL=20260201T090000Z", 5))
+	require.Equal(t, []string{"2026-01-31", "2027-01-31"}, occurrences("FREQ=YEARLY", 2))
+
+	leap := time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)
+	next, ok := Rule{Freq: Yearly, Interval: 1}.Occurrence(leap, 2)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2029, time.February, 28, 0, 0, 0, 0, time.UTC), next)
+
+	_, ok = Rule{Freq: Daily, Interval: 1}.Occurrence(start, 0)
+	require.False(t, ok)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"time"
+)
+
+// BatchRepo stores transfer batches in the batches table. Its SQL works on
+// both Postgres and SQLite. Items are stored as a JSON array.
+type BatchRepo struct {
+	db *sql.DB
+}
+
+func NewBatchRepo(db *sql.DB) domain.BatchRepository {
+	return &BatchRepo{db: db}
+}
+
+const batchColumns = `id, reference, sender_id, mode, status, error, items, locked_until, version, created_at, updated_at`
+
+func (r *BatchRepo) CreateBatch(ctx context.Context, batch *domain.Batch) error {
+	items, err := json.Marshal(batch.Items)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO batches (` + batchColumns + `)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = r.db.ExecContext(ctx, query, batch.ID, batch.Reference, batch.SenderID, batch.Mode, batch.Status,
+		batch.Error, string(items), batch.LockedUntil.UTC(), batch.Version, batch.CreatedAt.UTC(), batch.UpdatedAt.UTC())
+	return mapDriverError(err)
+}
+
+func (r *BatchRepo) GetBatch(ctx context.Context, id string) (*domain.Batch, error) {
+	query := `SELECT ` + batchColumns + ` FROM batches WHERE id = $1`
+	batch, err := scanBatch(r.db.QueryRowContext(ctx, query, id))
+	err = mapDriverError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrBatchNotFound, err)
+	}
+	return batch, err
+}
+
+// ClaimBatch picks the oldest pending batch, or processing batch whose lock
+// expired, and locks it with a conditional UPDATE on its version. If another
+// instance claimed it in between, the next one is tried.
+func (r *BatchRepo) ClaimBatch(ctx context.Context, now, lockUntil time.Time) (*domain.Batch, error) {
+	for {
+		var id string
+		var version int
+		err := r.db.QueryRowContext(ctx,
+			`SELECT id, version FROM batches
+             WHERE status = $1 OR (status = $2 AND locked_until <= $3)
+             ORDER BY created_at, id LIMIT 1`,
+			domain.BatchStatusPending, domain.BatchStatusProcessing, now.UTC()).Scan(&id, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrBatchNotFound
+		}
+		if err != nil {
+			return nil, mapDriverError(err)
+		}
+
+		res, err := r.db.ExecContext(ctx,
+			`UPDATE batches SET status = $1, locked_until = $2, version = version + 1, updated_at = $3
+             WHERE id = $4 AND version = $5`,
+			domain.BatchStatusProcessing, lockUntil.UTC(), now.UTC(), id, version)
+		if err != nil {
+			return nil, mapDriverError(err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return r.GetBatch(ctx, id)
+		}
+	}
+}
+
+func (r *BatchRepo) UpdateBatch(ctx context.Context, batch *domain.Batch) error {
+	items, err := json.Marshal(batch.Items)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE batches SET status = $1, error = $2, items = $3, locked_until = $4, version = version + 1,
+             updated_at = $5
+         WHERE id = $6 AND version = $7`,
+		batch.Status, batch.Error, string(items), batch.LockedUntil.UTC(), batch.UpdatedAt.UTC(), batch.ID,
+		batch.Version)
+	if err != nil {
+		return mapDriverError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := r.GetBatch(ctx, batch.ID); err != nil {
+			return err
+		}
+		return domain.ErrBatchConflict
+	}
+	batch.Version++
+	return nil
+}
+
+func scanBatch(row rowScanner) (*domain.Batch, error) {
+	var batch domain.Batch
+	var items string
+	err := row.Scan(&batch.ID, &batch.Reference, &batch.SenderID, &batch.Mode, &batch.Status, &batch.Error,
+		&items, &batch.LockedUntil, &batch.Version, &batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(items), &batch.Items); err != nil {
+		return nil, fmt.Errorf("invalid items of batch %s: %w", batch.ID, err)
+	}
+	return &batch, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/migrations"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRepo(t *testing.T) {
+	ctx := t.Context()
+	backends := map[string]func(t *testing.T) domain.BatchRepository{
+		"Memory": func(t *testing.T) domain.BatchRepository {
+			return NewMemoryBatchRepo()
+		},
+		"SQLite": func(t *testing.T) domain.BatchRepository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+			require.NoError(t, err)
+			_, err = m.Up(context.Background())
+			require.NoError(t, err)
+			return NewBatchRepo(db)
+		},
+		"Postgres": func(t *testing.T) domain.BatchRepository {
+			requirePostgres(t)
+			_, err := testDB.Exec("DELETE FROM batches")
+			require.NoError(t, err)
+			return NewBatchRepo(testDB)
+		},
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			r := newRepo(t)
+			created := time.Date(2024, 2, 19, 10, 0, 0, 0, time.UTC)
+			newBatch := func(reference string, created time.Time) *domain.Batch {
+				return &domain.Batch{
+					ID:        uuid.New().String(),
+					Reference: reference,
+					SenderID:  uuid.New().String(),
+					Mode:      domain.BatchModeBestEffort,
+					Status:    domain.BatchStatusPending,
+					Items: []domain.BatchItem{
+						{ReceiverID: uuid.New().String(), Amount: 10, Reference: reference + "-1", Status: domain.BatchItemStatusPending},
+						{ReceiverID: uuid.New().String(), Amount: 20, Reference: reference + "-2", Status: domain.BatchItemStatusPending},
+					},
+					CreatedAt: created,
+					UpdatedAt: created,
+				}
+			}
+			older := newBatch("payroll-jan", created)
+			newer := newBatch("payroll-feb", created.Add(time.Hour))
+			require.NoError(t, r.CreateBatch(ctx, older))
+			require.NoError(t, r.CreateBatch(ctx, newer))
+
+			err := r.CreateBatch(ctx, newBatch("payroll-jan", created))
+			require.ErrorIs(t, err, domain.ErrDuplicateBatchReference)
+
+			got, err := r.GetBatch(ctx, older.ID)
+			require.NoError(t, err)
+			require.Equal(t, older.Items, got.Items)
+			require.Equal(t, older.Reference, got.Reference)
+			require.True(t, older.CreatedAt.Equal(got.CreatedAt))
+
+			_, err = r.GetBatch(ctx, uuid.New().String())
+			require.ErrorIs(t, err, domain.ErrBatchNotFound)
+			_, err = r.GetBatch(ctx, "abc")
+			require.ErrorIs(t, err, domain.ErrBatchNotFound, "a malformed ID names no row")
+
+			now := created.Add(2 * time.Hour)
+			claimed, err := r.ClaimBatch(ctx, now, now.Add(time.Minute))
+			require.NoError(t, err)
+			require.Equal(t, older.ID, claimed.ID, "the oldest batch is claimed first")
+			require.Equal(t, domain.BatchStatusProcessing, claimed.Status)
+			require.True(t, now.Add(time.Minute).Equal(claimed.LockedUntil))
+			require.Equal(t, 1, claimed.Version)
+
+			stale := *claimed
+			claimed.UpdatedAt = now
+			require.NoError(t, r.UpdateBatch(ctx, claimed), "progress is saved under the lock")
+			require.Equal(t, 2, claimed.Version)
+			require.ErrorIs(t, r.UpdateBatch(ctx, &stale), domain.ErrBatchConflict)
+
+			claimed.Status = domain.BatchStatusPartiallyCompleted
+			claimed.Items[0].Status = domain.BatchItemStatusCompleted
+			claimed.Items[0].TransactionID = uuid.New().String()
+			claimed.Items[1].Status = domain.BatchItemStatusFailed
+			claimed.Items[1].Error = "wallet_not_found"
+			claimed.LockedUntil = time.Time{}
+			claimed.UpdatedAt = now.Add(time.Minute)
+			require.NoError(t, r.UpdateBatch(ctx, claimed))
+
+			got, err = r.GetBatch(ctx, older.ID)
+			require.NoError(t, err)
+			require.Equal(t, domain.BatchStatusPartiallyCompleted, got.Status)
+			require.Equal(t, claimed.Items, got.Items)
+
+			claimed, err = r.ClaimBatch(ctx, now, now.Add(time.Minute))
+			require.NoError(t, err)
+			require.Equal(t, newer.ID, claimed.ID)
+			_, err = r.ClaimBatch(ctx, now, now.Add(time.Minute))
+			require.ErrorIs(t, err, domain.ErrBatchNotFound, "a locked batch is not claimed again")
+
+			// The worker of newer crashed; its batch is claimed again once
+			// the lock expired, and the crashed worker can no longer save it.
+			later := now.Add(time.Minute)
+			reclaimed, err := r.ClaimBatch(ctx, later, later.Add(time.Minute))
+			require.NoError(t, err)
+			require.Equal(t, newer.ID, reclaimed.ID)
+			require.Equal(t, domain.BatchStatusProcessing, reclaimed.Status)
+			require.Equal(t, claimed.Version+1, reclaimed.Version)
+			require.ErrorIs(t, r.UpdateBatch(ctx, claimed), domain.ErrBatchConflict)
+			_, err = r.ClaimBatch(ctx, later, later.Add(time.Minute))
+			require.ErrorIs(t, err, domain.ErrBatchNotFound)
+
+			missing := newBatch("missing", created)
+			require.ErrorIs(t, r.UpdateBatch(ctx, missing), domain.ErrBatchNotFound)
+		})
+	}
+}
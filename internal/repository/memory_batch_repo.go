@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sync"
+	"time"
+)
+
+// MemoryBatchRepo keeps transfer batches in memory for the memory backend.
+// Batches are returned as copies so that callers cannot change the stored
+// items.
+type MemoryBatchRepo struct {
+	mu      sync.Mutex
+	batches map[string]domain.Batch
+	order   []string // IDs in creation order, for ClaimBatch
+}
+
+func NewMemoryBatchRepo() domain.BatchRepository {
+	return &MemoryBatchRepo{batches: make(map[string]domain.Batch)}
+}
+
+func (r *MemoryBatchRepo) CreateBatch(ctx context.Context, batch *domain.Batch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, b := range r.batches {
+		if b.Reference == batch.Reference {
+			return domain.ErrDuplicateBatchReference
+		}
+	}
+	r.batches[batch.ID] = copyBatch(batch)
+	r.order = append(r.order, batch.ID)
+	return nil
+}
+
+func (r *MemoryBatchRepo) GetBatch(ctx context.Context, id string) (*domain.Batch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch, ok := r.batches[id]
+	if !ok {
+		return nil, domain.ErrBatchNotFound
+	}
+	copied := copyBatch(&batch)
+	return &copied, nil
+}
+
+func (r *MemoryBatchRepo) ClaimBatch(ctx context.Context, now, lockUntil time.Time) (*domain.Batch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range r.order {
+		batch := r.batches[id]
+		expired := batch.Status == domain.BatchStatusProcessing && !batch.LockedUntil.After(now)
+		if batch.Status != domain.BatchStatusPending && !expired {
+			continue
+		}
+		batch.Status = domain.BatchStatusProcessing
+		batch.LockedUntil = lockUntil
+		batch.Version++
+		batch.UpdatedAt = now
+		r.batches[id] = batch
+		copied := copyBatch(&batch)
+		return &copied, nil
+	}
+	return nil, domain.ErrBatchNotFound
+}
+
+func (r *MemoryBatchRepo) UpdateBatch(ctx context.Context, batch *domain.Batch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.batches[batch.ID]
+	if !ok {
+		return domain.ErrBatchNotFound
+	}
+	if stored.Version != batch.Version {
+		return domain.ErrBatchConflict
+	}
+	stored.Status = batch.Status
+	stored.Error = batch.Error
+	stored.Items = append([]domain.BatchItem(nil), batch.Items...)
+	stored.LockedUntil = batch.LockedUntil
+	stored.Version++
+	stored.UpdatedAt = batch.UpdatedAt
+	r.batches[batch.ID] = stored
+	batch.Version++
+	return nil
+}
+
+func copyBatch(batch *domain.Batch) domain.Batch {
+	copied := *batch
+	copied.Items = append([]domain.BatchItem(nil), batch.Items...)
+	return copied
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sort"
+	"sync"
+)
+
+// MemoryPaymentRequestRepo keeps payment requests in memory for the memory
+// backend.
+type MemoryPaymentRequestRepo struct {
+	mu       sync.Mutex
+	requests map[string]domain.PaymentRequest
+}
+
+func NewMemoryPaymentRequestRepo() domain.PaymentRequestRepository {
+	return &MemoryPaymentRequestRepo{requests: make(map[string]domain.PaymentRequest)}
+}
+
+func (r *MemoryPaymentRequestRepo) CreatePaymentRequest(ctx context.Context, p *domain.PaymentRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[p.ID] = *p
+	return nil
+}
+
+func (r *MemoryPaymentRequestRepo) GetPaymentRequest(ctx context.Context, id string) (*domain.PaymentRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.requests[id]
+	if !ok {
+		return nil, domain.ErrPaymentRequestNotFound
+	}
+	return &p, nil
+}
+
+func (r *MemoryPaymentRequestRepo) ListPaymentRequests(ctx context.Context, userID string, limit int) ([]domain.PaymentRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var requests []domain.PaymentRequest
+	for _, p := range r.requests {
+		if p.RequesterID == userID || p.PayerID == userID {
+			requests = append(requests, p)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	if limit > 0 && len(requests) > limit {
+		requests = requests[:limit]
+	}
+	return requests, nil
+}
+
+func (r *MemoryPaymentRequestRepo) UpdatePaymentRequest(ctx context.Context, p *domain.PaymentRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.requests[p.ID]
+	if !ok {
+		return domain.ErrPaymentRequestNotFound
+	}
+	if stored.Version != p.Version {
+		return domain.ErrPaymentRequestConflict
+	}
+	stored.Status = p.Status
+	stored.Version++
+	stored.UpdatedAt = p.UpdatedAt
+	r.requests[p.ID] = stored
+	p.Version++
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sort"
+	"sync"
+)
+
+// MemoryReconciliationRepo keeps reconciliation reports in memory for the
+// memory backend.
+type MemoryReconciliationRepo struct {
+	mu      sync.Mutex
+	reports map[string]domain.ReconciliationReport
+}
+
+func NewMemoryReconciliationRepo() domain.ReconciliationRepository {
+	return &MemoryReconciliationRepo{reports: make(map[string]domain.ReconciliationReport)}
+}
+
+func (r *MemoryReconciliationRepo) CreateReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *report
+	copied.Mismatches = append([]domain.LedgerMismatch(nil), report.Mismatches...)
+	r.reports[report.ID] = copied
+	return nil
+}
+
+func (r *MemoryReconciliationRepo) GetReport(ctx context.Context, id string) (*domain.ReconciliationReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, ok := r.reports[id]
+	if !ok {
+		return nil, domain.ErrReportNotFound
+	}
+	return &report, nil
+}
+
+func (r *MemoryReconciliationRepo) ListReports(ctx context.Context, limit int) ([]domain.ReconciliationReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reports := make([]domain.ReconciliationReport, 0, len(r.reports))
+	for _, report := range r.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.After(b.StartedAt)
+		}
+		return a.ID < b.ID
+	})
+	if limit > 0 && len(reports) > limit {
+		reports = reports[:limit]
+	}
+	return reports, nil
+}
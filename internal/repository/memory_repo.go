@@ -0,0 +1,465 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errTxDone = errors.New("transaction has already been committed or rolled back")
+
+// MemoryRepo is a thread-safe in-memory domain.TransactionRepository. It mimics
+// the Postgres schema closely enough to run the API without a database:
+// wallets are row locked until the owning transaction ends, writes are only
+// visible to other callers after commit, references are unique and balances
+// cannot go below zero. Contexts are accepted for the interface but not
+// observed: waiting for a row lock cannot be cancelled.
+type MemoryRepo struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	wallets      map[string]*domain.Wallet // by wallet ID
+	userWallets  map[string]string         // user ID -> wallet ID
+	usernames    map[string]bool
+	transactions map[string]*domain.Transaction // by reference
+
+	locks       map[string]*memTx // wallet ID -> transaction holding the row lock
+	pendingRefs map[string]*memTx // reference -> transaction that inserted it
+	pendingKeys map[string]*memTx // user ID, username and wallet ID -> transaction that inserted it
+	waits       map[*memTx]*memTx // transaction -> transaction it waits for
+}
+
+type memTx struct {
+	wallets      map[string]*domain.Wallet // staged wallet rows by wallet ID
+	transactions []*domain.Transaction
+	created      []createdWallet
+	done         bool
+}
+
+type createdWallet struct {
+	user   domain.User
+	wallet domain.Wallet
+}
+
+// NewMemoryRepo returns an empty in-memory repository holding the given
+// wallets.
+func NewMemoryRepo(wallets ...domain.Wallet) domain.TransactionRepository {
+	r := &MemoryRepo{
+		wallets:      make(map[string]*domain.Wallet),
+		userWallets:  make(map[string]string),
+		usernames:    make(map[string]bool),
+		transactions: make(map[string]*domain.Transaction),
+		locks:        make(map[string]*memTx),
+		pendingRefs:  make(map[string]*memTx),
+		pendingKeys:  make(map[string]*memTx),
+		waits:        make(map[*memTx]*memTx),
+	}
+	r.cond = sync.NewCond(&r.mu)
+
+	for _, w := range wallets {
+		r.addWallet(domain.User{ID: w.UserID, Username: w.UserID}, w)
+	}
+	return r
+}
+
+// addWallet stores a committed user and wallet. r.mu must be held.
+func (r *MemoryRepo) addWallet(user domain.User, w domain.Wallet) {
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now()
+	}
+	if w.UpdatedAt.IsZero() {
+		w.UpdatedAt = w.CreatedAt
+	}
+	if w.Status == "" {
+		w.Status = domain.WalletStatusActive
+	}
+	r.wallets[w.ID] = &w
+	r.userWallets[w.UserID] = w.ID
+	r.usernames[user.Username] = true
+}
+
+func (r *MemoryRepo) BeginTx(ctx context.Context) (interface{}, error) {
+	return &memTx{wallets: make(map[string]*domain.Wallet)}, nil
+}
+
+func (r *MemoryRepo) CommitTx(tx interface{}) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, w := range t.wallets {
+		if w.Version != r.wallets[id].Version {
+			w.UpdatedAt = now
+		}
+		r.wallets[id] = w
+	}
+	for _, transaction := range t.transactions {
+		r.transactions[transaction.Reference] = transaction
+	}
+	for _, c := range t.created {
+		r.addWallet(c.user, c.wallet)
+	}
+	r.release(t)
+	return nil
+}
+
+func (r *MemoryRepo) RollbackTx(tx interface{}) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.release(t)
+	return nil
+}
+
+func (r *MemoryRepo) GetWalletForUpdate(ctx context.Context, tx interface{}, userID string) (*domain.Wallet, error) {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	walletID, ok := r.userWallets[userID]
+	if !ok {
+		return nil, domain.ErrWalletNotFound
+	}
+	w, err := r.lockWallet(t, walletID)
+	if err != nil {
+		return nil, err
+	}
+	copied := *w
+	return &copied, nil
+}
+
+func (r *MemoryRepo) UpdateWalletBalance(ctx context.Context, tx interface{}, walletID string, amount int64) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, err := r.lockWallet(t, walletID)
+	if err != nil {
+		return err
+	}
+	return applyBalance(w, amount)
+}
+
+func (r *MemoryRepo) UpdateWalletBalanceIfVersion(ctx context.Context, tx interface{}, walletID string, amount int64, expectedVersion int) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, err := r.lockWallet(t, walletID)
+	if err != nil {
+		return err
+	}
+	if w.Version != expectedVersion {
+		return domain.ErrVersionConflict
+	}
+	return applyBalance(w, amount)
+}
+
+func (r *MemoryRepo) CreateTransaction(ctx context.Context, tx interface{}, transaction *domain.Transaction) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like a unique index, wait for a concurrent insert of the same
+	// reference to commit or roll back before deciding.
+	for {
+		if _, ok := r.transactions[transaction.Reference]; ok {
+			return domain.ErrDuplicateReference
+		}
+		holder, ok := r.pendingRefs[transaction.Reference]
+		if !ok {
+			break
+		}
+		if holder == t {
+			return domain.ErrDuplicateReference
+		}
+		if err := r.wait(t, holder); err != nil {
+			return err
+		}
+	}
+
+	copied := *transaction
+	if copied.Type == "" {
+		copied.Type = domain.TransactionTypeTransfer
+	}
+	t.transactions = append(t.transactions, &copied)
+	r.pendingRefs[transaction.Reference] = t
+	return nil
+}
+
+func (r *MemoryRepo) GetTransactionByRef(ctx context.Context, refID string) (*domain.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transaction, ok := r.transactions[refID]
+	if !ok {
+		return nil, domain.ErrTransactionNotFound
+	}
+	copied := *transaction
+	return &copied, nil
+}
+
+func (r *MemoryRepo) TopUpWallet(ctx context.Context, tx interface{}, userID string, amount int64) error {
+	wallet, err := r.GetWalletForUpdate(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	return r.UpdateWalletBalance(ctx, tx, wallet.ID, amount)
+}
+
+func (r *MemoryRepo) GetWalletByUserID(ctx context.Context, userID string) (*domain.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	walletID, ok := r.userWallets[userID]
+	if !ok {
+		return nil, domain.ErrWalletNotFound
+	}
+	copied := *r.wallets[walletID]
+	return &copied, nil
+}
+
+func (r *MemoryRepo) CreateWallet(ctx context.Context, tx interface{}, user *domain.User, wallet *domain.Wallet) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like the unique indexes, wait for a concurrent insert of the same user
+	// ID, username or wallet ID to commit or roll back before deciding.
+	c := createdWallet{user: *user, wallet: *wallet}
+	keys := createdKeys(c)
+	for {
+		_, userTaken := r.userWallets[user.ID]
+		_, walletTaken := r.wallets[wallet.ID]
+		if userTaken || walletTaken || r.usernames[user.Username] {
+			return domain.ErrUserExists
+		}
+		var holder *memTx
+		for _, key := range keys {
+			if h, ok := r.pendingKeys[key]; ok {
+				holder = h
+				break
+			}
+		}
+		if holder == nil {
+			break
+		}
+		if holder == t {
+			return domain.ErrUserExists
+		}
+		if err := r.wait(t, holder); err != nil {
+			return err
+		}
+	}
+
+	if wallet.Status == "" {
+		wallet.Status = domain.WalletStatusActive
+	}
+	now := time.Now()
+	wallet.CreatedAt, wallet.UpdatedAt = now, now
+	c.wallet = *wallet
+	t.created = append(t.created, c)
+	for _, key := range keys {
+		r.pendingKeys[key] = t
+	}
+	return nil
+}
+
+func (r *MemoryRepo) SetWalletStatus(ctx context.Context, tx interface{}, walletID string, status string) error {
+	t, err := r.activeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, err := r.lockWallet(t, walletID)
+	if err != nil {
+		return err
+	}
+	w.Status = status
+	w.Version++
+	return nil
+}
+
+func (r *MemoryRepo) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var transactions []domain.Transaction
+	for _, t := range r.transactions {
+		if filter.UserID != "" && t.SenderID != filter.UserID && t.ReceiverID != filter.UserID {
+			continue
+		}
+		if filter.Type != "" && t.Type != filter.Type {
+			continue
+		}
+		transactions = append(transactions, *t)
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	if filter.Limit > 0 && len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+	return transactions, nil
+}
+
+func (r *MemoryRepo) GetLedger(ctx context.Context) (*domain.Ledger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wallets := make(map[string]*domain.WalletLedger, len(r.wallets))
+	for _, w := range r.wallets {
+		wallets[w.UserID] = &domain.WalletLedger{WalletID: w.ID, UserID: w.UserID, Balance: w.Balance}
+	}
+	ledger := &domain.Ledger{}
+	for _, t := range r.transactions {
+		if t.Status != "completed" {
+			continue
+		}
+		if l, ok := wallets[t.ReceiverID]; ok {
+			l.Credits += t.Amount
+		}
+		if l, ok := wallets[t.SenderID]; ok {
+			l.Debits += t.Amount
+		}
+		switch {
+		case t.SenderID == "":
+			ledger.Credits += t.Amount
+		case t.ReceiverID == "":
+			ledger.Debits += t.Amount
+		}
+	}
+
+	ledger.Wallets = make([]domain.WalletLedger, 0, len(wallets))
+	for _, l := range wallets {
+		ledger.Wallets = append(ledger.Wallets, *l)
+	}
+	sort.Slice(ledger.Wallets, func(i, j int) bool { return ledger.Wallets[i].UserID < ledger.Wallets[j].UserID })
+	return ledger, nil
+}
+
+func createdKeys(c createdWallet) []string {
+	return []string{"user:" + c.user.ID, "username:" + c.user.Username, "wallet:" + c.wallet.ID}
+}
+
+func (r *MemoryRepo) activeTx(tx interface{}) (*memTx, error) {
+	t, ok := tx.(*memTx)
+	if !ok {
+		return nil, fmt.Errorf("invalid transaction type")
+	}
+	if t.done {
+		return nil, errTxDone
+	}
+	return t, nil
+}
+
+// lockWallet takes the row lock on walletID for t, blocking while another
+// transaction holds it, and returns t's staged copy of the row. r.mu must be
+// held.
+func (r *MemoryRepo) lockWallet(t *memTx, walletID string) (*domain.Wallet, error) {
+	if _, ok := r.wallets[walletID]; !ok {
+		return nil, domain.ErrWalletNotFound
+	}
+	for {
+		holder := r.locks[walletID]
+		if holder == nil || holder == t {
+			break
+		}
+		if err := r.wait(t, holder); err != nil {
+			return nil, err
+		}
+	}
+	r.locks[walletID] = t
+
+	w, ok := t.wallets[walletID]
+	if !ok {
+		copied := *r.wallets[walletID]
+		w = &copied
+		t.wallets[walletID] = w
+	}
+	return w, nil
+}
+
+// wait blocks t until holder releases its locks. Like Postgres, it aborts
+// with domain.ErrTxConflict instead of waiting when holder is itself
+// (transitively) waiting for t. r.mu must be held.
+func (r *MemoryRepo) wait(t, holder *memTx) error {
+	for h := holder; h != nil; h = r.waits[h] {
+		if h == t {
+			return fmt.Errorf("%w: deadlock detected", domain.ErrTxConflict)
+		}
+	}
+	r.waits[t] = holder
+	r.cond.Wait()
+	delete(r.waits, t)
+	return nil
+}
+
+// release drops every lock and pending reference held by t and wakes up
+// waiting transactions. r.mu must be held.
+func (r *MemoryRepo) release(t *memTx) {
+	t.done = true
+	for walletID := range t.wallets {
+		delete(r.locks, walletID)
+	}
+	for _, transaction := range t.transactions {
+		delete(r.pendingRefs, transaction.Reference)
+	}
+	for _, c := range t.created {
+		for _, key := range createdKeys(c) {
+			delete(r.pendingKeys, key)
+		}
+	}
+	delete(r.waits, t)
+	r.cond.Broadcast()
+}
+
+func applyBalance(w *domain.Wallet, amount int64) error {
+	if w.Balance+amount < 0 {
+		return domain.ErrNegativeBalance
+	}
+	w.Balance += amount
+	w.Version++
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository/repositorytest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryRepo(t *testing.T) domain.TransactionRepository {
+	return NewMemoryRepo()
+}
+
+func TestMemoryRepo_Contract(t *testing.T) {
+	repositorytest.Run(t, newMemoryRepo)
+}
+
+func TestMemoryRepo_UncommittedWritesAreInvisible(t *testing.T) {
+	ctx := t.Context()
+	r := newMemoryRepo(t)
+	w := repositorytest.CreateWallet(t, r, 1000)
+
+	tx, err := r.BeginTx(ctx)
+	require.NoError(t, err)
+	defer r.RollbackTx(tx)
+	require.NoError(t, r.TopUpWallet(ctx, tx, w.UserID, 500))
+
+	got, err := r.GetWalletByUserID(ctx, w.UserID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), got.Balance)
+
+	require.NoError(t, r.CommitTx(tx))
+	require.Error(t, r.RollbackTx(tx))
+
+	got, err = r.GetWalletByUserID(ctx, w.UserID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1500), got.Balance)
+}
+
+func TestMemoryRepo_DetectsDeadlock(t *testing.T) {
+	ctx := t.Context()
+	r := newMemoryRepo(t)
+	a, c := repositorytest.CreateWallet(t, r, 100), repositorytest.CreateWallet(t, r, 100)
+
+	tx1, _ := r.BeginTx(ctx)
+	tx2, _ := r.BeginTx(ctx)
+	_, err := r.GetWalletForUpdate(ctx, tx1, a.UserID)
+	require.NoError(t, err)
+	_, err = r.GetWalletForUpdate(ctx, tx2, c.UserID)
+	require.NoError(t, err)
+
+	blocked := make(chan error)
+	go func() {
+		_, err := r.GetWalletForUpdate(ctx, tx1, c.UserID)
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = r.GetWalletForUpdate(ctx, tx2, a.UserID)
+	require.ErrorIs(t, err, domain.ErrTxConflict)
+	require.NoError(t, r.RollbackTx(tx2))
+
+	require.NoError(t, <-blocked)
+	require.NoError(t, r.RollbackTx(tx1))
+}
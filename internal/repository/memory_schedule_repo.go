@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sync"
+	"time"
+)
+
+// MemoryScheduleRepo keeps scheduled transfers in memory for the memory
+// backend.
+type MemoryScheduleRepo struct {
+	mu        sync.Mutex
+	schedules map[string]domain.Schedule
+}
+
+func NewMemoryScheduleRepo() domain.ScheduleRepository {
+	return &MemoryScheduleRepo{schedules: make(map[string]domain.Schedule)}
+}
+
+func (r *MemoryScheduleRepo) CreateSchedule(ctx context.Context, s *domain.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.schedules {
+		if stored.Reference == s.Reference {
+			return domain.ErrDuplicateScheduleReference
+		}
+	}
+	r.schedules[s.ID] = *s
+	return nil
+}
+
+func (r *MemoryScheduleRepo) GetSchedule(ctx context.Context, id string) (*domain.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schedules[id]
+	if !ok {
+		return nil, domain.ErrScheduleNotFound
+	}
+	return &s, nil
+}
+
+func (r *MemoryScheduleRepo) ClaimDueSchedule(ctx context.Context, now, lockUntil time.Time) (*domain.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *domain.Schedule
+	for _, s := range r.schedules {
+		if s.Status != domain.ScheduleStatusActive || s.NextRunAt.After(now) || s.LockedUntil.After(now) {
+			continue
+		}
+		if due == nil || s.NextRunAt.Before(due.NextRunAt) || (s.NextRunAt.Equal(due.NextRunAt) && s.ID < due.ID) {
+			due = &s
+		}
+	}
+	if due == nil {
+		return nil, domain.ErrScheduleNotFound
+	}
+	due.LockedUntil = lockUntil
+	due.Version++
+	r.schedules[due.ID] = *due
+	claimed := *due
+	return &claimed, nil
+}
+
+func (r *MemoryScheduleRepo) UpdateSchedule(ctx context.Context, s *domain.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.schedules[s.ID]
+	if !ok {
+		return domain.ErrScheduleNotFound
+	}
+	if stored.Version != s.Version {
+		return domain.ErrScheduleConflict
+	}
+	stored.Status = s.Status
+	stored.Occurrence = s.Occurrence
+	stored.NextRunAt = s.NextRunAt
+	stored.Attempts = s.Attempts
+	stored.Completed = s.Completed
+	stored.Skipped = s.Skipped
+	stored.LastError = s.LastError
+	stored.LockedUntil = s.LockedUntil
+	stored.Version++
+	stored.UpdatedAt = s.UpdatedAt
+	r.schedules[s.ID] = stored
+	s.Version++
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sync"
+)
+
+// MemorySplitRepo keeps split bills in memory for the memory backend.
+type MemorySplitRepo struct {
+	mu     sync.Mutex
+	splits map[string]domain.Split
+}
+
+func NewMemorySplitRepo() domain.SplitRepository {
+	return &MemorySplitRepo{splits: make(map[string]domain.Split)}
+}
+
+func (r *MemorySplitRepo) CreateSplit(ctx context.Context, split *domain.Split) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.splits[split.ID] = copySplit(split)
+	return nil
+}
+
+func (r *MemorySplitRepo) GetSplit(ctx context.Context, id string) (*domain.Split, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	split, ok := r.splits[id]
+	if !ok {
+		return nil, domain.ErrSplitNotFound
+	}
+	copied := copySplit(&split)
+	return &copied, nil
+}
+
+func (r *MemorySplitRepo) UpdateSplit(ctx context.Context, split *domain.Split) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.splits[split.ID]
+	if !ok {
+		return domain.ErrSplitNotFound
+	}
+	if stored.Version != split.Version {
+		return domain.ErrSplitConflict
+	}
+	stored.Status = split.Status
+	stored.Shares = append([]domain.SplitShare(nil), split.Shares...)
+	stored.Version++
+	stored.UpdatedAt = split.UpdatedAt
+	r.splits[split.ID] = stored
+	split.Version++
+	return nil
+}
+
+func copySplit(split *domain.Split) domain.Split {
+	copied := *split
+	copied.Shares = append([]domain.SplitShare(nil), split.Shares...)
+	return copied
+}
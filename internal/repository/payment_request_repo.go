@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+)
+
+// PaymentRequestRepo stores payment requests in the payment_requests table.
+// Its SQL works on both Postgres and SQLite.
+type PaymentRequestRepo struct {
+	db *sql.DB
+}
+
+func NewPaymentRequestRepo(db *sql.DB) domain.PaymentRequestRepository {
+	return &PaymentRequestRepo{db: db}
+}
+
+const paymentRequestColumns = `id, requester_id, payer_id, amount, note, status, expires_at, version, created_at, updated_at`
+
+func (r *PaymentRequestRepo) CreatePaymentRequest(ctx context.Context, p *domain.PaymentRequest) error {
+	query := `INSERT INTO payment_requests (` + paymentRequestColumns + `)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := r.db.ExecContext(ctx, query, p.ID, p.RequesterID, p.PayerID, p.Amount, p.Note, p.Status,
+		p.ExpiresAt.UTC(), p.Version, p.CreatedAt.UTC(), p.UpdatedAt.UTC())
+	return mapDriverError(err)
+}
+
+func (r *PaymentRequestRepo) GetPaymentRequest(ctx context.Context, id string) (*domain.PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + ` FROM payment_requests WHERE id = $1`
+	p, err := scanPaymentRequest(r.db.QueryRowContext(ctx, query, id))
+	err = mapDriverError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPaymentRequestNotFound, err)
+	}
+	return p, err
+}
+
+func (r *PaymentRequestRepo) ListPaymentRequests(ctx context.Context, userID string, limit int) ([]domain.PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + ` FROM payment_requests
+              WHERE requester_id = $1 OR payer_id = $1
+              ORDER BY created_at DESC, id`
+	args := []interface{}{userID}
+	if limit > 0 {
+		args = append(args, limit)
+		query += ` LIMIT $2`
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapDriverError(err)
+	}
+	defer rows.Close()
+
+	var requests []domain.PaymentRequest
+	for rows.Next() {
+		p, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *p)
+	}
+	return requests, mapDriverError(rows.Err())
+}
+
+func (r *PaymentRequestRepo) UpdatePaymentRequest(ctx context.Context, p *domain.PaymentRequest) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE payment_requests SET status = $1, version = version + 1, updated_at = $2
+         WHERE id = $3 AND version = $4`,
+		p.Status, p.UpdatedAt.UTC(), p.ID, p.Version)
+	if err != nil {
+		return mapDriverError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := r.GetPaymentRequest(ctx, p.ID); err != nil {
+			return err
+		}
+		return domain.ErrPaymentRequestConflict
+	}
+	p.Version++
+	return nil
+}
+
+func scanPaymentRequest(row rowScanner) (*domain.PaymentRequest, error) {
+	var p domain.PaymentRequest
+	err := row.Scan(&p.ID, &p.RequesterID, &p.PayerID, &p.Amount, &p.Note, &p.Status, &p.ExpiresAt,
+		&p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
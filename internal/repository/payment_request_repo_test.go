@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/migrations"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentRequestRepo(t *testing.T) {
+	ctx := t.Context()
+	backends := map[string]func(t *testing.T) domain.PaymentRequestRepository{
+		"Memory": func(t *testing.T) domain.PaymentRequestRepository {
+			return NewMemoryPaymentRequestRepo()
+		},
+		"SQLite": func(t *testing.T) domain.PaymentRequestRepository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+			require.NoError(t, err)
+			_, err = m.Up(context.Background())
+			require.NoError(t, err)
+			return NewPaymentRequestRepo(db)
+		},
+		"Postgres": func(t *testing.T) domain.PaymentRequestRepository {
+			requirePostgres(t)
+			_, err := testDB.Exec("DELETE FROM payment_requests")
+			require.NoError(t, err)
+			return NewPaymentRequestRepo(testDB)
+		},
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			r := newRepo(t)
+			now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+			alice, bob, carol := uuid.New().String(), uuid.New().String(), uuid.New().String()
+			newRequest := func(requester, payer string, created time.Time) *domain.PaymentRequest {
+				return &domain.PaymentRequest{
+					ID:          uuid.New().String(),
+					RequesterID: requester,
+					PayerID:     payer,
+					Amount:      2500,
+					Note:        "dinner",
+					Status:      domain.PaymentRequestStatusPending,
+					ExpiresAt:   created.Add(7 * 24 * time.Hour),
+					CreatedAt:   created,
+					UpdatedAt:   created,
+				}
+			}
+			first := newRequest(alice, bob, now.Add(-time.Hour))
+			second := newRequest(bob, alice, now)
+			other := newRequest(bob, carol, now)
+			for _, p := range []*domain.PaymentRequest{first, second, other} {
+				require.NoError(t, r.CreatePaymentRequest(ctx, p))
+			}
+
+			_, err := r.GetPaymentRequest(ctx, uuid.New().String())
+			require.ErrorIs(t, err, domain.ErrPaymentRequestNotFound)
+			_, err = r.GetPaymentRequest(ctx, "abc")
+			require.ErrorIs(t, err, domain.ErrPaymentRequestNotFound, "a malformed ID names no row")
+			got, err := r.GetPaymentRequest(ctx, first.ID)
+			require.NoError(t, err)
+			require.Equal(t, "dinner", got.Note)
+			require.True(t, first.ExpiresAt.Equal(got.ExpiresAt))
+
+			list, err := r.ListPaymentRequests(ctx, alice, 0)
+			require.NoError(t, err)
+			require.Len(t, list, 2, "requests made by and to the user are listed")
+			require.Equal(t, second.ID, list[0].ID, "newest first")
+			require.Equal(t, first.ID, list[1].ID)
+			list, err = r.ListPaymentRequests(ctx, bob, 1)
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+
+			stale := *first
+			first.Status = domain.PaymentRequestStatusAccepted
+			first.UpdatedAt = now
+			require.NoError(t, r.UpdatePaymentRequest(ctx, first))
+			require.Equal(t, 1, first.Version)
+			require.ErrorIs(t, r.UpdatePaymentRequest(ctx, &stale), domain.ErrPaymentRequestConflict)
+			require.ErrorIs(t, r.UpdatePaymentRequest(ctx, newRequest(alice, bob, now)), domain.ErrPaymentRequestNotFound)
+
+			got, err = r.GetPaymentRequest(ctx, first.ID)
+			require.NoError(t, err)
+			require.Equal(t, domain.PaymentRequestStatusAccepted, got.Status)
+			require.Equal(t, 1, got.Version)
+		})
+	}
+}
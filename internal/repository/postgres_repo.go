@@ -1,9 +1,26 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"payment-service/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+)
+
+// SQLSTATE codes for which Postgres aborts a transaction that can be retried
+// as a whole.
+const (
+	pqSerializationFailure      = "40001"
+	pqDeadlockDetected          = "40P01"
+	pqUniqueViolation           = "23505"
+	pqCheckViolation            = "23514"
+	pqInvalidTextRepresentation = "22P02"
 )
 
 type PostgresRepo struct {
@@ -14,8 +31,14 @@ func NewPostgresRepo(db *sql.DB) domain.TransactionRepository {
 	return &PostgresRepo{db: db}
 }
 
-func (r *PostgresRepo) BeginTx() (interface{}, error) {
-	return r.db.Begin()
+// OpenPostgres connects to the database at the lib/pq connection string dsn.
+// Statements are traced, see openDB.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	return openDB("postgres", dsn, semconv.DBSystemNamePostgreSQL)
+}
+
+func (r *PostgresRepo) BeginTx(ctx context.Context) (interface{}, error) {
+	return r.db.BeginTx(ctx, nil)
 }
 
 func (r *PostgresRepo) CommitTx(tx interface{}) error {
@@ -23,7 +46,7 @@ func (r *PostgresRepo) CommitTx(tx interface{}) error {
 	if !ok {
 		return fmt.Errorf("invalid transaction type")
 	}
-	return sqlTx.Commit()
+	return mapPgError(sqlTx.Commit())
 }
 
 func (r *PostgresRepo) RollbackTx(tx interface{}) error {
@@ -34,70 +57,195 @@ func (r *PostgresRepo) RollbackTx(tx interface{}) error {
 	return sqlTx.Rollback()
 }
 
-func (r *PostgresRepo) GetWalletForUpdate(tx interface{}, userID string) (*domain.Wallet, error) {
+func (r *PostgresRepo) GetWalletForUpdate(ctx context.Context, tx interface{}, userID string) (*domain.Wallet, error) {
 	sqlTx := tx.(*sql.Tx)
-	query := `SELECT id, user_id, balance, version FROM wallets WHERE user_id = $1 FOR UPDATE`
+	query := `SELECT id, user_id, balance, version, status FROM wallets WHERE user_id = $1 FOR UPDATE`
 
-	row := sqlTx.QueryRow(query, userID)
+	row := sqlTx.QueryRowContext(ctx, query, userID)
 	var w domain.Wallet
-	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version)
+	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version, &w.Status)
 	if err != nil {
-		return nil, err
+		return nil, mapWalletError(err)
 	}
 	return &w, nil
 }
 
-func (r *PostgresRepo) UpdateWalletBalance(tx interface{}, walletID string, amount int64) error {
+func (r *PostgresRepo) UpdateWalletBalance(ctx context.Context, tx interface{}, walletID string, amount int64) error {
 	sqlTx := tx.(*sql.Tx)
-	query := `UPDATE wallets SET balance = balance + $1, version = version + 1 WHERE id = $2`
-	_, err := sqlTx.Exec(query, amount, walletID)
-	return err
+	query := `UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := sqlTx.ExecContext(ctx, query, amount, walletID)
+	return mapPgError(err)
 }
 
-func (r *PostgresRepo) CreateTransaction(tx interface{}, t *domain.Transaction) error {
+// UpdateWalletBalanceIfVersion applies the balance change only if the wallet is
+// still at expectedVersion and returns domain.ErrVersionConflict otherwise.
+func (r *PostgresRepo) UpdateWalletBalanceIfVersion(ctx context.Context, tx interface{}, walletID string, amount int64, expectedVersion int) error {
 	sqlTx := tx.(*sql.Tx)
-	query := `INSERT INTO transactions (id, reference_id, sender_id, receiver_id, amount, status, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := sqlTx.Exec(query, t.ID, t.Reference, t.SenderID, t.ReceiverID, t.Amount, t.Status, t.CreatedAt)
-	return err
+	query := `UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND version = $3`
+	res, err := sqlTx.ExecContext(ctx, query, amount, walletID, expectedVersion)
+	if err != nil {
+		return mapPgError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrVersionConflict
+	}
+	return nil
 }
 
-func (r *PostgresRepo) GetTransactionByRef(refID string) (*domain.Transaction, error) {
-	query := `SELECT id, reference_id, sender_id, receiver_id, amount, status, created_at FROM transactions WHERE reference_id = $1`
-	row := r.db.QueryRow(query, refID)
-	var t domain.Transaction
-	err := row.Scan(&t.ID, &t.Reference, &t.SenderID, &t.ReceiverID, &t.Amount, &t.Status, &t.CreatedAt)
+func (r *PostgresRepo) CreateTransaction(ctx context.Context, tx interface{}, t *domain.Transaction) error {
+	sqlTx := tx.(*sql.Tx)
+	if t.Type == "" {
+		t.Type = domain.TransactionTypeTransfer
+	}
+	query := `INSERT INTO transactions (id, reference_id, type, sender_id, receiver_id, amount, status, created_at) 
+              VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, $6, $7, $8)`
+	_, err := sqlTx.ExecContext(ctx, query, t.ID, t.Reference, t.Type, t.SenderID, t.ReceiverID, t.Amount, t.Status, t.CreatedAt)
+	return mapPgError(err)
+}
+
+func (r *PostgresRepo) GetTransactionByRef(ctx context.Context, refID string) (*domain.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE reference_id = $1`
+	t, err := scanTransaction(r.db.QueryRowContext(ctx, query, refID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrTransactionNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	return t, nil
 }
 
-func (r *PostgresRepo) TopUpWallet(tx interface{}, userID string, amount int64) error {
+func (r *PostgresRepo) TopUpWallet(ctx context.Context, tx interface{}, userID string, amount int64) error {
 	sqlTx, ok := tx.(*sql.Tx)
 	if !ok {
 		return fmt.Errorf("invalid transaction type")
 	}
 
-	wallet, err := r.GetWalletForUpdate(sqlTx, userID)
+	wallet, err := r.GetWalletForUpdate(ctx, sqlTx, userID)
 	if err != nil {
 		return err
 	}
 
-	err = r.UpdateWalletBalance(sqlTx, wallet.ID, amount)
+	err = r.UpdateWalletBalance(ctx, sqlTx, wallet.ID, amount)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *PostgresRepo) GetWalletByUserID(userID string) (*domain.Wallet, error) {
-	query := `SELECT id, user_id, balance, version, created_at, updated_at FROM wallets WHERE user_id = $1`
-	row := r.db.QueryRow(query, userID)
+// CreateWallet inserts user and its wallet. The wallet's timestamps are set
+// to the current time.
+func (r *PostgresRepo) CreateWallet(ctx context.Context, tx interface{}, user *domain.User, wallet *domain.Wallet) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+
+	_, err := sqlTx.ExecContext(ctx, `INSERT INTO users (id, username) VALUES ($1, $2)`, user.ID, user.Username)
+	if err != nil {
+		return mapPgError(err)
+	}
+
+	if wallet.Status == "" {
+		wallet.Status = domain.WalletStatusActive
+	}
+	wallet.CreatedAt = time.Now().UTC()
+	wallet.UpdatedAt = wallet.CreatedAt
+	query := `INSERT INTO wallets (id, user_id, balance, version, status, created_at, updated_at) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = sqlTx.ExecContext(ctx, query, wallet.ID, wallet.UserID, wallet.Balance, wallet.Version, wallet.Status, wallet.CreatedAt, wallet.UpdatedAt)
+	return mapPgError(err)
+}
+
+func (r *PostgresRepo) GetWalletByUserID(ctx context.Context, userID string) (*domain.Wallet, error) {
+	query := `SELECT id, user_id, balance, version, status, created_at, updated_at FROM wallets WHERE user_id = $1`
+	row := r.db.QueryRowContext(ctx, query, userID)
 	var w domain.Wallet
-	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version, &w.CreatedAt, &w.UpdatedAt)
+	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version, &w.Status, &w.CreatedAt, &w.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return nil, mapWalletError(err)
 	}
 	return &w, nil
 }
+
+// SetWalletStatus changes the status of the wallet and bumps its version.
+func (r *PostgresRepo) SetWalletStatus(ctx context.Context, tx interface{}, walletID string, status string) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+
+	query := `UPDATE wallets SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	res, err := sqlTx.ExecContext(ctx, query, status, walletID)
+	if err != nil {
+		return mapPgError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepo) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	return listTransactions(ctx, r.db, filter)
+}
+
+// GetLedger reads the ledger in a repeatable read transaction so that the
+// per-wallet sums and the totals come from the same snapshot.
+func (r *PostgresRepo) GetLedger(ctx context.Context) (*domain.Ledger, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return getLedger(ctx, tx)
+}
+
+// mapPgError wraps Postgres errors the usecase layer needs to react to with
+// the matching domain error, keeping the original message.
+func mapPgError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case pqSerializationFailure, pqDeadlockDetected:
+		return fmt.Errorf("%w: %v", domain.ErrTxConflict, err)
+	case pqUniqueViolation:
+		switch {
+		case strings.Contains(pqErr.Constraint, "reference_id"):
+			return fmt.Errorf("%w: %v", domain.ErrDuplicateReference, err)
+		case pqErr.Table == "batches" && strings.Contains(pqErr.Constraint, "reference"):
+			return fmt.Errorf("%w: %v", domain.ErrDuplicateBatchReference, err)
+		case pqErr.Table == "schedules" && strings.Contains(pqErr.Constraint, "reference"):
+			return fmt.Errorf("%w: %v", domain.ErrDuplicateScheduleReference, err)
+		case pqErr.Table == "users" || strings.Contains(pqErr.Constraint, "user_id"):
+			return fmt.Errorf("%w: %v", domain.ErrUserExists, err)
+		}
+	case pqCheckViolation:
+		if strings.Contains(pqErr.Constraint, "balance") {
+			return fmt.Errorf("%w: %v", domain.ErrNegativeBalance, err)
+		}
+	case pqInvalidTextRepresentation:
+		return fmt.Errorf("%w: %v", domain.ErrMalformedID, err)
+	}
+	return err
+}
+
+// mapWalletError is mapPgError for wallet lookups, which additionally report
+// a missing row, or a user ID that is no UUID, as domain.ErrWalletNotFound.
+func mapWalletError(err error) error {
+	err = mapPgError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return fmt.Errorf("%w: %v", domain.ErrWalletNotFound, err)
+	}
+	return err
+}
@@ -1,11 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/internal/repository/repositorytest"
+	"payment-service/migrations"
 	"testing"
 	"time"
 
@@ -30,12 +34,24 @@ const (
 func TestMain(m *testing.M) {
 	err := setupTestDB()
 	if err != nil {
-		log.Fatalf("Failed to set up test database: %v", err)
+		// Postgres tests are skipped when no database is reachable so the
+		// rest of the package can still be tested without Docker.
+		log.Printf("Skipping Postgres tests, failed to set up test database: %v", err)
+		testDB = nil
+		os.Exit(m.Run())
 	}
-	defer teardownTestDB()
 
 	repo = NewPostgresRepo(testDB)
-	os.Exit(m.Run())
+	code := m.Run()
+	teardownTestDB()
+	os.Exit(code)
+}
+
+func requirePostgres(t *testing.T) {
+	t.Helper()
+	if testDB == nil {
+		t.Skip("Postgres test database is not available")
+	}
 }
 
 func setupTestDB() error {
@@ -61,36 +77,12 @@ func setupTestDB() error {
 		return fmt.Errorf("failed to connect to test db: %w", err)
 	}
 
-	// Apply migrations or create tables
-	// For simplicity, we'll create tables directly here.
-	createWalletsTableSQL := `
-	CREATE TABLE IF NOT EXISTS wallets (
-		id VARCHAR(36) PRIMARY KEY,
-		user_id VARCHAR(36) NOT NULL UNIQUE,
-		balance BIGINT NOT NULL DEFAULT 0,
-		version INT NOT NULL DEFAULT 0,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	createTransactionsTableSQL := `
-	CREATE TABLE IF NOT EXISTS transactions (
-		id VARCHAR(36) PRIMARY KEY,
-		reference_id VARCHAR(255) NOT NULL UNIQUE,
-		sender_id VARCHAR(36) NOT NULL,
-		receiver_id VARCHAR(36) NOT NULL,
-		amount BIGINT NOT NULL,
-		status VARCHAR(50) NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err = testDB.Exec(createWalletsTableSQL)
+	m, err := migrate.New(testDB, migrate.Postgres, migrations.FS)
 	if err != nil {
-		return fmt.Errorf("failed to create wallets table: %w", err)
+		return err
 	}
-	_, err = testDB.Exec(createTransactionsTableSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create transactions table: %w", err)
+	if _, err := m.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to migrate test database: %w", err)
 	}
 
 	return nil
@@ -127,10 +119,21 @@ func clearTables() error {
 		return err
 	}
 	_, err = testDB.Exec("DELETE FROM wallets")
+	if err != nil {
+		return err
+	}
+	_, err = testDB.Exec("DELETE FROM users")
+	return err
+}
+
+func insertTestUser(userID string) error {
+	_, err := testDB.Exec(`INSERT INTO users (id, username) VALUES ($1, $2)`, userID, userID)
 	return err
 }
 
 func TestPostgresRepo_TopUpWallet(t *testing.T) {
+	ctx := t.Context()
+	requirePostgres(t)
 	require.NoError(t, clearTables())
 
 	userID := uuid.New().String()
@@ -139,16 +142,17 @@ func TestPostgresRepo_TopUpWallet(t *testing.T) {
 	topUpAmount := int64(500)
 
 	// Insert initial wallet
+	require.NoError(t, insertTestUser(userID))
 	_, err := testDB.Exec(`INSERT INTO wallets (id, user_id, balance, version, created_at, updated_at) 
                            VALUES ($1, $2, $3, $4, $5, $6)`,
 		walletID, userID, initialBalance, 0, time.Now(), time.Now())
 	require.NoError(t, err)
 
-	tx, err := repo.BeginTx()
+	tx, err := repo.BeginTx(ctx)
 	require.NoError(t, err)
 	defer repo.RollbackTx(tx)
 
-	err = repo.TopUpWallet(tx, userID, topUpAmount)
+	err = repo.TopUpWallet(ctx, tx, userID, topUpAmount)
 	require.NoError(t, err)
 
 	err = repo.CommitTx(tx)
@@ -162,13 +166,23 @@ func TestPostgresRepo_TopUpWallet(t *testing.T) {
 
 	// Test case: User not found - TopUpWallet should return an error from GetWalletForUpdate
 	require.NoError(t, clearTables()) // Clear for next test case
-	tx2, err := repo.BeginTx()
+	tx2, err := repo.BeginTx(ctx)
 	require.NoError(t, err)
 	defer repo.RollbackTx(tx2)
 
 	nonExistentUserID := uuid.New().String()
-	err = repo.TopUpWallet(tx2, nonExistentUserID, topUpAmount)
+	err = repo.TopUpWallet(ctx, tx2, nonExistentUserID, topUpAmount)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "no rows in result set") // Expecting an error from GetWalletForUpdate
 	repo.RollbackTx(tx2)                                      // Rollback explicitly since no commit will happen
 }
+
+func newPostgresRepo(t *testing.T) domain.TransactionRepository {
+	require.NoError(t, clearTables())
+	return repo
+}
+
+func TestPostgresRepo_Contract(t *testing.T) {
+	requirePostgres(t)
+	repositorytest.Run(t, newPostgresRepo)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+)
+
+// ReconciliationRepo stores reconciliation reports in the
+// reconciliation_reports table. Its SQL works on both Postgres and SQLite.
+// Mismatches are stored as a JSON array.
+type ReconciliationRepo struct {
+	db *sql.DB
+}
+
+func NewReconciliationRepo(db *sql.DB) domain.ReconciliationRepository {
+	return &ReconciliationRepo{db: db}
+}
+
+const reportColumns = `id, triggered_by, status, wallets_checked, total_balance, expected_total, mismatches, started_at, finished_at`
+
+func (r *ReconciliationRepo) CreateReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	mismatches, err := json.Marshal(report.Mismatches)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO reconciliation_reports (` + reportColumns + `)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = r.db.ExecContext(ctx, query, report.ID, report.Trigger, report.Status, report.WalletsChecked,
+		report.TotalBalance, report.ExpectedTotal, string(mismatches), report.StartedAt.UTC(), report.FinishedAt.UTC())
+	return err
+}
+
+func (r *ReconciliationRepo) GetReport(ctx context.Context, id string) (*domain.ReconciliationReport, error) {
+	query := `SELECT ` + reportColumns + ` FROM reconciliation_reports WHERE id = $1`
+	report, err := scanReport(r.db.QueryRowContext(ctx, query, id))
+	err = mapDriverError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrReportNotFound, err)
+	}
+	return report, err
+}
+
+func (r *ReconciliationRepo) ListReports(ctx context.Context, limit int) ([]domain.ReconciliationReport, error) {
+	query := `SELECT ` + reportColumns + ` FROM reconciliation_reports ORDER BY started_at DESC, id`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []domain.ReconciliationReport
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, rows.Err()
+}
+
+func scanReport(row rowScanner) (*domain.ReconciliationReport, error) {
+	var report domain.ReconciliationReport
+	var mismatches string
+	err := row.Scan(&report.ID, &report.Trigger, &report.Status, &report.WalletsChecked, &report.TotalBalance,
+		&report.ExpectedTotal, &mismatches, &report.StartedAt, &report.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(mismatches), &report.Mismatches); err != nil {
+		return nil, fmt.Errorf("invalid mismatches of report %s: %w", report.ID, err)
+	}
+	return &report, nil
+}
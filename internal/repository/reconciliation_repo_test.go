@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/migrations"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRepo(t *testing.T) {
+	ctx := t.Context()
+	backends := map[string]func(t *testing.T) domain.ReconciliationRepository{
+		"Memory": func(t *testing.T) domain.ReconciliationRepository {
+			return NewMemoryReconciliationRepo()
+		},
+		"SQLite": func(t *testing.T) domain.ReconciliationRepository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+			require.NoError(t, err)
+			_, err = m.Up(context.Background())
+			require.NoError(t, err)
+			return NewReconciliationRepo(db)
+		},
+		"Postgres": func(t *testing.T) domain.ReconciliationRepository {
+			requirePostgres(t)
+			_, err := testDB.Exec("DELETE FROM reconciliation_reports")
+			require.NoError(t, err)
+			return NewReconciliationRepo(testDB)
+		},
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			r := newRepo(t)
+			start := time.Date(2024, 2, 19, 10, 0, 0, 0, time.UTC)
+			older := &domain.ReconciliationReport{
+				ID:             uuid.New().String(),
+				Trigger:        domain.ReconciliationTriggerScheduled,
+				Status:         domain.ReconciliationStatusOK,
+				WalletsChecked: 2,
+				TotalBalance:   100,
+				ExpectedTotal:  100,
+				StartedAt:      start,
+				FinishedAt:     start.Add(time.Second),
+			}
+			newer := &domain.ReconciliationReport{
+				ID:             uuid.New().String(),
+				Trigger:        domain.ReconciliationTriggerManual,
+				Status:         domain.ReconciliationStatusMismatch,
+				WalletsChecked: 2,
+				TotalBalance:   105,
+				ExpectedTotal:  100,
+				Mismatches:     []domain.LedgerMismatch{{WalletID: "w1", UserID: "u1", Balance: 55, Expected: 50}},
+				StartedAt:      start.Add(time.Hour),
+				FinishedAt:     start.Add(time.Hour + time.Second),
+			}
+			require.NoError(t, r.CreateReport(ctx, older))
+			require.NoError(t, r.CreateReport(ctx, newer))
+
+			got, err := r.GetReport(ctx, newer.ID)
+			require.NoError(t, err)
+			require.Equal(t, newer.Mismatches, got.Mismatches)
+			require.Equal(t, newer.Status, got.Status)
+			require.True(t, newer.StartedAt.Equal(got.StartedAt))
+
+			_, err = r.GetReport(ctx, uuid.New().String())
+			require.ErrorIs(t, err, domain.ErrReportNotFound)
+			_, err = r.GetReport(ctx, "abc")
+			require.ErrorIs(t, err, domain.ErrReportNotFound, "a malformed ID names no row")
+
+			reports, err := r.ListReports(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, reports, 2)
+			require.Equal(t, newer.ID, reports[0].ID)
+			require.Empty(t, reports[1].Mismatches)
+
+			reports, err = r.ListReports(ctx, 1)
+			require.NoError(t, err)
+			require.Len(t, reports, 1)
+		})
+	}
+}
@@ -0,0 +1,506 @@
+// Package repositorytest provides a conformance suite that every
+// domain.TransactionRepository implementation must pass, so that all backends
+// are verified against the same expectations.
+package repositorytest
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite. newRepo is called once per test and must return a
+// repository that does not share wallets with previous calls.
+func Run(t *testing.T, newRepo func(t *testing.T) domain.TransactionRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo domain.TransactionRepository)
+	}{
+		{"CreateWallet", testCreateWallet},
+		{"CreateWalletWaitsForPendingUser", testCreateWalletWaitsForPendingUser},
+		{"TopUpCommit", testTopUpCommit},
+		{"Rollback", testRollback},
+		{"GetWalletByUserID", testGetWalletByUserID},
+		{"WalletNotFound", testWalletNotFound},
+		{"TransactionNotFound", testTransactionNotFound},
+		{"DuplicateReference", testDuplicateReference},
+		{"NegativeBalance", testNegativeBalance},
+		{"VersionConflict", testVersionConflict},
+		{"RowLockBlocksUntilCommit", testRowLockBlocksUntilCommit},
+		{"ConcurrentOppositeTransfers", testConcurrentOppositeTransfers},
+		{"OneSidedTransaction", testOneSidedTransaction},
+		{"ListTransactions", testListTransactions},
+		{"SetWalletStatus", testSetWalletStatus},
+		{"Ledger", testLedger},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+// CreateWallet stores a new user owning a wallet with the given balance and
+// returns the wallet.
+func CreateWallet(t *testing.T, repo domain.TransactionRepository, balance int64) domain.Wallet {
+	t.Helper()
+	ctx := t.Context()
+	user := domain.User{ID: uuid.New().String()}
+	user.Username = "user-" + user.ID
+	w := domain.Wallet{ID: uuid.New().String(), UserID: user.ID, Balance: balance, Version: 1}
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	require.NoError(t, repo.CreateWallet(ctx, tx, &user, &w))
+	require.NoError(t, repo.CommitTx(tx))
+	return w
+}
+
+// readWallet returns the committed state of the wallet owned by userID.
+func readWallet(t *testing.T, repo domain.TransactionRepository, userID string) *domain.Wallet {
+	t.Helper()
+	ctx := t.Context()
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+
+	w, err := repo.GetWalletForUpdate(ctx, tx, userID)
+	require.NoError(t, err)
+	return w
+}
+
+func newTransaction(sender, receiver domain.Wallet, reference string) *domain.Transaction {
+	return &domain.Transaction{
+		ID:         uuid.New().String(),
+		Reference:  reference,
+		SenderID:   sender.UserID,
+		ReceiverID: receiver.UserID,
+		Amount:     1,
+		Status:     "completed",
+		CreatedAt:  time.Now(),
+	}
+}
+
+func testCreateWallet(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 250)
+
+	got, err := repo.GetWalletByUserID(ctx, w.UserID)
+	require.NoError(t, err)
+	require.Equal(t, w.ID, got.ID)
+	require.Equal(t, int64(250), got.Balance)
+	require.Equal(t, 1, got.Version)
+
+	// Neither the user ID nor the username can be reused.
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	err = repo.CreateWallet(ctx, tx,
+		&domain.User{ID: w.UserID, Username: uuid.New().String()},
+		&domain.Wallet{ID: uuid.New().String(), UserID: w.UserID, Version: 1})
+	require.ErrorIs(t, err, domain.ErrUserExists)
+	require.NoError(t, repo.RollbackTx(tx))
+
+	tx, err = repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	other := uuid.New().String()
+	err = repo.CreateWallet(ctx, tx,
+		&domain.User{ID: other, Username: "user-" + w.UserID},
+		&domain.Wallet{ID: uuid.New().String(), UserID: other, Version: 1})
+	require.ErrorIs(t, err, domain.ErrUserExists)
+}
+
+// testCreateWalletWaitsForPendingUser checks that creating a user another
+// transaction is creating blocks until that transaction ends, and succeeds
+// when it rolls back.
+func testCreateWalletWaitsForPendingUser(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	user := domain.User{ID: uuid.New().String()}
+	user.Username = "user-" + user.ID
+
+	tx1, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx1)
+	require.NoError(t, repo.CreateWallet(ctx, tx1, &user, &domain.Wallet{ID: uuid.New().String(), UserID: user.ID, Version: 1}))
+
+	created := make(chan error, 1)
+	go func() {
+		tx2, err := repo.BeginTx(ctx)
+		if err != nil {
+			created <- err
+			return
+		}
+		defer repo.RollbackTx(tx2)
+		err = repo.CreateWallet(ctx, tx2, &user, &domain.Wallet{ID: uuid.New().String(), UserID: user.ID, Balance: 75, Version: 1})
+		if err == nil {
+			err = repo.CommitTx(tx2)
+		}
+		created <- err
+	}()
+
+	select {
+	case err := <-created:
+		t.Fatalf("second transaction did not wait for the first: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, repo.RollbackTx(tx1))
+
+	select {
+	case err := <-created:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second transaction did not go on after the first rolled back")
+	}
+	got, err := repo.GetWalletByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(75), got.Balance)
+}
+
+func testTopUpCommit(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 1000)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+
+	require.NoError(t, repo.TopUpWallet(ctx, tx, w.UserID, 500))
+	require.NoError(t, repo.CommitTx(tx))
+
+	got := readWallet(t, repo, w.UserID)
+	require.Equal(t, int64(1500), got.Balance)
+	require.Equal(t, w.Version+1, got.Version)
+}
+
+func testRollback(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	sender := CreateWallet(t, repo, 1000)
+	receiver := CreateWallet(t, repo, 0)
+	reference := uuid.New().String()
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateWalletBalance(ctx, tx, sender.ID, -300))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, tx, receiver.ID, 300))
+	require.NoError(t, repo.CreateTransaction(ctx, tx, newTransaction(sender, receiver, reference)))
+	require.NoError(t, repo.RollbackTx(tx))
+
+	require.Equal(t, int64(1000), readWallet(t, repo, sender.UserID).Balance)
+	require.Equal(t, int64(0), readWallet(t, repo, receiver.UserID).Balance)
+	_, err = repo.GetTransactionByRef(ctx, reference)
+	require.ErrorIs(t, err, domain.ErrTransactionNotFound)
+
+	// The reference is free again after the rollback.
+	tx, err = repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	require.NoError(t, repo.CreateTransaction(ctx, tx, newTransaction(sender, receiver, reference)))
+	require.NoError(t, repo.CommitTx(tx))
+
+	got, err := repo.GetTransactionByRef(ctx, reference)
+	require.NoError(t, err)
+	require.Equal(t, sender.UserID, got.SenderID)
+	require.Equal(t, receiver.UserID, got.ReceiverID)
+}
+
+func testGetWalletByUserID(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 1000)
+
+	got, err := repo.GetWalletByUserID(ctx, w.UserID)
+	require.NoError(t, err)
+	require.Equal(t, w.ID, got.ID)
+	require.Equal(t, w.UserID, got.UserID)
+	require.Equal(t, int64(1000), got.Balance)
+	require.Equal(t, w.Version, got.Version)
+	require.False(t, got.CreatedAt.IsZero())
+	require.False(t, got.UpdatedAt.Before(got.CreatedAt))
+
+	_, err = repo.GetWalletByUserID(ctx, uuid.New().String())
+	require.ErrorIs(t, err, domain.ErrWalletNotFound)
+	_, err = repo.GetWalletByUserID(ctx, "alice")
+	require.ErrorIs(t, err, domain.ErrWalletNotFound, "a malformed user ID names no wallet")
+}
+
+func testWalletNotFound(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	// "alice" is no UUID, which Postgres rejects instead of finding no row.
+	for _, userID := range []string{uuid.New().String(), "alice"} {
+		tx, err := repo.BeginTx(ctx)
+		require.NoError(t, err)
+		_, err = repo.GetWalletForUpdate(ctx, tx, userID)
+		repo.RollbackTx(tx)
+		require.ErrorIs(t, err, domain.ErrWalletNotFound, userID)
+	}
+}
+
+func testTransactionNotFound(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	_, err := repo.GetTransactionByRef(ctx, uuid.New().String())
+	require.ErrorIs(t, err, domain.ErrTransactionNotFound)
+}
+
+func testDuplicateReference(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	sender := CreateWallet(t, repo, 1000)
+	receiver := CreateWallet(t, repo, 0)
+	reference := uuid.New().String()
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	require.NoError(t, repo.CreateTransaction(ctx, tx, newTransaction(sender, receiver, reference)))
+	require.NoError(t, repo.CommitTx(tx))
+
+	tx2, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx2)
+	err = repo.CreateTransaction(ctx, tx2, newTransaction(sender, receiver, reference))
+	require.ErrorIs(t, err, domain.ErrDuplicateReference)
+}
+
+func testNegativeBalance(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 100)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	err = repo.UpdateWalletBalance(ctx, tx, w.ID, -101)
+	require.ErrorIs(t, err, domain.ErrNegativeBalance)
+	require.NoError(t, repo.RollbackTx(tx))
+
+	require.Equal(t, int64(100), readWallet(t, repo, w.UserID).Balance)
+}
+
+func testVersionConflict(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 100)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+
+	err = repo.UpdateWalletBalanceIfVersion(ctx, tx, w.ID, 10, w.Version+1)
+	require.ErrorIs(t, err, domain.ErrVersionConflict)
+	require.NoError(t, repo.UpdateWalletBalanceIfVersion(ctx, tx, w.ID, 10, w.Version))
+	require.NoError(t, repo.CommitTx(tx))
+
+	got := readWallet(t, repo, w.UserID)
+	require.Equal(t, int64(110), got.Balance)
+	require.Equal(t, w.Version+1, got.Version)
+}
+
+// testRowLockBlocksUntilCommit checks that a second transaction cannot lock a
+// wallet held by a first one and observes the first one's committed write
+// once it gets the lock.
+func testRowLockBlocksUntilCommit(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 100)
+
+	tx1, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx1)
+	_, err = repo.GetWalletForUpdate(ctx, tx1, w.UserID)
+	require.NoError(t, err)
+
+	type result struct {
+		wallet *domain.Wallet
+		err    error
+	}
+	locked := make(chan result, 1)
+	go func() {
+		tx2, err := repo.BeginTx(ctx)
+		if err != nil {
+			locked <- result{err: err}
+			return
+		}
+		defer repo.RollbackTx(tx2)
+		got, err := repo.GetWalletForUpdate(ctx, tx2, w.UserID)
+		locked <- result{wallet: got, err: err}
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second transaction acquired a wallet locked by the first")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, tx1, w.ID, 50))
+	require.NoError(t, repo.CommitTx(tx1))
+
+	select {
+	case res := <-locked:
+		require.NoError(t, res.err)
+		require.Equal(t, int64(150), res.wallet.Balance)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second transaction did not get the lock after the first committed")
+	}
+}
+
+// testConcurrentOppositeTransfers hammers two wallets with transfers in both
+// directions through the usecase and checks that none fail and no money is
+// created or lost.
+func testConcurrentOppositeTransfers(t *testing.T, repo domain.TransactionRepository) {
+	const (
+		initialBalance = int64(100000)
+		workers        = 8
+		transfersEach  = 25
+	)
+
+	ctx := t.Context()
+	a := CreateWallet(t, repo, initialBalance)
+	c := CreateWallet(t, repo, initialBalance)
+	uc := usecase.NewPaymentUsecase(repo)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*transfersEach)
+	for i := 0; i < workers; i++ {
+		sender, receiver := a.UserID, c.UserID
+		if i%2 == 1 {
+			sender, receiver = c.UserID, a.UserID
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < transfersEach; j++ {
+				_, err := uc.TransferFunds(ctx, usecase.TransferRequest{
+					SenderID:   sender,
+					ReceiverID: receiver,
+					Amount:     int64(1 + j),
+					Reference:  uuid.New().String(),
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	total := readWallet(t, repo, a.UserID).Balance + readWallet(t, repo, c.UserID).Balance
+	require.Equal(t, 2*initialBalance, total)
+}
+
+func testOneSidedTransaction(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 0)
+	credit := &domain.Transaction{
+		ID:         uuid.New().String(),
+		Reference:  uuid.New().String(),
+		Type:       domain.TransactionTypeTopUp,
+		ReceiverID: w.UserID,
+		Amount:     10,
+		Status:     "completed",
+		CreatedAt:  time.Now(),
+	}
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	require.NoError(t, repo.CreateTransaction(ctx, tx, credit))
+	require.NoError(t, repo.CommitTx(tx))
+
+	got, err := repo.GetTransactionByRef(ctx, credit.Reference)
+	require.NoError(t, err)
+	require.Equal(t, domain.TransactionTypeTopUp, got.Type)
+	require.Empty(t, got.SenderID)
+	require.Equal(t, w.UserID, got.ReceiverID)
+}
+
+func testListTransactions(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	a := CreateWallet(t, repo, 1000)
+	c := CreateWallet(t, repo, 1000)
+	other := CreateWallet(t, repo, 1000)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	start := time.Now().Add(-time.Hour)
+	for i, pair := range [][2]domain.Wallet{{a, c}, {c, a}, {other, c}} {
+		transaction := newTransaction(pair[0], pair[1], uuid.New().String())
+		transaction.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, repo.CreateTransaction(ctx, tx, transaction))
+	}
+	require.NoError(t, repo.CommitTx(tx))
+
+	got, err := repo.ListTransactions(ctx, domain.TransactionFilter{UserID: a.UserID})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, c.UserID, got[0].SenderID, "newest first")
+	require.Equal(t, domain.TransactionTypeTransfer, got[0].Type)
+
+	got, err = repo.ListTransactions(ctx, domain.TransactionFilter{UserID: c.UserID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, other.UserID, got[0].SenderID)
+
+	got, err = repo.ListTransactions(ctx, domain.TransactionFilter{UserID: a.UserID, Type: domain.TransactionTypeRefund})
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func testSetWalletStatus(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	w := CreateWallet(t, repo, 0)
+	got, err := repo.GetWalletByUserID(ctx, w.UserID)
+	require.NoError(t, err)
+	require.Equal(t, domain.WalletStatusActive, got.Status)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	require.NoError(t, repo.SetWalletStatus(ctx, tx, w.ID, domain.WalletStatusFrozen))
+	require.NoError(t, repo.CommitTx(tx))
+
+	got = readWallet(t, repo, w.UserID)
+	require.Equal(t, domain.WalletStatusFrozen, got.Status)
+	require.Equal(t, w.Version+1, got.Version)
+}
+
+func testLedger(t *testing.T, repo domain.TransactionRepository) {
+	ctx := t.Context()
+	a := CreateWallet(t, repo, 100)
+	c := CreateWallet(t, repo, 0)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	defer repo.RollbackTx(tx)
+	credit := newTransaction(a, c, uuid.New().String())
+	credit.SenderID, credit.Amount = "", 7
+	require.NoError(t, repo.CreateTransaction(ctx, tx, credit))
+	debit := newTransaction(a, c, uuid.New().String())
+	debit.ReceiverID, debit.Amount = "", 2
+	require.NoError(t, repo.CreateTransaction(ctx, tx, debit))
+	transfer := newTransaction(a, c, uuid.New().String())
+	transfer.Amount = 30
+	require.NoError(t, repo.CreateTransaction(ctx, tx, transfer))
+	pending := newTransaction(c, a, uuid.New().String())
+	pending.Status = "pending"
+	require.NoError(t, repo.CreateTransaction(ctx, tx, pending))
+	require.NoError(t, repo.CommitTx(tx))
+
+	ledger, err := repo.GetLedger(ctx)
+	require.NoError(t, err)
+	byUser := make(map[string]domain.WalletLedger)
+	for _, l := range ledger.Wallets {
+		byUser[l.UserID] = l
+	}
+	require.Equal(t, domain.WalletLedger{WalletID: a.ID, UserID: a.UserID, Balance: 100, Debits: 32}, byUser[a.UserID])
+	require.Equal(t, domain.WalletLedger{WalletID: c.ID, UserID: c.UserID, Balance: 0, Credits: 37}, byUser[c.UserID])
+	require.Equal(t, int64(7), ledger.Credits)
+	require.Equal(t, int64(2), ledger.Debits)
+}
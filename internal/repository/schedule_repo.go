@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"time"
+)
+
+// ScheduleRepo stores scheduled transfers in the schedules table. Its SQL
+// works on both Postgres and SQLite.
+type ScheduleRepo struct {
+	db *sql.DB
+}
+
+func NewScheduleRepo(db *sql.DB) domain.ScheduleRepository {
+	return &ScheduleRepo{db: db}
+}
+
+const scheduleColumns = `id, reference, sender_id, receiver_id, amount, recurrence, start_at, on_insufficient_balance,
+	status, occurrence, next_run_at, attempts, completed, skipped, last_error, locked_until, version, created_at, updated_at`
+
+func (r *ScheduleRepo) CreateSchedule(ctx context.Context, s *domain.Schedule) error {
+	query := `INSERT INTO schedules (` + scheduleColumns + `)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`
+	_, err := r.db.ExecContext(ctx, query, s.ID, s.Reference, s.SenderID, s.ReceiverID, s.Amount, s.Recurrence,
+		s.StartAt.UTC(), s.OnInsufficientBalance, s.Status, s.Occurrence, s.NextRunAt.UTC(), s.Attempts,
+		s.Completed, s.Skipped, s.LastError, s.LockedUntil.UTC(), s.Version, s.CreatedAt.UTC(), s.UpdatedAt.UTC())
+	return mapDriverError(err)
+}
+
+func (r *ScheduleRepo) GetSchedule(ctx context.Context, id string) (*domain.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+	s, err := scanSchedule(r.db.QueryRowContext(ctx, query, id))
+	err = mapDriverError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrScheduleNotFound, err)
+	}
+	return s, err
+}
+
+// ClaimDueSchedule picks the schedule due the longest and locks it with a
+// conditional UPDATE on its version. If another instance claimed it in
+// between, the next one is tried.
+func (r *ScheduleRepo) ClaimDueSchedule(ctx context.Context, now, lockUntil time.Time) (*domain.Schedule, error) {
+	for {
+		var id string
+		var version int
+		err := r.db.QueryRowContext(ctx,
+			`SELECT id, version FROM schedules
+             WHERE status = $1 AND next_run_at <= $2 AND locked_until <= $2
+             ORDER BY next_run_at, id LIMIT 1`,
+			domain.ScheduleStatusActive, now.UTC()).Scan(&id, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrScheduleNotFound
+		}
+		if err != nil {
+			return nil, mapDriverError(err)
+		}
+
+		res, err := r.db.ExecContext(ctx,
+			`UPDATE schedules SET locked_until = $1, version = version + 1 WHERE id = $2 AND version = $3`,
+			lockUntil.UTC(), id, version)
+		if err != nil {
+			return nil, mapDriverError(err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return r.GetSchedule(ctx, id)
+		}
+	}
+}
+
+func (r *ScheduleRepo) UpdateSchedule(ctx context.Context, s *domain.Schedule) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE schedules SET status = $1, occurrence = $2, next_run_at = $3, attempts = $4, completed = $5,
+             skipped = $6, last_error = $7, locked_until = $8, version = version + 1, updated_at = $9
+         WHERE id = $10 AND version = $11`,
+		s.Status, s.Occurrence, s.NextRunAt.UTC(), s.Attempts, s.Completed, s.Skipped, s.LastError,
+		s.LockedUntil.UTC(), s.UpdatedAt.UTC(), s.ID, s.Version)
+	if err != nil {
+		return mapDriverError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := r.GetSchedule(ctx, s.ID); err != nil {
+			return err
+		}
+		return domain.ErrScheduleConflict
+	}
+	s.Version++
+	return nil
+}
+
+func scanSchedule(row rowScanner) (*domain.Schedule, error) {
+	var s domain.Schedule
+	err := row.Scan(&s.ID, &s.Reference, &s.SenderID, &s.ReceiverID, &s.Amount, &s.Recurrence, &s.StartAt,
+		&s.OnInsufficientBalance, &s.Status, &s.Occurrence, &s.NextRunAt, &s.Attempts, &s.Completed, &s.Skipped,
+		&s.LastError, &s.LockedUntil, &s.Version, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
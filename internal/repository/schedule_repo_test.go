@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/migrations"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRepo(t *testing.T) {
+	ctx := t.Context()
+	backends := map[string]func(t *testing.T) domain.ScheduleRepository{
+		"Memory": func(t *testing.T) domain.ScheduleRepository {
+			return NewMemoryScheduleRepo()
+		},
+		"SQLite": func(t *testing.T) domain.ScheduleRepository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+			require.NoError(t, err)
+			_, err = m.Up(context.Background())
+			require.NoError(t, err)
+			return NewScheduleRepo(db)
+		},
+		"Postgres": func(t *testing.T) domain.ScheduleRepository {
+			requirePostgres(t)
+			_, err := testDB.Exec("DELETE FROM schedules")
+			require.NoError(t, err)
+			return NewScheduleRepo(testDB)
+		},
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			r := newRepo(t)
+			now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+			newSchedule := func(reference string, next time.Time) *domain.Schedule {
+				return &domain.Schedule{
+					ID:                    uuid.New().String(),
+					Reference:             reference,
+					SenderID:              uuid.New().String(),
+					ReceiverID:            uuid.New().String(),
+					Amount:                100,
+					Recurrence:            "FREQ=MONTHLY",
+					StartAt:               next,
+					OnInsufficientBalance: domain.ScheduleRetry,
+					Status:                domain.ScheduleStatusActive,
+					Occurrence:            1,
+					NextRunAt:             next,
+					CreatedAt:             now,
+					UpdatedAt:             now,
+				}
+			}
+			overdue := newSchedule("allowance-1", now.Add(-time.Hour))
+			due := newSchedule("allowance-2", now.Add(-time.Millisecond))
+			future := newSchedule("allowance-3", now.Add(time.Hour))
+			for _, s := range []*domain.Schedule{future, due, overdue} {
+				require.NoError(t, r.CreateSchedule(ctx, s))
+			}
+
+			err := r.CreateSchedule(ctx, newSchedule("allowance-1", now))
+			require.ErrorIs(t, err, domain.ErrDuplicateScheduleReference)
+			_, err = r.GetSchedule(ctx, uuid.New().String())
+			require.ErrorIs(t, err, domain.ErrScheduleNotFound)
+			_, err = r.GetSchedule(ctx, "abc")
+			require.ErrorIs(t, err, domain.ErrScheduleNotFound, "a malformed ID names no row")
+
+			got, err := r.GetSchedule(ctx, due.ID)
+			require.NoError(t, err)
+			require.Equal(t, due.Recurrence, got.Recurrence)
+			require.True(t, due.NextRunAt.Equal(got.NextRunAt))
+			require.True(t, got.LockedUntil.IsZero())
+
+			lockUntil := now.Add(time.Minute)
+			claimed, err := r.ClaimDueSchedule(ctx, now, lockUntil)
+			require.NoError(t, err)
+			require.Equal(t, overdue.ID, claimed.ID, "the schedule due the longest is claimed first")
+			require.True(t, lockUntil.Equal(claimed.LockedUntil))
+			require.Equal(t, 1, claimed.Version)
+
+			claimed2, err := r.ClaimDueSchedule(ctx, now, lockUntil)
+			require.NoError(t, err)
+			require.Equal(t, due.ID, claimed2.ID, "a locked schedule is not claimed again")
+			_, err = r.ClaimDueSchedule(ctx, now, lockUntil)
+			require.ErrorIs(t, err, domain.ErrScheduleNotFound)
+
+			stale := *claimed
+			claimed.Occurrence = 2
+			claimed.Completed = 1
+			claimed.NextRunAt = now.Add(30 * 24 * time.Hour)
+			claimed.LockedUntil = time.Time{}
+			claimed.UpdatedAt = now
+			require.NoError(t, r.UpdateSchedule(ctx, claimed))
+			require.Equal(t, 2, claimed.Version)
+			require.ErrorIs(t, r.UpdateSchedule(ctx, &stale), domain.ErrScheduleConflict)
+			require.ErrorIs(t, r.UpdateSchedule(ctx, newSchedule("missing", now)), domain.ErrScheduleNotFound)
+
+			got, err = r.GetSchedule(ctx, overdue.ID)
+			require.NoError(t, err)
+			require.Equal(t, 2, got.Occurrence)
+			require.Equal(t, 1, got.Completed)
+			require.True(t, got.LockedUntil.IsZero())
+
+			// Expired locks are claimed again.
+			claimed, err = r.ClaimDueSchedule(ctx, lockUntil, lockUntil.Add(time.Minute))
+			require.NoError(t, err)
+			require.Equal(t, due.ID, claimed.ID)
+		})
+	}
+}
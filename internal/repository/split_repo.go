@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+)
+
+// SplitRepo stores split bills in the splits table. Its SQL works on both
+// Postgres and SQLite. Shares are stored as a JSON array.
+type SplitRepo struct {
+	db *sql.DB
+}
+
+func NewSplitRepo(db *sql.DB) domain.SplitRepository {
+	return &SplitRepo{db: db}
+}
+
+const splitColumns = `id, organizer_id, description, total, method, status, shares, version, created_at, updated_at`
+
+func (r *SplitRepo) CreateSplit(ctx context.Context, split *domain.Split) error {
+	shares, err := json.Marshal(split.Shares)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO splits (` + splitColumns + `)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = r.db.ExecContext(ctx, query, split.ID, split.OrganizerID, split.Description, split.Total, split.Method,
+		split.Status, string(shares), split.Version, split.CreatedAt.UTC(), split.UpdatedAt.UTC())
+	return mapDriverError(err)
+}
+
+func (r *SplitRepo) GetSplit(ctx context.Context, id string) (*domain.Split, error) {
+	query := `SELECT ` + splitColumns + ` FROM splits WHERE id = $1`
+	split, err := scanSplit(r.db.QueryRowContext(ctx, query, id))
+	err = mapDriverError(err)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, domain.ErrMalformedID) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrSplitNotFound, err)
+	}
+	return split, err
+}
+
+func (r *SplitRepo) UpdateSplit(ctx context.Context, split *domain.Split) error {
+	shares, err := json.Marshal(split.Shares)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE splits SET status = $1, shares = $2, version = version + 1, updated_at = $3
+         WHERE id = $4 AND version = $5`,
+		split.Status, string(shares), split.UpdatedAt.UTC(), split.ID, split.Version)
+	if err != nil {
+		return mapDriverError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := r.GetSplit(ctx, split.ID); err != nil {
+			return err
+		}
+		return domain.ErrSplitConflict
+	}
+	split.Version++
+	return nil
+}
+
+func scanSplit(row rowScanner) (*domain.Split, error) {
+	var split domain.Split
+	var shares string
+	err := row.Scan(&split.ID, &split.OrganizerID, &split.Description, &split.Total, &split.Method, &split.Status,
+		&shares, &split.Version, &split.CreatedAt, &split.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(shares), &split.Shares); err != nil {
+		return nil, fmt.Errorf("invalid shares of split %s: %w", split.ID, err)
+	}
+	return &split, nil
+}
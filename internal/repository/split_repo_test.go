@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/migrations"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRepo(t *testing.T) {
+	ctx := t.Context()
+	backends := map[string]func(t *testing.T) domain.SplitRepository{
+		"Memory": func(t *testing.T) domain.SplitRepository {
+			return NewMemorySplitRepo()
+		},
+		"SQLite": func(t *testing.T) domain.SplitRepository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+			require.NoError(t, err)
+			_, err = m.Up(context.Background())
+			require.NoError(t, err)
+			return NewSplitRepo(db)
+		},
+		"Postgres": func(t *testing.T) domain.SplitRepository {
+			requirePostgres(t)
+			_, err := testDB.Exec("DELETE FROM splits")
+			require.NoError(t, err)
+			return NewSplitRepo(testDB)
+		},
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			r := newRepo(t)
+			now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+			split := &domain.Split{
+				ID:          uuid.New().String(),
+				OrganizerID: "alice",
+				Description: "dinner",
+				Total:       100,
+				Method:      domain.SplitMethodEqual,
+				Status:      domain.SplitStatusOpen,
+				Shares: []domain.SplitShare{
+					{ParticipantID: "alice", Amount: 34, Status: domain.SplitShareStatusPaid},
+					{ParticipantID: "bob", Amount: 33, Reference: "split-1-2", Status: domain.SplitShareStatusPending},
+					{ParticipantID: "carol", Amount: 33, Reference: "split-1-3", Status: domain.SplitShareStatusPending},
+				},
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			require.NoError(t, r.CreateSplit(ctx, split))
+
+			_, err := r.GetSplit(ctx, uuid.New().String())
+			require.ErrorIs(t, err, domain.ErrSplitNotFound)
+			_, err = r.GetSplit(ctx, "abc")
+			require.ErrorIs(t, err, domain.ErrSplitNotFound, "a malformed ID names no row")
+			got, err := r.GetSplit(ctx, split.ID)
+			require.NoError(t, err)
+			require.Equal(t, split.Shares, got.Shares)
+			require.Equal(t, "dinner", got.Description)
+
+			stale := *got
+			got.Shares[1].Status = domain.SplitShareStatusPaid
+			got.UpdatedAt = now.Add(time.Minute)
+			require.NoError(t, r.UpdateSplit(ctx, got))
+			require.Equal(t, 1, got.Version)
+			require.ErrorIs(t, r.UpdateSplit(ctx, &stale), domain.ErrSplitConflict)
+			missing := *split
+			missing.ID = uuid.New().String()
+			require.ErrorIs(t, r.UpdateSplit(ctx, &missing), domain.ErrSplitNotFound)
+
+			got, err = r.GetSplit(ctx, split.ID)
+			require.NoError(t, err)
+			require.Equal(t, domain.SplitShareStatusPaid, got.Shares[1].Status)
+			require.Equal(t, domain.SplitShareStatusPending, got.Shares[2].Status)
+			require.Equal(t, 1, got.Version)
+		})
+	}
+}
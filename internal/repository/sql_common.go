@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"payment-service/internal/domain"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Queries and helpers shared by the Postgres and SQLite repositories. They only
+// use SQL both dialects understand.
+
+const transactionColumns = `id, reference_id, type, sender_id, receiver_id, amount, status, created_at`
+
+// ledgerQuery sums the completed transactions crediting and debiting every
+// wallet.
+const ledgerQuery = `SELECT w.id, w.user_id, w.balance,
+       COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.receiver_id = w.user_id AND t.status = 'completed'), 0),
+       COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.sender_id = w.user_id AND t.status = 'completed'), 0)
+FROM wallets w
+ORDER BY w.user_id`
+
+// externalFlowsQuery sums the completed transactions that have only a
+// receiver (money entering the system) or only a sender (money leaving it).
+const externalFlowsQuery = `SELECT
+       COALESCE(SUM(CASE WHEN sender_id IS NULL THEN amount ELSE 0 END), 0),
+       COALESCE(SUM(CASE WHEN receiver_id IS NULL THEN amount ELSE 0 END), 0)
+FROM transactions
+WHERE status = 'completed'`
+
+// openDB opens a database whose statements are traced as spans of the global
+// tracer provider, children of the span in the context they run with. system
+// is the db.system.name attribute of the spans.
+func openDB(driverName, dsn string, system attribute.KeyValue) (*sql.DB, error) {
+	db, err := otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// mapDriverError applies the error mapping of whichever driver err comes
+// from, for repositories shared by Postgres and SQLite; each mapper leaves
+// the other driver's errors alone.
+func mapDriverError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return mapSQLiteError(mapPgError(err))
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTransaction scans transactionColumns. Credits have no sender and debits
+// no receiver; both are returned as empty strings.
+func scanTransaction(row rowScanner) (*domain.Transaction, error) {
+	var t domain.Transaction
+	var sender, receiver sql.NullString
+	err := row.Scan(&t.ID, &t.Reference, &t.Type, &sender, &receiver, &t.Amount, &t.Status, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.SenderID = sender.String
+	t.ReceiverID = receiver.String
+	return &t, nil
+}
+
+func listTransactions(ctx context.Context, db *sql.DB, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("(sender_id = $%d OR receiver_id = $%d)", len(args), len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	query := `SELECT ` + transactionColumns + ` FROM transactions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []domain.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, rows.Err()
+}
+
+// getLedger reads the ledger within tx, which must see a single snapshot of
+// the database for the result to be consistent.
+func getLedger(ctx context.Context, tx *sql.Tx) (*domain.Ledger, error) {
+	rows, err := tx.QueryContext(ctx, ledgerQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ledger := &domain.Ledger{}
+	for rows.Next() {
+		var l domain.WalletLedger
+		if err := rows.Scan(&l.WalletID, &l.UserID, &l.Balance, &l.Credits, &l.Debits); err != nil {
+			return nil, err
+		}
+		ledger.Wallets = append(ledger.Wallets, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, externalFlowsQuery).Scan(&ledger.Credits, &ledger.Debits)
+	if err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"payment-service/internal/domain"
+	"strings"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteRepo is a domain.TransactionRepository backed by a single SQLite
+// database file. SQLite has no row locks, so money movement relies on every
+// transaction being started with BEGIN IMMEDIATE: the database write lock is
+// taken up front and concurrent transactions are fully serialized. Use
+// OpenSQLite to get a *sql.DB configured that way.
+type SQLiteRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepo(db *sql.DB) domain.TransactionRepository {
+	return &SQLiteRepo{db: db}
+}
+
+// OpenSQLite opens the database at path with immediate transactions, foreign
+// keys, WAL journaling and a busy timeout. The schema is managed by the
+// migrate package like for Postgres. Statements are traced, see openDB.
+func OpenSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+
+	return openDB("sqlite", "file:"+path+"?"+params.Encode(), semconv.DBSystemNameSQLite)
+}
+
+func (r *SQLiteRepo) BeginTx(ctx context.Context) (interface{}, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	return tx, nil
+}
+
+func (r *SQLiteRepo) CommitTx(tx interface{}) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+	return mapSQLiteError(sqlTx.Commit())
+}
+
+func (r *SQLiteRepo) RollbackTx(tx interface{}) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+	return sqlTx.Rollback()
+}
+
+// GetWalletForUpdate is a plain SELECT: the IMMEDIATE transaction already
+// holds the database write lock.
+func (r *SQLiteRepo) GetWalletForUpdate(ctx context.Context, tx interface{}, userID string) (*domain.Wallet, error) {
+	sqlTx := tx.(*sql.Tx)
+	query := `SELECT id, user_id, balance, version, status FROM wallets WHERE user_id = $1`
+
+	row := sqlTx.QueryRowContext(ctx, query, userID)
+	var w domain.Wallet
+	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version, &w.Status)
+	if err != nil {
+		return nil, mapSQLiteWalletError(err)
+	}
+	return &w, nil
+}
+
+func (r *SQLiteRepo) UpdateWalletBalance(ctx context.Context, tx interface{}, walletID string, amount int64) error {
+	sqlTx := tx.(*sql.Tx)
+	query := `UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := sqlTx.ExecContext(ctx, query, amount, walletID)
+	return mapSQLiteError(err)
+}
+
+// UpdateWalletBalanceIfVersion applies the balance change only if the wallet is
+// still at expectedVersion and returns domain.ErrVersionConflict otherwise.
+func (r *SQLiteRepo) UpdateWalletBalanceIfVersion(ctx context.Context, tx interface{}, walletID string, amount int64, expectedVersion int) error {
+	sqlTx := tx.(*sql.Tx)
+	query := `UPDATE wallets SET balance = balance + $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND version = $3`
+	res, err := sqlTx.ExecContext(ctx, query, amount, walletID, expectedVersion)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *SQLiteRepo) CreateTransaction(ctx context.Context, tx interface{}, t *domain.Transaction) error {
+	sqlTx := tx.(*sql.Tx)
+	if t.Type == "" {
+		t.Type = domain.TransactionTypeTransfer
+	}
+	query := `INSERT INTO transactions (id, reference_id, type, sender_id, receiver_id, amount, status, created_at) 
+              VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)`
+	_, err := sqlTx.ExecContext(ctx, query, t.ID, t.Reference, t.Type, t.SenderID, t.ReceiverID, t.Amount, t.Status, t.CreatedAt)
+	return mapSQLiteError(err)
+}
+
+func (r *SQLiteRepo) GetTransactionByRef(ctx context.Context, refID string) (*domain.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE reference_id = $1`
+	t, err := scanTransaction(r.db.QueryRowContext(ctx, query, refID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrTransactionNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *SQLiteRepo) TopUpWallet(ctx context.Context, tx interface{}, userID string, amount int64) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+
+	wallet, err := r.GetWalletForUpdate(ctx, sqlTx, userID)
+	if err != nil {
+		return err
+	}
+
+	return r.UpdateWalletBalance(ctx, sqlTx, wallet.ID, amount)
+}
+
+// CreateWallet inserts user and its wallet. The wallet's timestamps are set
+// to the current time.
+func (r *SQLiteRepo) CreateWallet(ctx context.Context, tx interface{}, user *domain.User, wallet *domain.Wallet) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+
+	_, err := sqlTx.ExecContext(ctx, `INSERT INTO users (id, username) VALUES ($1, $2)`, user.ID, user.Username)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	if wallet.Status == "" {
+		wallet.Status = domain.WalletStatusActive
+	}
+	wallet.CreatedAt = time.Now().UTC()
+	wallet.UpdatedAt = wallet.CreatedAt
+	query := `INSERT INTO wallets (id, user_id, balance, version, status, created_at, updated_at) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = sqlTx.ExecContext(ctx, query, wallet.ID, wallet.UserID, wallet.Balance, wallet.Version, wallet.Status, wallet.CreatedAt, wallet.UpdatedAt)
+	return mapSQLiteError(err)
+}
+
+func (r *SQLiteRepo) GetWalletByUserID(ctx context.Context, userID string) (*domain.Wallet, error) {
+	query := `SELECT id, user_id, balance, version, status, created_at, updated_at FROM wallets WHERE user_id = $1`
+	row := r.db.QueryRowContext(ctx, query, userID)
+	var w domain.Wallet
+	err := row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Version, &w.Status, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, mapSQLiteWalletError(err)
+	}
+	return &w, nil
+}
+
+// SetWalletStatus changes the status of the wallet and bumps its version.
+func (r *SQLiteRepo) SetWalletStatus(ctx context.Context, tx interface{}, walletID string, status string) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("invalid transaction type")
+	}
+
+	query := `UPDATE wallets SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	res, err := sqlTx.ExecContext(ctx, query, status, walletID)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteRepo) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	return listTransactions(ctx, r.db, filter)
+}
+
+// GetLedger reads the ledger in a transaction so that the per-wallet sums and
+// the totals come from the same snapshot.
+func (r *SQLiteRepo) GetLedger(ctx context.Context) (*domain.Ledger, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	defer tx.Rollback()
+	return getLedger(ctx, tx)
+}
+
+// mapSQLiteError translates SQLite result codes into the domain errors the
+// Postgres repository reports for the same conditions. A busy or locked
+// database after the busy timeout is treated as a retryable conflict.
+func mapSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch code := sqliteErr.Code(); {
+	case code&0xff == sqlite3.SQLITE_BUSY, code&0xff == sqlite3.SQLITE_LOCKED:
+		return fmt.Errorf("%w: %v", domain.ErrTxConflict, err)
+	case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(err.Error(), "reference_id"):
+		return fmt.Errorf("%w: %v", domain.ErrDuplicateReference, err)
+	case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(err.Error(), "batches.reference"):
+		return fmt.Errorf("%w: %v", domain.ErrDuplicateBatchReference, err)
+	case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(err.Error(), "schedules.reference"):
+		return fmt.Errorf("%w: %v", domain.ErrDuplicateScheduleReference, err)
+	case (code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) &&
+		(strings.Contains(err.Error(), "users.") || strings.Contains(err.Error(), "wallets.user_id")):
+		return fmt.Errorf("%w: %v", domain.ErrUserExists, err)
+	case code == sqlite3.SQLITE_CONSTRAINT_CHECK && strings.Contains(err.Error(), "balance"):
+		return fmt.Errorf("%w: %v", domain.ErrNegativeBalance, err)
+	}
+	return err
+}
+
+func mapSQLiteWalletError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %v", domain.ErrWalletNotFound, err)
+	}
+	return mapSQLiteError(err)
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"payment-service/internal/domain"
+	"payment-service/internal/migrate"
+	"payment-service/internal/repository/repositorytest"
+	"payment-service/migrations"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteRepo(t *testing.T) domain.TransactionRepository {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "payment.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, migrate.SQLite, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	return NewSQLiteRepo(db)
+}
+
+func TestSQLiteRepo_Contract(t *testing.T) {
+	repositorytest.Run(t, newSQLiteRepo)
+}
@@ -0,0 +1,132 @@
+// Package seed generates users and wallets for local development and load
+// testing. Generation is deterministic: the same profile, user count and seed
+// always produce the same IDs, usernames and balances, so seeding can be
+// repeated safely.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// namespace scopes the name-based UUIDs of generated users and wallets.
+var namespace = uuid.MustParse("6f1d7c52-9b1e-4c47-8f0a-3a5e2d9c4b10")
+
+// Profile describes a set of users to seed.
+type Profile struct {
+	Name string
+
+	// Fixtures are always created, before any generated users.
+	Fixtures []usecase.CreateWalletRequest
+
+	// Users is the number of generated users when Options.Users is zero.
+	Users int
+
+	// Generated balances are uniformly distributed in [MinBalance, MaxBalance].
+	MinBalance int64
+	MaxBalance int64
+}
+
+// Profiles lists the built-in profiles by name.
+var Profiles = map[string]Profile{
+	"demo": {
+		Name: "demo",
+		Fixtures: []usecase.CreateWalletRequest{
+			{UserID: "11111111-1111-1111-1111-111111111111", Username: "alice", WalletID: "aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa", Balance: 1000000},
+			{UserID: "22222222-2222-2222-2222-222222222222", Username: "bob", WalletID: "bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb", Balance: 50000},
+		},
+		Users:      8,
+		MaxBalance: 100000,
+	},
+	"loadtest": {
+		Name:       "loadtest",
+		Users:      1000,
+		MinBalance: 100000,
+		MaxBalance: 10000000,
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles in sorted order.
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Options struct {
+	Profile string
+
+	// Users overrides the number of generated users of the profile.
+	Users int
+
+	// Seed selects the generated users. Different seeds produce disjoint
+	// sets of users.
+	Seed uint64
+}
+
+// Result summarizes a seeding run.
+type Result struct {
+	Created int
+	Skipped int
+}
+
+// Users returns the wallets Run would create for opts.
+func Users(opts Options) ([]usecase.CreateWalletRequest, error) {
+	profile, ok := Profiles[opts.Profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown seed profile %q", opts.Profile)
+	}
+	if opts.Users < 0 {
+		return nil, fmt.Errorf("number of users must not be negative")
+	}
+	n := profile.Users
+	if opts.Users > 0 {
+		n = opts.Users
+	}
+
+	users := append([]usecase.CreateWalletRequest(nil), profile.Fixtures...)
+	rng := rand.New(rand.NewPCG(opts.Seed, 0))
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("%s/%d/%d", profile.Name, opts.Seed, i)
+		users = append(users, usecase.CreateWalletRequest{
+			UserID:   uuid.NewSHA1(namespace, []byte("user/"+key)).String(),
+			Username: fmt.Sprintf("%s-%d-%d", profile.Name, opts.Seed, i),
+			WalletID: uuid.NewSHA1(namespace, []byte("wallet/"+key)).String(),
+			Balance:  profile.MinBalance + rng.Int64N(profile.MaxBalance-profile.MinBalance+1),
+		})
+	}
+	return users, nil
+}
+
+// Run creates the users selected by opts. Users that already exist are
+// skipped, so running the same seed twice leaves the first run's data as is.
+func Run(ctx context.Context, uc *usecase.PaymentUsecase, opts Options) (*Result, error) {
+	users, err := Users(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, req := range users {
+		_, err := uc.CreateWallet(ctx, req)
+		if errors.Is(err, domain.ErrUserExists) {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to create user %s: %w", req.Username, err)
+		}
+		result.Created++
+	}
+	return result, nil
+}
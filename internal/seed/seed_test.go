@@ -0,0 +1,59 @@
+package seed
+
+import (
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers_Deterministic(t *testing.T) {
+	opts := Options{Profile: "loadtest", Users: 50, Seed: 42}
+
+	first, err := Users(opts)
+	require.NoError(t, err)
+	second, err := Users(opts)
+	require.NoError(t, err)
+	require.Len(t, first, 50)
+	require.Equal(t, first, second)
+
+	profile := Profiles["loadtest"]
+	for _, u := range first {
+		require.GreaterOrEqual(t, u.Balance, profile.MinBalance)
+		require.LessOrEqual(t, u.Balance, profile.MaxBalance)
+	}
+
+	other, err := Users(Options{Profile: "loadtest", Users: 50, Seed: 43})
+	require.NoError(t, err)
+	for i := range first {
+		require.NotEqual(t, first[i].UserID, other[i].UserID)
+		require.NotEqual(t, first[i].Username, other[i].Username)
+	}
+}
+
+func TestUsers_Errors(t *testing.T) {
+	_, err := Users(Options{Profile: "production"})
+	require.ErrorContains(t, err, "unknown seed profile")
+
+	_, err = Users(Options{Profile: "demo", Users: -1})
+	require.Error(t, err)
+}
+
+func TestRun_Idempotent(t *testing.T) {
+	ctx := t.Context()
+	uc := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	opts := Options{Profile: "demo", Users: 3, Seed: 1}
+
+	result, err := Run(ctx, uc, opts)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Created: 5}, result)
+
+	result, err = Run(ctx, uc, opts)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Skipped: 5}, result)
+
+	alice, err := uc.GetWallet(ctx, "11111111-1111-1111-1111-111111111111")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000000), alice.Balance)
+}
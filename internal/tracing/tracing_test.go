@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"payment-service/internal/app"
+	"payment-service/internal/config"
+	"payment-service/internal/delivery"
+	"payment-service/internal/usecase"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	recorder = tracetest.NewSpanRecorder()
+	provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+)
+
+// TestMain installs the recording provider before anything asks for a
+// tracer: tracers obtained from the global provider before the first
+// SetTracerProvider, like the usecase one, stay bound to that first provider.
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+func TestMiddleware_TracesRequestThroughUsecaseAndSQL(t *testing.T) {
+	otel.SetTracerProvider(provider)
+
+	cfg := config.Default()
+	cfg.Backend = "sqlite"
+	cfg.SQLitePath = filepath.Join(t.TempDir(), "payment.db")
+	store, err := app.Open(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	m, err := store.Migrator()
+	require.NoError(t, err)
+	_, err = m.Up(t.Context())
+	require.NoError(t, err)
+
+	uc := app.NewUsecase(cfg, store.Repo)
+	_, err = uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: "alice", Username: "alice", Balance: 100})
+	require.NoError(t, err)
+
+	handler := delivery.NewHttpHandler(uc)
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/wallet/{userId}", handler.GetWallet)
+
+	recorder.Reset()
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/wallet/alice", nil)
+	req.Header.Set("traceparent", traceparent)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	server, ok := spans["GET /wallet/{userId}"]
+	require.True(t, ok, "no server span among %v", spanNames(recorder.Ended()))
+	require.Equal(t, trace.SpanKindServer, server.SpanKind())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	require.True(t, server.Parent().IsRemote())
+
+	getWallet, ok := spans["usecase.GetWallet"]
+	require.True(t, ok, "no usecase span among %v", spanNames(recorder.Ended()))
+	require.Equal(t, server.SpanContext().SpanID(), getWallet.Parent().SpanID())
+	require.Contains(t, getWallet.Attributes(), attribute.String("payment.user_id", usecase.RedactID("alice")),
+		"user IDs are redacted like in logs")
+
+	var sqlSpans int
+	for _, s := range recorder.Ended() {
+		if s.Parent().SpanID() == getWallet.SpanContext().SpanID() && s.Name() != "usecase.GetWallet" {
+			sqlSpans++
+		}
+	}
+	require.NotZero(t, sqlSpans, "no SQL span under the usecase span among %v", spanNames(recorder.Ended()))
+}
+
+func TestMiddleware_FailedRequest(t *testing.T) {
+	otel.SetTracerProvider(provider)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	recorder.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /fail", spans[0].Name())
+	require.False(t, spans[0].Parent().IsValid())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	defer otel.SetTracerProvider(provider)
+
+	cfg := config.Default().Tracing
+	cfg.Exporter = "stdout"
+	var out bytes.Buffer
+	shutdown, err := Setup(context.Background(), cfg, &out)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+	require.Contains(t, out.String(), `"Name":"exported"`)
+	require.Contains(t, out.String(), "payment-service")
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+	}
+	return names
+}
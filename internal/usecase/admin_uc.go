@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"payment-service/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Operations for operators, used by cmd/paymentctl. Adjustments and refunds
+// are corrections and therefore also apply to frozen wallets.
+
+var (
+	ErrNotRefundable     = errors.New("only completed transfers can be refunded")
+	ErrAlreadyRefunded   = errors.New("transfer has already been refunded")
+	ErrInvalidAdjustment = errors.New("adjustment amount must not be zero")
+)
+
+// DefaultListLimit is the number of transactions ListTransactions returns
+// when no limit is given.
+const DefaultListLimit = 50
+
+// TransactionDetails describes a transaction of any type. SenderID is empty
+// for credits and ReceiverID for debits.
+type TransactionDetails struct {
+	TransactionID string    `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	Type          string    `json:"type"`
+	SenderID      string    `json:"sender_id,omitempty"`
+	ReceiverID    string    `json:"receiver_id,omitempty"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ListTransactionsRequest struct {
+	UserID string
+	Type   string
+	Limit  int
+}
+
+// AdjustmentRequest credits (positive Amount) or debits (negative Amount) a
+// wallet outside of a transfer. Reference is generated when empty.
+type AdjustmentRequest struct {
+	UserID    string
+	Amount    int64
+	Reference string
+}
+
+// LedgerReport is the result of CheckLedger.
+type LedgerReport struct {
+	Wallets       int                 `json:"wallets"`
+	TotalBalance  int64               `json:"total_balance"`
+	ExpectedTotal int64               `json:"expected_total"`
+	Discrepancies []LedgerDiscrepancy `json:"discrepancies"`
+}
+
+// Consistent reports whether money is conserved and every wallet balance
+// matches its transactions.
+func (r *LedgerReport) Consistent() bool {
+	return r.TotalBalance == r.ExpectedTotal && len(r.Discrepancies) == 0
+}
+
+// LedgerDiscrepancy is a wallet whose balance differs from the sum of its
+// transactions.
+type LedgerDiscrepancy struct {
+	WalletID string `json:"wallet_id"`
+	UserID   string `json:"user_id"`
+	Balance  int64  `json:"balance"`
+	Expected int64  `json:"expected"`
+}
+
+func (u *PaymentUsecase) GetTransactionDetails(ctx context.Context, refID string) (_ *TransactionDetails, err error) {
+	ctx, span := startSpan(ctx, "GetTransactionDetails")
+	defer endSpan(span, &err)
+
+	t, err := u.repo.GetTransactionByRef(ctx, refID)
+	if err != nil {
+		return nil, err
+	}
+	return transactionDetails(t), nil
+}
+
+func (u *PaymentUsecase) ListTransactions(ctx context.Context, req ListTransactionsRequest) (_ []TransactionDetails, err error) {
+	ctx, span := startSpan(ctx, "ListTransactions")
+	defer endSpan(span, &err)
+
+	if req.Limit <= 0 {
+		req.Limit = DefaultListLimit
+	}
+
+	transactions, err := u.repo.ListTransactions(ctx, domain.TransactionFilter{
+		UserID: req.UserID,
+		Type:   req.Type,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]TransactionDetails, 0, len(transactions))
+	for i := range transactions {
+		details = append(details, *transactionDetails(&transactions[i]))
+	}
+	return details, nil
+}
+
+// FreezeWallet stops the wallet of userID from sending, receiving and being
+// topped up. Freezing a frozen wallet is a no-op.
+func (u *PaymentUsecase) FreezeWallet(ctx context.Context, userID string) (_ *GetWalletResponse, err error) {
+	ctx, span := startSpan(ctx, "FreezeWallet", userAttr(userID))
+	defer endSpan(span, &err)
+
+	return u.setWalletStatus(ctx, userID, domain.WalletStatusFrozen)
+}
+
+func (u *PaymentUsecase) UnfreezeWallet(ctx context.Context, userID string) (_ *GetWalletResponse, err error) {
+	ctx, span := startSpan(ctx, "UnfreezeWallet", userAttr(userID))
+	defer endSpan(span, &err)
+
+	return u.setWalletStatus(ctx, userID, domain.WalletStatusActive)
+}
+
+func (u *PaymentUsecase) setWalletStatus(ctx context.Context, userID, status string) (*GetWalletResponse, error) {
+	err := u.withRetry(ctx, func() error {
+		tx, err := u.repo.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer u.repo.RollbackTx(tx)
+
+		wallet, err := u.lockWallet(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if wallet.Status == status {
+			return nil
+		}
+
+		err = u.repo.SetWalletStatus(ctx, tx, wallet.ID, status)
+		if err != nil {
+			return err
+		}
+		return u.repo.CommitTx(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u.GetWallet(ctx, userID)
+}
+
+// AdjustBalance corrects the balance of a wallet and records the correction as
+// an adjustment transaction.
+func (u *PaymentUsecase) AdjustBalance(ctx context.Context, req AdjustmentRequest) (_ *TransactionDetails, err error) {
+	ctx, span := startSpan(ctx, "AdjustBalance", userAttr(req.UserID), attribute.Int64("payment.amount", req.Amount))
+	defer endSpan(span, &err)
+
+	if req.Amount == 0 {
+		return nil, ErrInvalidAdjustment
+	}
+	if req.Reference == "" {
+		req.Reference = "ADJ-" + uuid.New().String()
+	}
+
+	var transaction *domain.Transaction
+	err = u.withRetry(ctx, func() error {
+		tx, err := u.repo.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer u.repo.RollbackTx(tx)
+
+		wallet, err := u.lockWallet(ctx, tx, req.UserID)
+		if err != nil {
+			return err
+		}
+		if wallet.Balance+req.Amount < 0 {
+			return ErrInsufficientBalance
+		}
+
+		err = u.repo.UpdateWalletBalance(ctx, tx, wallet.ID, req.Amount)
+		if err != nil {
+			return err
+		}
+
+		transaction = &domain.Transaction{
+			ID:        uuid.New().String(),
+			Reference: req.Reference,
+			Type:      domain.TransactionTypeAdjustment,
+			Amount:    req.Amount,
+			Status:    "completed",
+			CreatedAt: time.Now(),
+		}
+		if req.Amount > 0 {
+			transaction.ReceiverID = req.UserID
+		} else {
+			transaction.SenderID = req.UserID
+			transaction.Amount = -req.Amount
+		}
+
+		err = u.repo.CreateTransaction(ctx, tx, transaction)
+		if errors.Is(err, domain.ErrDuplicateReference) {
+			return ErrReferenceExists
+		}
+		if err != nil {
+			return err
+		}
+		return u.repo.CommitTx(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactionDetails(transaction), nil
+}
+
+// RefundTransfer moves the amount of the completed transfer with reference
+// refID back from its receiver to its sender. The refund is recorded under
+// the reference "REFUND-<refID>", so a transfer can only be refunded once.
+func (u *PaymentUsecase) RefundTransfer(ctx context.Context, refID string) (_ *TransactionDetails, err error) {
+	ctx, span := startSpan(ctx, "RefundTransfer", attribute.String("payment.reference", refID))
+	defer endSpan(span, &err)
+
+	original, err := u.repo.GetTransactionByRef(ctx, refID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Type != domain.TransactionTypeTransfer || original.Status != "completed" {
+		return nil, ErrNotRefundable
+	}
+
+	refundRef := "REFUND-" + original.Reference
+	existing, err := u.repo.GetTransactionByRef(ctx, refundRef)
+	if err == nil && existing != nil {
+		return nil, ErrAlreadyRefunded
+	}
+
+	var transaction *domain.Transaction
+	err = u.withRetry(ctx, func() error {
+		tx, err := u.repo.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer u.repo.RollbackTx(tx)
+
+		wallets := make(map[string]*domain.Wallet, 2)
+		for _, userID := range lockOrder(original.SenderID, original.ReceiverID) {
+			wallet, err := u.lockWallet(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			wallets[userID] = wallet
+		}
+		if wallets[original.ReceiverID].Balance < original.Amount {
+			return ErrInsufficientBalance
+		}
+
+		err = u.repo.UpdateWalletBalance(ctx, tx, wallets[original.ReceiverID].ID, -original.Amount)
+		if err != nil {
+			return err
+		}
+		err = u.repo.UpdateWalletBalance(ctx, tx, wallets[original.SenderID].ID, original.Amount)
+		if err != nil {
+			return err
+		}
+
+		transaction = &domain.Transaction{
+			ID:         uuid.New().String(),
+			Reference:  refundRef,
+			Type:       domain.TransactionTypeRefund,
+			SenderID:   original.ReceiverID,
+			ReceiverID: original.SenderID,
+			Amount:     original.Amount,
+			Status:     "completed",
+			CreatedAt:  time.Now(),
+		}
+		err = u.repo.CreateTransaction(ctx, tx, transaction)
+		if errors.Is(err, domain.ErrDuplicateReference) {
+			return ErrAlreadyRefunded
+		}
+		if err != nil {
+			return err
+		}
+		return u.repo.CommitTx(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactionDetails(transaction), nil
+}
+
+// CheckLedger checks that money is conserved, i.e. that the sum of all
+// balances equals the money that entered minus the money that left the
+// system, and compares every wallet balance with the sum of the completed
+// transactions crediting and debiting it.
+func (u *PaymentUsecase) CheckLedger(ctx context.Context) (_ *LedgerReport, err error) {
+	ctx, span := startSpan(ctx, "CheckLedger")
+	defer endSpan(span, &err)
+
+	return checkLedger(ctx, u.repo)
+}
+
+func checkLedger(ctx context.Context, repo domain.TransactionRepository) (*LedgerReport, error) {
+	ledger, err := repo.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LedgerReport{
+		Wallets:       len(ledger.Wallets),
+		ExpectedTotal: ledger.Credits - ledger.Debits,
+		Discrepancies: []LedgerDiscrepancy{},
+	}
+	for _, l := range ledger.Wallets {
+		report.TotalBalance += l.Balance
+		if expected := l.Credits - l.Debits; expected != l.Balance {
+			report.Discrepancies = append(report.Discrepancies, LedgerDiscrepancy{
+				WalletID: l.WalletID,
+				UserID:   l.UserID,
+				Balance:  l.Balance,
+				Expected: expected,
+			})
+		}
+	}
+	return report, nil
+}
+
+func transactionDetails(t *domain.Transaction) *TransactionDetails {
+	return &TransactionDetails{
+		TransactionID: t.ID,
+		Reference:     t.Reference,
+		Type:          t.Type,
+		SenderID:      t.SenderID,
+		ReceiverID:    t.ReceiverID,
+		Amount:        t.Amount,
+		Status:        t.Status,
+		CreatedAt:     t.CreatedAt,
+	}
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTestUsecase(t *testing.T) (*PaymentUsecase, domain.TransactionRepository, string, string) {
+	ctx := t.Context()
+	repo := repository.NewMemoryRepo()
+	uc := NewPaymentUsecase(repo)
+	alice, err := uc.CreateWallet(ctx, CreateWalletRequest{Username: "alice", Balance: 1000})
+	require.NoError(t, err)
+	bob, err := uc.CreateWallet(ctx, CreateWalletRequest{Username: "bob"})
+	require.NoError(t, err)
+	return uc, repo, alice.UserID, bob.UserID
+}
+
+func TestFreezeWallet(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, bob := newAdminTestUsecase(t)
+
+	wallet, err := uc.FreezeWallet(ctx, bob)
+	require.NoError(t, err)
+	require.Equal(t, domain.WalletStatusFrozen, wallet.Status)
+
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 100, Reference: "ref-frozen"})
+	require.ErrorIs(t, err, ErrWalletFrozen)
+	_, err = uc.TopUpWallet(ctx, TopUpRequest{UserID: bob, Amount: 100})
+	require.ErrorIs(t, err, ErrWalletFrozen)
+
+	got, err := uc.GetWallet(ctx, bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), got.Balance)
+
+	_, err = uc.UnfreezeWallet(ctx, bob)
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 100, Reference: "ref-frozen"})
+	require.NoError(t, err)
+}
+
+func TestRefundTransfer(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, bob := newAdminTestUsecase(t)
+
+	_, err := uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 300, Reference: "ref-refund"})
+	require.NoError(t, err)
+
+	refund, err := uc.RefundTransfer(ctx, "ref-refund")
+	require.NoError(t, err)
+	require.Equal(t, domain.TransactionTypeRefund, refund.Type)
+	require.Equal(t, bob, refund.SenderID)
+	require.Equal(t, alice, refund.ReceiverID)
+
+	_, err = uc.RefundTransfer(ctx, "ref-refund")
+	require.ErrorIs(t, err, ErrAlreadyRefunded)
+	_, err = uc.RefundTransfer(ctx, refund.Reference)
+	require.ErrorIs(t, err, ErrNotRefundable)
+
+	got, err := uc.GetWallet(ctx, alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), got.Balance)
+}
+
+func TestAdjustBalance(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, _ := newAdminTestUsecase(t)
+
+	credit, err := uc.AdjustBalance(ctx, AdjustmentRequest{UserID: alice, Amount: 50, Reference: "adj-1"})
+	require.NoError(t, err)
+	require.Equal(t, alice, credit.ReceiverID)
+
+	debit, err := uc.AdjustBalance(ctx, AdjustmentRequest{UserID: alice, Amount: -150})
+	require.NoError(t, err)
+	require.Equal(t, alice, debit.SenderID)
+	require.Equal(t, int64(150), debit.Amount)
+
+	_, err = uc.AdjustBalance(ctx, AdjustmentRequest{UserID: alice, Amount: -901})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = uc.AdjustBalance(ctx, AdjustmentRequest{UserID: alice, Amount: 1, Reference: "adj-1"})
+	require.ErrorIs(t, err, ErrReferenceExists)
+	_, err = uc.AdjustBalance(ctx, AdjustmentRequest{UserID: alice})
+	require.ErrorIs(t, err, ErrInvalidAdjustment)
+
+	got, err := uc.GetWallet(ctx, alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(900), got.Balance)
+
+	transactions, err := uc.ListTransactions(ctx, ListTransactionsRequest{UserID: alice, Type: domain.TransactionTypeAdjustment})
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+}
+
+func TestCheckLedger(t *testing.T) {
+	ctx := t.Context()
+	uc, repo, alice, bob := newAdminTestUsecase(t)
+
+	_, err := uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 300, Reference: "ref-ledger"})
+	require.NoError(t, err)
+	_, err = uc.TopUpWallet(ctx, TopUpRequest{UserID: bob, Amount: 20})
+	require.NoError(t, err)
+
+	report, err := uc.CheckLedger(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Wallets)
+	require.Equal(t, int64(1020), report.TotalBalance)
+	require.Equal(t, int64(1020), report.ExpectedTotal)
+	require.Empty(t, report.Discrepancies)
+	require.True(t, report.Consistent())
+
+	// A balance change that bypasses the usecase is not backed by a
+	// transaction.
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repo.TopUpWallet(ctx, tx, bob, 5))
+	require.NoError(t, repo.CommitTx(tx))
+
+	report, err = uc.CheckLedger(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Discrepancies, 1)
+	require.Equal(t, bob, report.Discrepancies[0].UserID)
+	require.Equal(t, int64(325), report.Discrepancies[0].Balance)
+	require.Equal(t, int64(320), report.Discrepancies[0].Expected)
+	require.False(t, report.Consistent())
+}
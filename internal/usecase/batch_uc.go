@@ -0,0 +1,482 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrEmptyBatch            = errors.New("batch must contain at least one item")
+	ErrBatchTooLarge         = errors.New("batch contains too many items")
+	ErrInvalidBatchMode      = errors.New("batch mode must be all_or_nothing or best_effort")
+	ErrInvalidBatchReference = errors.New("batch reference must be 1 to 64 characters")
+	ErrBatchReferenceExists  = errors.New("batch reference already exists")
+	// ErrBatchAborted is the error of the items of a failed all-or-nothing
+	// batch that did not cause the failure themselves.
+	ErrBatchAborted = errors.New("batch aborted because another item failed")
+)
+
+// MaxBatchReferenceLength keeps the item references, the batch reference
+// followed by "-" and the item number, within the 100 characters of a
+// transaction reference.
+const MaxBatchReferenceLength = 64
+
+// batchProgressEvery is how many best-effort items are transferred between
+// two saves of the batch, so that GetBatch shows the progress of large
+// batches without rewriting them after every item.
+const batchProgressEvery = 50
+
+// batchLock is how long a worker holds a claimed batch; every save of the
+// batch's progress renews it. A batch whose worker crashed is processed
+// again once its lock expires; the item references keep that from paying
+// twice.
+const batchLock = 5 * time.Minute
+
+// BatchPolicy holds the limits of submitted batches. DefaultMode applies to
+// batches submitted without a mode.
+type BatchPolicy struct {
+	DefaultMode string
+	MaxItems    int
+}
+
+var DefaultBatchPolicy = BatchPolicy{DefaultMode: domain.BatchModeAllOrNothing, MaxItems: 1000}
+
+// BatchUsecase accepts batches of transfers from one sender and processes
+// them in the background, see worker.BatchProcessor.
+type BatchUsecase struct {
+	payments *PaymentUsecase
+	batches  domain.BatchRepository
+	policy   BatchPolicy
+	now      func() time.Time
+}
+
+func NewBatchUsecase(payments *PaymentUsecase, batches domain.BatchRepository, policy BatchPolicy) *BatchUsecase {
+	return &BatchUsecase{payments: payments, batches: batches, policy: policy, now: time.Now}
+}
+
+// BatchTransferRequest transfers every item from SenderID. Reference
+// identifies the batch and must not have been used by another batch. Mode is
+// one of the domain.BatchMode constants; empty selects the default mode.
+type BatchTransferRequest struct {
+	Reference string             `json:"reference"`
+	SenderID  string             `json:"sender_id"`
+	Mode      string             `json:"mode"`
+	Items     []BatchItemRequest `json:"items"`
+}
+
+type BatchItemRequest struct {
+	ReceiverID string `json:"receiver_id"`
+	Amount     int64  `json:"amount"`
+}
+
+type BatchResponse struct {
+	BatchID     string              `json:"batch_id"`
+	Reference   string              `json:"reference"`
+	SenderID    string              `json:"sender_id"`
+	Mode        string              `json:"mode"`
+	Status      string              `json:"status"`
+	Error       string              `json:"error,omitempty"`
+	TotalAmount int64               `json:"total_amount"`
+	Items       []BatchItemResponse `json:"items"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type BatchItemResponse struct {
+	ReceiverID    string `json:"receiver_id"`
+	Amount        int64  `json:"amount"`
+	Reference     string `json:"reference"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+// SubmitBatch validates req and stores it as a pending batch. No money moves
+// until ProcessNext picks the batch up.
+func (u *BatchUsecase) SubmitBatch(ctx context.Context, req BatchTransferRequest) (_ *BatchResponse, err error) {
+	ctx, span := startSpan(ctx, "SubmitBatch", attribute.String("batch.reference", req.Reference), attribute.Int("batch.items", len(req.Items)))
+	defer endSpan(span, &err)
+
+	if req.Mode == "" {
+		req.Mode = u.policy.DefaultMode
+	}
+	if err := u.validate(req); err != nil {
+		return nil, err
+	}
+
+	now := u.now()
+	batch := &domain.Batch{
+		ID:        uuid.New().String(),
+		Reference: req.Reference,
+		SenderID:  req.SenderID,
+		Mode:      req.Mode,
+		Status:    domain.BatchStatusPending,
+		Items:     make([]domain.BatchItem, 0, len(req.Items)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for i, item := range req.Items {
+		batch.Items = append(batch.Items, domain.BatchItem{
+			ReceiverID: item.ReceiverID,
+			Amount:     item.Amount,
+			Reference:  req.Reference + "-" + strconv.Itoa(i+1),
+			Status:     domain.BatchItemStatusPending,
+		})
+	}
+
+	err = u.batches.CreateBatch(ctx, batch)
+	if errors.Is(err, domain.ErrDuplicateBatchReference) {
+		return nil, ErrBatchReferenceExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return batchResponse(batch), nil
+}
+
+func (u *BatchUsecase) validate(req BatchTransferRequest) error {
+	if req.Reference == "" || len(req.Reference) > MaxBatchReferenceLength {
+		return ErrInvalidBatchReference
+	}
+	if req.Mode != domain.BatchModeAllOrNothing && req.Mode != domain.BatchModeBestEffort {
+		return ErrInvalidBatchMode
+	}
+	if len(req.Items) == 0 {
+		return ErrEmptyBatch
+	}
+	if len(req.Items) > u.policy.MaxItems {
+		return fmt.Errorf("%w: %d items, at most %d are allowed", ErrBatchTooLarge, len(req.Items), u.policy.MaxItems)
+	}
+
+	var total int64
+	for i, item := range req.Items {
+		if item.Amount <= 0 {
+			return fmt.Errorf("item %d: %w", i+1, ErrInvalidAmount)
+		}
+		if item.ReceiverID == req.SenderID {
+			return fmt.Errorf("item %d: %w", i+1, ErrSameUser)
+		}
+		if total > total+item.Amount {
+			return fmt.Errorf("item %d: %w", i+1, ErrInvalidAmount)
+		}
+		total += item.Amount
+	}
+	return nil
+}
+
+func (u *BatchUsecase) GetBatch(ctx context.Context, id string) (_ *BatchResponse, err error) {
+	ctx, span := startSpan(ctx, "GetBatch", attribute.String("batch.id", id))
+	defer endSpan(span, &err)
+
+	batch, err := u.batches.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return batchResponse(batch), nil
+}
+
+// ProcessNext claims the oldest pending batch, or batch whose worker crashed,
+// and transfers its items. It returns domain.ErrBatchNotFound when no batch
+// is waiting. Failed items do not fail ProcessNext; they are recorded in the
+// batch.
+func (u *BatchUsecase) ProcessNext(ctx context.Context) (_ *BatchResponse, err error) {
+	ctx, span := startSpan(ctx, "ProcessBatch")
+	defer endSpan(span, &err)
+
+	now := u.now()
+	batch, err := u.batches.ClaimBatch(ctx, now, now.Add(batchLock))
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("batch.id", batch.ID),
+		attribute.String("batch.mode", batch.Mode),
+		attribute.Int("batch.items", len(batch.Items)),
+	)
+
+	if batch.Mode == domain.BatchModeAllOrNothing {
+		err = u.transferAllOrNothing(ctx, batch)
+	} else {
+		err = u.transferBestEffort(ctx, batch)
+	}
+	if err != nil {
+		// The lock expires and the batch is processed again.
+		return nil, err
+	}
+	batch.Status = batchStatus(batch)
+	batch.LockedUntil = time.Time{}
+	batch.UpdatedAt = u.now()
+	if err := u.batches.UpdateBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	u.payments.logger.InfoContext(ctx, "batch processed",
+		"batch_id", batch.ID,
+		"reference", batch.Reference,
+		"mode", batch.Mode,
+		"status", batch.Status,
+		"items", len(batch.Items))
+	return batchResponse(batch), nil
+}
+
+// transferBestEffort makes one transfer per pending item, saving the batch
+// every batchProgressEvery items. An item transferred by an interrupted
+// earlier attempt counts as completed.
+func (u *BatchUsecase) transferBestEffort(ctx context.Context, batch *domain.Batch) error {
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.Status != domain.BatchItemStatusPending {
+			continue
+		}
+		resp, err := u.payments.transferOrAdopt(ctx, TransferRequest{
+			SenderID:   batch.SenderID,
+			ReceiverID: item.ReceiverID,
+			Amount:     item.Amount,
+			Reference:  item.Reference,
+		})
+		if err != nil {
+			item.Status = domain.BatchItemStatusFailed
+			item.Error = ErrorCode(err)
+		} else {
+			item.Status = domain.BatchItemStatusCompleted
+			item.TransactionID = resp.TransactionID
+		}
+
+		if (i+1)%batchProgressEvery == 0 && i+1 < len(batch.Items) {
+			batch.UpdatedAt = u.now()
+			batch.LockedUntil = batch.UpdatedAt.Add(batchLock)
+			if err := u.batches.UpdateBatch(ctx, batch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// itemError is the error of one item that failed an all-or-nothing batch.
+type itemError struct {
+	index int
+	err   error
+}
+
+func (e *itemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.index+1, e.err)
+}
+
+func (e *itemError) Unwrap() error {
+	return e.err
+}
+
+// transferAllOrNothing moves all items in one database transaction, retried
+// on conflicts like a single transfer, and records the outcome in the items.
+// Items transferred by an interrupted earlier attempt count as completed.
+// It only fails when the earlier attempt cannot be looked up.
+func (u *BatchUsecase) transferAllOrNothing(ctx context.Context, batch *domain.Batch) error {
+	var transactionIDs []string
+	err := u.payments.withRetry(ctx, func() error {
+		var err error
+		transactionIDs, err = u.transferAll(ctx, batch)
+		return err
+	})
+	if errors.Is(err, ErrReferenceExists) {
+		ids, lookupErr := u.previousTransfers(ctx, batch)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		if ids != nil {
+			transactionIDs, err = ids, nil
+		}
+	}
+
+	if err == nil {
+		for i := range batch.Items {
+			batch.Items[i].Status = domain.BatchItemStatusCompleted
+			batch.Items[i].TransactionID = transactionIDs[i]
+			u.payments.metrics.TransferFinished(batch.Items[i].Amount, nil)
+		}
+		return nil
+	}
+
+	batch.Error = ErrorCode(err)
+	failed := -1
+	var itemErr *itemError
+	if errors.As(err, &itemErr) {
+		failed = itemErr.index
+	}
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		item.Status = domain.BatchItemStatusFailed
+		item.Error = batch.Error
+		if failed >= 0 && i != failed {
+			item.Error = ErrorCode(ErrBatchAborted)
+		}
+	}
+	u.payments.metrics.TransferFinished(batch.Items[max(failed, 0)].Amount, err)
+	return nil
+}
+
+// previousTransfers returns the transaction IDs of the items of batch when
+// an earlier attempt transferred all of them, or nil when any item reference
+// is unused or used by another transfer.
+func (u *BatchUsecase) previousTransfers(ctx context.Context, batch *domain.Batch) ([]string, error) {
+	ids := make([]string, len(batch.Items))
+	for i, item := range batch.Items {
+		tx, err := u.payments.repo.GetTransactionByRef(ctx, item.Reference)
+		if errors.Is(err, domain.ErrTransactionNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !sameTransfer(tx, TransferRequest{SenderID: batch.SenderID, ReceiverID: item.ReceiverID, Amount: item.Amount}) {
+			return nil, nil
+		}
+		ids[i] = tx.ID
+	}
+	return ids, nil
+}
+
+// transferAll locks the sender and receiver wallets in user ID order, the
+// order single transfers lock them in, moves the money and records one
+// transaction per item. It returns the transaction IDs in item order.
+func (u *BatchUsecase) transferAll(ctx context.Context, batch *domain.Batch) ([]string, error) {
+	repo := u.payments.repo
+	tx, err := repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.RollbackTx(tx)
+
+	// credits sums the items of each receiver, firstItem remembers which
+	// item to blame for a problem with a receiver's wallet.
+	credits := make(map[string]int64)
+	firstItem := make(map[string]int)
+	var total int64
+	for i, item := range batch.Items {
+		if _, ok := firstItem[item.ReceiverID]; !ok {
+			firstItem[item.ReceiverID] = i
+		}
+		credits[item.ReceiverID] += item.Amount
+		total += item.Amount
+	}
+
+	userIDs := []string{batch.SenderID}
+	for userID := range credits {
+		userIDs = append(userIDs, userID)
+	}
+	slices.Sort(userIDs)
+
+	wallets := make(map[string]*domain.Wallet, len(userIDs))
+	for _, userID := range userIDs {
+		wallet, err := u.payments.lockWallet(ctx, tx, userID)
+		if err != nil {
+			if userID == batch.SenderID {
+				return nil, err
+			}
+			return nil, &itemError{index: firstItem[userID], err: err}
+		}
+		wallets[userID] = wallet
+	}
+
+	sender := wallets[batch.SenderID]
+	if isFrozen(sender) {
+		return nil, ErrWalletFrozen
+	}
+	for i, item := range batch.Items {
+		if isFrozen(wallets[item.ReceiverID]) {
+			return nil, &itemError{index: i, err: ErrWalletFrozen}
+		}
+	}
+	if sender.Balance < total {
+		return nil, ErrInsufficientBalance
+	}
+
+	for _, userID := range userIDs {
+		amount := credits[userID]
+		if userID == batch.SenderID {
+			amount = -total
+		}
+		if err := repo.UpdateWalletBalance(ctx, tx, wallets[userID].ID, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, len(batch.Items))
+	now := time.Now()
+	for i, item := range batch.Items {
+		ids[i] = uuid.New().String()
+		err := repo.CreateTransaction(ctx, tx, &domain.Transaction{
+			ID:         ids[i],
+			Reference:  item.Reference,
+			Type:       domain.TransactionTypeTransfer,
+			SenderID:   batch.SenderID,
+			ReceiverID: item.ReceiverID,
+			Amount:     item.Amount,
+			Status:     "completed",
+			CreatedAt:  now,
+		})
+		if errors.Is(err, domain.ErrDuplicateReference) {
+			return nil, &itemError{index: i, err: ErrReferenceExists}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repo.CommitTx(tx); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// batchStatus derives the final status of a processed batch from its items.
+func batchStatus(batch *domain.Batch) string {
+	completed := 0
+	for _, item := range batch.Items {
+		if item.Status == domain.BatchItemStatusCompleted {
+			completed++
+		}
+	}
+	switch completed {
+	case len(batch.Items):
+		return domain.BatchStatusCompleted
+	case 0:
+		return domain.BatchStatusFailed
+	default:
+		return domain.BatchStatusPartiallyCompleted
+	}
+}
+
+func batchResponse(batch *domain.Batch) *BatchResponse {
+	resp := &BatchResponse{
+		BatchID:   batch.ID,
+		Reference: batch.Reference,
+		SenderID:  batch.SenderID,
+		Mode:      batch.Mode,
+		Status:    batch.Status,
+		Error:     batch.Error,
+		Items:     make([]BatchItemResponse, 0, len(batch.Items)),
+		CreatedAt: batch.CreatedAt,
+		UpdatedAt: batch.UpdatedAt,
+	}
+	for _, item := range batch.Items {
+		resp.TotalAmount += item.Amount
+		resp.Items = append(resp.Items, BatchItemResponse{
+			ReceiverID:    item.ReceiverID,
+			Amount:        item.Amount,
+			Reference:     item.Reference,
+			Status:        item.Status,
+			Error:         item.Error,
+			TransactionID: item.TransactionID,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchTestUsecase returns a batch usecase over alice (1000), bob (0) and
+// carol (0).
+func newBatchTestUsecase(t *testing.T) (*BatchUsecase, *PaymentUsecase, string, string, string) {
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	carol, err := uc.CreateWallet(t.Context(), CreateWalletRequest{Username: "carol"})
+	require.NoError(t, err)
+	return NewBatchUsecase(uc, repository.NewMemoryBatchRepo(), DefaultBatchPolicy), uc, alice, bob, carol.UserID
+}
+
+func requireBalance(t *testing.T, uc *PaymentUsecase, userID string, want int64) {
+	t.Helper()
+	wallet, err := uc.GetWallet(t.Context(), userID)
+	require.NoError(t, err)
+	require.Equal(t, want, wallet.Balance)
+}
+
+func TestSubmitBatch_Validation(t *testing.T) {
+	ctx := t.Context()
+	batches, _, alice, bob, _ := newBatchTestUsecase(t)
+	batches.policy.MaxItems = 2
+	item := BatchItemRequest{ReceiverID: bob, Amount: 10}
+
+	for _, tc := range []struct {
+		name string
+		req  BatchTransferRequest
+		want error
+	}{
+		{"no reference", BatchTransferRequest{SenderID: alice, Items: []BatchItemRequest{item}}, ErrInvalidBatchReference},
+		{"long reference", BatchTransferRequest{Reference: strings.Repeat("r", 65), SenderID: alice, Items: []BatchItemRequest{item}}, ErrInvalidBatchReference},
+		{"unknown mode", BatchTransferRequest{Reference: "b", SenderID: alice, Mode: "some", Items: []BatchItemRequest{item}}, ErrInvalidBatchMode},
+		{"no items", BatchTransferRequest{Reference: "b", SenderID: alice}, ErrEmptyBatch},
+		{"too many items", BatchTransferRequest{Reference: "b", SenderID: alice, Items: []BatchItemRequest{item, item, item}}, ErrBatchTooLarge},
+		{"zero amount", BatchTransferRequest{Reference: "b", SenderID: alice, Items: []BatchItemRequest{item, {ReceiverID: bob}}}, ErrInvalidAmount},
+		{"to the sender", BatchTransferRequest{Reference: "b", SenderID: alice, Items: []BatchItemRequest{{ReceiverID: alice, Amount: 1}}}, ErrSameUser},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := batches.SubmitBatch(ctx, tc.req)
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	resp, err := batches.SubmitBatch(ctx, BatchTransferRequest{Reference: "b", SenderID: alice, Items: []BatchItemRequest{item}})
+	require.NoError(t, err)
+	require.Equal(t, domain.BatchModeAllOrNothing, resp.Mode, "the default mode applies")
+	require.Equal(t, domain.BatchStatusPending, resp.Status)
+	require.Equal(t, "b-1", resp.Items[0].Reference)
+
+	_, err = batches.SubmitBatch(ctx, BatchTransferRequest{Reference: "b", SenderID: alice, Items: []BatchItemRequest{item}})
+	require.ErrorIs(t, err, ErrBatchReferenceExists)
+	require.Equal(t, "reference_exists", ErrorCode(err))
+}
+
+func TestProcessBatch_AllOrNothing(t *testing.T) {
+	ctx := t.Context()
+	batches, uc, alice, bob, carol := newBatchTestUsecase(t)
+
+	ok, err := batches.SubmitBatch(ctx, BatchTransferRequest{
+		Reference: "payroll-1",
+		SenderID:  alice,
+		Mode:      domain.BatchModeAllOrNothing,
+		Items:     []BatchItemRequest{{ReceiverID: bob, Amount: 100}, {ReceiverID: carol, Amount: 200}, {ReceiverID: bob, Amount: 50}},
+	})
+	require.NoError(t, err)
+	failing, err := batches.SubmitBatch(ctx, BatchTransferRequest{
+		Reference: "payroll-2",
+		SenderID:  alice,
+		Mode:      domain.BatchModeAllOrNothing,
+		Items:     []BatchItemRequest{{ReceiverID: bob, Amount: 100}, {ReceiverID: "missing", Amount: 1}},
+	})
+	require.NoError(t, err)
+
+	done, err := batches.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ok.BatchID, done.BatchID)
+	require.Equal(t, domain.BatchStatusCompleted, done.Status)
+	for _, item := range done.Items {
+		require.Equal(t, domain.BatchItemStatusCompleted, item.Status)
+		tx, err := uc.GetTransactionByRef(ctx, item.Reference)
+		require.NoError(t, err)
+		require.Equal(t, item.TransactionID, tx.TransactionID)
+	}
+	requireBalance(t, uc, alice, 650)
+	requireBalance(t, uc, bob, 150)
+	requireBalance(t, uc, carol, 200)
+
+	done, err = batches.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, failing.BatchID, done.BatchID)
+	require.Equal(t, domain.BatchStatusFailed, done.Status)
+	require.Equal(t, "wallet_not_found", done.Error)
+	require.Equal(t, "batch_aborted", done.Items[0].Error)
+	require.Equal(t, "wallet_not_found", done.Items[1].Error)
+	requireBalance(t, uc, alice, 650)
+	requireBalance(t, uc, bob, 150)
+
+	_, err = batches.ProcessNext(ctx)
+	require.ErrorIs(t, err, domain.ErrBatchNotFound)
+
+	got, err := batches.GetBatch(ctx, failing.BatchID)
+	require.NoError(t, err)
+	require.Equal(t, done, got)
+}
+
+func TestProcessBatch_AllOrNothingInsufficientBalance(t *testing.T) {
+	ctx := t.Context()
+	batches, uc, alice, bob, carol := newBatchTestUsecase(t)
+
+	_, err := batches.SubmitBatch(ctx, BatchTransferRequest{
+		Reference: "payroll",
+		SenderID:  alice,
+		Items:     []BatchItemRequest{{ReceiverID: bob, Amount: 600}, {ReceiverID: carol, Amount: 600}},
+	})
+	require.NoError(t, err)
+
+	done, err := batches.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, domain.BatchStatusFailed, done.Status)
+	require.Equal(t, "insufficient_balance", done.Error)
+	for _, item := range done.Items {
+		require.Equal(t, "insufficient_balance", item.Error, "no item is to blame")
+	}
+	requireBalance(t, uc, alice, 1000)
+}
+
+func TestProcessBatch_BestEffort(t *testing.T) {
+	ctx := t.Context()
+	batches, uc, alice, bob, carol := newBatchTestUsecase(t)
+	_, err := uc.FreezeWallet(ctx, carol)
+	require.NoError(t, err)
+
+	_, err = batches.SubmitBatch(ctx, BatchTransferRequest{
+		Reference: "payroll",
+		SenderID:  alice,
+		Mode:      domain.BatchModeBestEffort,
+		Items: []BatchItemRequest{
+			{ReceiverID: bob, Amount: 400},
+			{ReceiverID: carol, Amount: 100},
+			{ReceiverID: bob, Amount: 700},
+			{ReceiverID: bob, Amount: 600},
+		},
+	})
+	require.NoError(t, err)
+
+	done, err := batches.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, domain.BatchStatusPartiallyCompleted, done.Status)
+	require.Empty(t, done.Error)
+
+	var statuses, errs []string
+	for _, item := range done.Items {
+		statuses = append(statuses, item.Status)
+		errs = append(errs, item.Error)
+	}
+	require.Equal(t, []string{"completed", "failed", "failed", "completed"}, statuses)
+	require.Equal(t, []string{"", "wallet_frozen", "insufficient_balance", ""}, errs)
+	requireBalance(t, uc, alice, 0)
+	requireBalance(t, uc, bob, 1000)
+}
+
+func TestProcessBatch_ReclaimedAfterCrash(t *testing.T) {
+	for _, mode := range []string{domain.BatchModeAllOrNothing, domain.BatchModeBestEffort} {
+		t.Run(mode, func(t *testing.T) {
+			ctx := t.Context()
+			batches, uc, alice, bob, carol := newBatchTestUsecase(t)
+			submitted, err := batches.SubmitBatch(ctx, BatchTransferRequest{
+				Reference: "payroll",
+				SenderID:  alice,
+				Mode:      mode,
+				Items:     []BatchItemRequest{{ReceiverID: bob, Amount: 100}, {ReceiverID: carol, Amount: 200}},
+			})
+			require.NoError(t, err)
+
+			// A worker claims the batch and moves the money, then crashes
+			// before saving the batch.
+			now := batches.now()
+			claimed, err := batches.batches.ClaimBatch(ctx, now, now)
+			require.NoError(t, err)
+			if mode == domain.BatchModeAllOrNothing {
+				_, err = batches.transferAll(ctx, claimed)
+				require.NoError(t, err)
+			} else {
+				_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 100, Reference: "payroll-1"})
+				require.NoError(t, err)
+			}
+
+			done, err := batches.ProcessNext(ctx)
+			require.NoError(t, err)
+			require.Equal(t, submitted.BatchID, done.BatchID, "the expired lock lets the batch be claimed again")
+			require.Equal(t, domain.BatchStatusCompleted, done.Status)
+			requireBalance(t, uc, alice, 700)
+			requireBalance(t, uc, bob, 100)
+			requireBalance(t, uc, carol, 200)
+			for _, item := range done.Items {
+				tx, err := uc.GetTransactionByRef(ctx, item.Reference)
+				require.NoError(t, err)
+				require.Equal(t, item.TransactionID, tx.TransactionID)
+			}
+		})
+	}
+}
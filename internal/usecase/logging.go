@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+)
+
+// WithLogger makes PaymentUsecase log the outcome of every transfer. Nothing
+// is logged by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(u *PaymentUsecase) {
+		u.logger = l
+	}
+}
+
+// RedactID replaces a user ID with a short stable digest, so that log lines of
+// the same user can be correlated without the logs holding the ID itself.
+func RedactID(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return "u_" + hex.EncodeToString(sum[:6])
+}
+
+// logTransfer logs a finished transfer: completed ones at info, ones refused
+// for a reason the caller can fix at warn and internal failures at error.
+func (u *PaymentUsecase) logTransfer(ctx context.Context, req TransferRequest, resp *TransferResponse, err error) {
+	code := ErrorCode(err)
+	attrs := []slog.Attr{
+		slog.String("reference", req.Reference),
+		slog.String("sender", RedactID(req.SenderID)),
+		slog.String("receiver", RedactID(req.ReceiverID)),
+		slog.Int64("amount", req.Amount),
+		slog.String("code", code),
+	}
+	switch {
+	case err == nil:
+		attrs = append(attrs, slog.String("transaction_id", resp.TransactionID))
+		u.logger.LogAttrs(ctx, slog.LevelInfo, "transfer completed", attrs...)
+	case code == "internal":
+		attrs = append(attrs, slog.String("error", err.Error()))
+		u.logger.LogAttrs(ctx, slog.LevelError, "transfer failed", attrs...)
+	default:
+		u.logger.LogAttrs(ctx, slog.LevelWarn, "transfer failed", attrs...)
+	}
+}
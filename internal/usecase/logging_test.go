@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"payment-service/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferFunds_LogsOutcomeWithRedactedIDs(t *testing.T) {
+	ctx := t.Context()
+	var out bytes.Buffer
+	uc := NewPaymentUsecase(repository.NewMemoryRepo(), WithLogger(slog.New(slog.NewJSONHandler(&out, nil))))
+	_, err := uc.CreateWallet(ctx, CreateWalletRequest{UserID: "alice-id", Username: "alice", Balance: 500})
+	require.NoError(t, err)
+	_, err = uc.CreateWallet(ctx, CreateWalletRequest{UserID: "bob-id", Username: "bob"})
+	require.NoError(t, err)
+
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: "alice-id", ReceiverID: "bob-id", Amount: 200, Reference: "ref-1"})
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: "bob-id", ReceiverID: "alice-id", Amount: 900, Reference: "ref-2"})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+
+	require.NotContains(t, out.String(), "alice-id")
+	require.NotContains(t, out.String(), "bob-id")
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	require.Equal(t, "INFO", records[0]["level"])
+	require.Equal(t, "transfer completed", records[0]["msg"])
+	require.Equal(t, "ref-1", records[0]["reference"])
+	require.Equal(t, RedactID("alice-id"), records[0]["sender"])
+	require.Equal(t, RedactID("bob-id"), records[0]["receiver"])
+	require.EqualValues(t, 200, records[0]["amount"])
+	require.NotEmpty(t, records[0]["transaction_id"])
+
+	require.Equal(t, "WARN", records[1]["level"])
+	require.Equal(t, "transfer failed", records[1]["msg"])
+	require.Equal(t, "insufficient_balance", records[1]["code"])
+	require.EqualValues(t, 900, records[1]["amount"])
+}
+
+func TestRedactID(t *testing.T) {
+	require.Equal(t, RedactID("alice"), RedactID("alice"))
+	require.NotEqual(t, RedactID("alice"), RedactID("bob"))
+	require.Regexp(t, `^u_[0-9a-f]{12}$`, RedactID("alice"))
+	require.Empty(t, RedactID(""))
+}
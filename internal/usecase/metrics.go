@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"payment-service/internal/domain"
+	"time"
+)
+
+// Metrics receives the business events of PaymentUsecase. err is nil for
+// operations that succeeded; use ErrorCode to turn it into a label.
+// internal/metrics implements it for Prometheus.
+type Metrics interface {
+	TransferFinished(amount int64, err error)
+	TopUpFinished(amount int64, err error)
+	// Retried is called before a transaction aborted by a conflict is
+	// retried, with the error that aborted it.
+	Retried(err error)
+	// LockWaited is called with the time it took to lock a wallet row.
+	LockWaited(d time.Duration)
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(u *PaymentUsecase) {
+		u.metrics = m
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) TransferFinished(int64, error) {}
+func (noopMetrics) TopUpFinished(int64, error)    {}
+func (noopMetrics) Retried(error)                 {}
+func (noopMetrics) LockWaited(time.Duration)      {}
+
+// ErrorCode returns a short, stable name for err suitable as a metric label:
+// "ok" for nil, a snake_case name for the errors callers can react to and
+// "internal" for everything else.
+func ErrorCode(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, ErrInvalidAmount):
+		return "invalid_amount"
+	case errors.Is(err, ErrSameUser):
+		return "same_user"
+	case errors.Is(err, ErrReferenceExists), errors.Is(err, ErrBatchReferenceExists),
+		errors.Is(err, ErrScheduleReferenceExists):
+		return "reference_exists"
+	case errors.Is(err, ErrVersionMismatch):
+		return "version_mismatch"
+	case errors.Is(err, ErrWalletFrozen):
+		return "wallet_frozen"
+	case errors.Is(err, domain.ErrWalletNotFound):
+		return "wallet_not_found"
+	case errors.Is(err, domain.ErrTransactionNotFound):
+		return "transaction_not_found"
+	case errors.Is(err, domain.ErrBatchNotFound):
+		return "batch_not_found"
+	case errors.Is(err, ErrEmptyBatch), errors.Is(err, ErrBatchTooLarge),
+		errors.Is(err, ErrInvalidBatchMode), errors.Is(err, ErrInvalidBatchReference):
+		return "invalid_batch"
+	case errors.Is(err, domain.ErrScheduleNotFound):
+		return "schedule_not_found"
+	case errors.Is(err, ErrInvalidSchedule), errors.Is(err, ErrInvalidScheduleReference):
+		return "invalid_schedule"
+	case errors.Is(err, ErrScheduleState):
+		return "invalid_schedule_state"
+	case errors.Is(err, domain.ErrPaymentRequestNotFound):
+		return "payment_request_not_found"
+	case errors.Is(err, ErrInvalidPaymentRequest):
+		return "invalid_payment_request"
+	case errors.Is(err, ErrPaymentRequestState):
+		return "invalid_payment_request_state"
+	case errors.Is(err, ErrNotPaymentRequestPayer):
+		return "not_payer"
+	case errors.Is(err, domain.ErrSplitNotFound):
+		return "split_not_found"
+	case errors.Is(err, ErrInvalidSplit):
+		return "invalid_split"
+	case errors.Is(err, ErrNotSplitParticipant):
+		return "not_participant"
+	case errors.Is(err, ErrInvalidPayout), errors.Is(err, ErrEmptyPayout):
+		return "invalid_payout"
+	case errors.Is(err, ErrMalformedRow), errors.Is(err, domain.ErrMalformedID):
+		return "invalid_request"
+	case errors.Is(err, ErrBatchAborted):
+		return "batch_aborted"
+	case errors.Is(err, domain.ErrTxConflict):
+		return "tx_conflict"
+	case errors.Is(err, domain.ErrVersionConflict):
+		return "version_conflict"
+	default:
+		return "internal"
+	}
+}
+
+// lockWallet is GetWalletForUpdate in its own span, reporting how long the row
+// lock took.
+func (u *PaymentUsecase) lockWallet(ctx context.Context, tx interface{}, userID string) (_ *domain.Wallet, err error) {
+	ctx, span := startSpan(ctx, "lockWallet", userAttr(userID))
+	defer endSpan(span, &err)
+
+	start := time.Now()
+	wallet, err := u.repo.GetWalletForUpdate(ctx, tx, userID)
+	u.metrics.LockWaited(time.Since(start))
+	return wallet, err
+}
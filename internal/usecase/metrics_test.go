@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"fmt"
+	"payment-service/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics records the error codes of the finished operations.
+type recordingMetrics struct {
+	transfers []string
+	topUps    []string
+	retries   int
+	lockWaits int
+}
+
+func (m *recordingMetrics) TransferFinished(amount int64, err error) {
+	m.transfers = append(m.transfers, ErrorCode(err))
+}
+
+func (m *recordingMetrics) TopUpFinished(amount int64, err error) {
+	m.topUps = append(m.topUps, ErrorCode(err))
+}
+
+func (m *recordingMetrics) Retried(err error)          { m.retries++ }
+func (m *recordingMetrics) LockWaited(d time.Duration) { m.lockWaits++ }
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "ok", ErrorCode(nil))
+	assert.Equal(t, "insufficient_balance", ErrorCode(ErrInsufficientBalance))
+	assert.Equal(t, "wallet_not_found", ErrorCode(fmt.Errorf("%w: sql: no rows", domain.ErrWalletNotFound)))
+	assert.Equal(t, "transaction_not_found", ErrorCode(domain.ErrTransactionNotFound))
+	assert.Equal(t, "tx_conflict", ErrorCode(fmt.Errorf("%w: deadlock detected", domain.ErrTxConflict)))
+	assert.Equal(t, "invalid_request", ErrorCode(fmt.Errorf("%w: pq: invalid input syntax for type uuid", domain.ErrMalformedID)))
+	assert.Equal(t, "internal", ErrorCode(fmt.Errorf("connection refused")))
+}
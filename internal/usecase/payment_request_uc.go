@@ -0,0 +1,293 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidPaymentRequest = errors.New("invalid payment request")
+	// ErrNotPaymentRequestPayer is returned when someone other than the
+	// payer accepts or declines a payment request.
+	ErrNotPaymentRequestPayer = errors.New("only the payer can accept or decline a payment request")
+	// ErrPaymentRequestState is returned when a payment request cannot be
+	// accepted or declined in its current status.
+	ErrPaymentRequestState = errors.New("payment request cannot be answered in its status")
+)
+
+const (
+	// DefaultPaymentRequestExpiry is how long a payment request created
+	// without an expiry can be accepted.
+	DefaultPaymentRequestExpiry = 7 * 24 * time.Hour
+	// MaxPaymentRequestExpiry is the latest a payment request may expire,
+	// counted from its creation.
+	MaxPaymentRequestExpiry = 30 * 24 * time.Hour
+	// MaxPaymentRequestNoteLength is the length of the note column.
+	MaxPaymentRequestNoteLength = 200
+)
+
+// PaymentRequestUsecase lets users request money from each other. The payer
+// of a request accepts it with a transfer made by PaymentUsecase.
+type PaymentRequestUsecase struct {
+	payments *PaymentUsecase
+	requests domain.PaymentRequestRepository
+	now      func() time.Time
+}
+
+func NewPaymentRequestUsecase(payments *PaymentUsecase, requests domain.PaymentRequestRepository) *PaymentRequestUsecase {
+	return &PaymentRequestUsecase{payments: payments, requests: requests, now: time.Now}
+}
+
+// CreatePaymentRequestRequest asks PayerID to pay Amount to RequesterID.
+// ExpiresAt is when the request can no longer be accepted,
+// DefaultPaymentRequestExpiry from now if it is zero.
+type CreatePaymentRequestRequest struct {
+	RequesterID string    `json:"requester_id"`
+	PayerID     string    `json:"payer_id"`
+	Amount      int64     `json:"amount"`
+	Note        string    `json:"note"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// PaymentRequestResponse describes a payment request. TransactionReference
+// is the reference of the transfer of an accepted request.
+type PaymentRequestResponse struct {
+	PaymentRequestID     string    `json:"payment_request_id"`
+	RequesterID          string    `json:"requester_id"`
+	PayerID              string    `json:"payer_id"`
+	Amount               int64     `json:"amount"`
+	Note                 string    `json:"note,omitempty"`
+	Status               string    `json:"status"`
+	ExpiresAt            time.Time `json:"expires_at"`
+	TransactionReference string    `json:"transaction_reference,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// CreatePaymentRequest validates req and stores a pending payment request.
+// Both wallets must exist; whether the payer can pay is checked when the
+// request is accepted.
+func (u *PaymentRequestUsecase) CreatePaymentRequest(ctx context.Context, req CreatePaymentRequestRequest) (_ *PaymentRequestResponse, err error) {
+	ctx, span := startSpan(ctx, "CreatePaymentRequest", attribute.Int64("payment.amount", req.Amount))
+	defer endSpan(span, &err)
+
+	now := u.now()
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = now.Add(DefaultPaymentRequestExpiry)
+	}
+	if err := validatePaymentRequest(req, now); err != nil {
+		return nil, err
+	}
+	for _, userID := range []string{req.RequesterID, req.PayerID} {
+		if _, err := u.payments.repo.GetWalletByUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	request := &domain.PaymentRequest{
+		ID:          uuid.New().String(),
+		RequesterID: req.RequesterID,
+		PayerID:     req.PayerID,
+		Amount:      req.Amount,
+		Note:        req.Note,
+		Status:      domain.PaymentRequestStatusPending,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := u.requests.CreatePaymentRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	return paymentRequestResponse(request, now), nil
+}
+
+func validatePaymentRequest(req CreatePaymentRequestRequest, now time.Time) error {
+	if req.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if req.RequesterID == req.PayerID {
+		return ErrSameUser
+	}
+	if len(req.Note) > MaxPaymentRequestNoteLength {
+		return fmt.Errorf("%w: note must be at most %d characters", ErrInvalidPaymentRequest, MaxPaymentRequestNoteLength)
+	}
+	if !req.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidPaymentRequest)
+	}
+	if req.ExpiresAt.After(now.Add(MaxPaymentRequestExpiry)) {
+		return fmt.Errorf("%w: expires_at must be within %d days", ErrInvalidPaymentRequest, MaxPaymentRequestExpiry/(24*time.Hour))
+	}
+	return nil
+}
+
+func (u *PaymentRequestUsecase) GetPaymentRequest(ctx context.Context, id string) (_ *PaymentRequestResponse, err error) {
+	ctx, span := startSpan(ctx, "GetPaymentRequest", attribute.String("payment_request.id", id))
+	defer endSpan(span, &err)
+
+	request, err := u.requests.GetPaymentRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return paymentRequestResponse(request, u.now()), nil
+}
+
+// ListPaymentRequests returns the payment requests made by or to userID,
+// newest first. A limit of 0 returns DefaultListLimit requests.
+func (u *PaymentRequestUsecase) ListPaymentRequests(ctx context.Context, userID string, limit int) (_ []PaymentRequestResponse, err error) {
+	ctx, span := startSpan(ctx, "ListPaymentRequests", userAttr(userID))
+	defer endSpan(span, &err)
+
+	if userID == "" {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidPaymentRequest)
+	}
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+
+	requests, err := u.requests.ListPaymentRequests(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := u.now()
+	resp := make([]PaymentRequestResponse, 0, len(requests))
+	for i := range requests {
+		resp = append(resp, *paymentRequestResponse(&requests[i], now))
+	}
+	return resp, nil
+}
+
+// AcceptPaymentRequest pays a pending request of payerID. The request is
+// accepted before the transfer is made, so that it cannot be declined
+// meanwhile, and is pending again if the transfer fails. After an
+// unexpected error the request stays accepted; accepting it again finishes
+// the transfer, which is made at most once.
+func (u *PaymentRequestUsecase) AcceptPaymentRequest(ctx context.Context, id, payerID string) (_ *PaymentRequestResponse, err error) {
+	ctx, span := startSpan(ctx, "AcceptPaymentRequest", attribute.String("payment_request.id", id))
+	defer endSpan(span, &err)
+
+	resp, err := u.update(ctx, id, func(p *domain.PaymentRequest, now time.Time) error {
+		if p.PayerID != payerID {
+			return ErrNotPaymentRequestPayer
+		}
+		if status := paymentRequestStatus(p, now); status != domain.PaymentRequestStatusPending &&
+			status != domain.PaymentRequestStatusAccepted {
+			return fmt.Errorf("%w: payment request is %s", ErrPaymentRequestState, status)
+		}
+		p.Status = domain.PaymentRequestStatusAccepted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := TransferRequest{
+		SenderID:   resp.PayerID,
+		ReceiverID: resp.RequesterID,
+		Amount:     resp.Amount,
+		Reference:  paymentRequestReference(id),
+	}
+	transferErr := u.payments.transferOnce(ctx, transfer)
+	if transferErr == nil {
+		return resp, nil
+	}
+	if ErrorCode(transferErr) == "internal" || errors.Is(transferErr, domain.ErrTxConflict) {
+		return nil, transferErr
+	}
+
+	_, err = u.update(ctx, id, func(p *domain.PaymentRequest, now time.Time) error {
+		// A concurrent accept may have paid the request in the meantime. A
+		// different transfer under its reference does not pay it.
+		tx, err := u.payments.repo.GetTransactionByRef(ctx, transfer.Reference)
+		if err == nil && sameTransfer(tx, transfer) {
+			return nil
+		} else if err != nil && !errors.Is(err, domain.ErrTransactionNotFound) {
+			return err
+		}
+		p.Status = domain.PaymentRequestStatusPending
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, transferErr
+}
+
+// DeclinePaymentRequest declines a pending request of payerID.
+func (u *PaymentRequestUsecase) DeclinePaymentRequest(ctx context.Context, id, payerID string) (_ *PaymentRequestResponse, err error) {
+	ctx, span := startSpan(ctx, "DeclinePaymentRequest", attribute.String("payment_request.id", id))
+	defer endSpan(span, &err)
+
+	return u.update(ctx, id, func(p *domain.PaymentRequest, now time.Time) error {
+		if p.PayerID != payerID {
+			return ErrNotPaymentRequestPayer
+		}
+		if status := paymentRequestStatus(p, now); status != domain.PaymentRequestStatusPending {
+			return fmt.Errorf("%w: payment request is %s", ErrPaymentRequestState, status)
+		}
+		p.Status = domain.PaymentRequestStatusDeclined
+		return nil
+	})
+}
+
+// update applies change to the stored payment request, reading it again
+// when another update won the race.
+func (u *PaymentRequestUsecase) update(ctx context.Context, id string, change func(p *domain.PaymentRequest, now time.Time) error) (*PaymentRequestResponse, error) {
+	for {
+		request, err := u.requests.GetPaymentRequest(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		now := u.now()
+		if err := change(request, now); err != nil {
+			return nil, err
+		}
+		request.UpdatedAt = now
+		err = u.requests.UpdatePaymentRequest(ctx, request)
+		if errors.Is(err, domain.ErrPaymentRequestConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return paymentRequestResponse(request, now), nil
+	}
+}
+
+// paymentRequestStatus is the status of p at now, which is expired for
+// pending requests past their expiry.
+func paymentRequestStatus(p *domain.PaymentRequest, now time.Time) string {
+	if p.Status == domain.PaymentRequestStatusPending && !now.Before(p.ExpiresAt) {
+		return domain.PaymentRequestStatusExpired
+	}
+	return p.Status
+}
+
+func paymentRequestResponse(p *domain.PaymentRequest, now time.Time) *PaymentRequestResponse {
+	resp := &PaymentRequestResponse{
+		PaymentRequestID: p.ID,
+		RequesterID:      p.RequesterID,
+		PayerID:          p.PayerID,
+		Amount:           p.Amount,
+		Note:             p.Note,
+		Status:           paymentRequestStatus(p, now),
+		ExpiresAt:        p.ExpiresAt,
+		CreatedAt:        p.CreatedAt,
+		UpdatedAt:        p.UpdatedAt,
+	}
+	if p.Status == domain.PaymentRequestStatusAccepted {
+		resp.TransactionReference = paymentRequestReference(p.ID)
+	}
+	return resp
+}
+
+// paymentRequestReference is the reference of the transfer paying the
+// payment request id.
+func paymentRequestReference(id string) string {
+	return "payment-request-" + id
+}
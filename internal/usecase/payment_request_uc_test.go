@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newPaymentRequestTestUsecase returns a payment request usecase over alice
+// (1000) and bob (0) whose clock is *now.
+func newPaymentRequestTestUsecase(t *testing.T) (*PaymentRequestUsecase, *PaymentUsecase, string, string, *time.Time) {
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	requests := NewPaymentRequestUsecase(uc, repository.NewMemoryPaymentRequestRepo())
+	now := time.Date(2026, time.October, 18, 9, 0, 0, 0, time.UTC)
+	requests.now = func() time.Time { return now }
+	return requests, uc, alice, bob, &now
+}
+
+func TestCreatePaymentRequest_Validation(t *testing.T) {
+	ctx := t.Context()
+	requests, _, alice, bob, now := newPaymentRequestTestUsecase(t)
+	valid := CreatePaymentRequestRequest{RequesterID: bob, PayerID: alice, Amount: 250, Note: "concert tickets"}
+
+	for _, tc := range []struct {
+		name   string
+		change func(req *CreatePaymentRequestRequest)
+		want   error
+	}{
+		{"zero amount", func(req *CreatePaymentRequestRequest) { req.Amount = 0 }, ErrInvalidAmount},
+		{"from the requester", func(req *CreatePaymentRequestRequest) { req.PayerID = bob }, ErrSameUser},
+		{"long note", func(req *CreatePaymentRequestRequest) { req.Note = string(make([]byte, 201)) }, ErrInvalidPaymentRequest},
+		{"expired", func(req *CreatePaymentRequestRequest) { req.ExpiresAt = now.Add(-time.Minute) }, ErrInvalidPaymentRequest},
+		{"expiry too late", func(req *CreatePaymentRequestRequest) { req.ExpiresAt = now.Add(31 * 24 * time.Hour) }, ErrInvalidPaymentRequest},
+		{"unknown payer", func(req *CreatePaymentRequestRequest) { req.PayerID = "carol" }, domain.ErrWalletNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := valid
+			tc.change(&req)
+			_, err := requests.CreatePaymentRequest(ctx, req)
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	resp, err := requests.CreatePaymentRequest(ctx, valid)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusPending, resp.Status)
+	require.Equal(t, now.Add(DefaultPaymentRequestExpiry), resp.ExpiresAt)
+	require.Empty(t, resp.TransactionReference)
+}
+
+func TestAcceptPaymentRequest(t *testing.T) {
+	ctx := t.Context()
+	requests, uc, alice, bob, _ := newPaymentRequestTestUsecase(t)
+	created, err := requests.CreatePaymentRequest(ctx, CreatePaymentRequestRequest{RequesterID: bob, PayerID: alice, Amount: 250})
+	require.NoError(t, err)
+
+	_, err = requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, bob)
+	require.ErrorIs(t, err, ErrNotPaymentRequestPayer)
+
+	accepted, err := requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, alice)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusAccepted, accepted.Status)
+	tx, err := uc.GetTransactionDetails(ctx, accepted.TransactionReference)
+	require.NoError(t, err)
+	require.Equal(t, alice, tx.SenderID)
+	require.Equal(t, bob, tx.ReceiverID)
+	require.Equal(t, int64(250), tx.Amount)
+
+	again, err := requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, alice)
+	require.NoError(t, err, "accepting again does not pay twice")
+	require.Equal(t, accepted.TransactionReference, again.TransactionReference)
+	wallet, err := uc.GetWallet(ctx, bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(250), wallet.Balance)
+
+	_, err = requests.DeclinePaymentRequest(ctx, created.PaymentRequestID, alice)
+	require.ErrorIs(t, err, ErrPaymentRequestState)
+}
+
+func TestAcceptPaymentRequest_InsufficientBalance(t *testing.T) {
+	ctx := t.Context()
+	requests, uc, alice, bob, _ := newPaymentRequestTestUsecase(t)
+	created, err := requests.CreatePaymentRequest(ctx, CreatePaymentRequestRequest{RequesterID: alice, PayerID: bob, Amount: 100})
+	require.NoError(t, err)
+
+	_, err = requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, bob)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	got, err := requests.GetPaymentRequest(ctx, created.PaymentRequestID)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusPending, got.Status, "a failed transfer leaves the request pending")
+
+	_, err = uc.TopUpWallet(ctx, TopUpRequest{UserID: bob, Amount: 100})
+	require.NoError(t, err)
+	accepted, err := requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, bob)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusAccepted, accepted.Status)
+}
+
+func TestAcceptPaymentRequest_ForeignTransferUnderReference(t *testing.T) {
+	ctx := t.Context()
+	requests, uc, alice, bob, _ := newPaymentRequestTestUsecase(t)
+	created, err := requests.CreatePaymentRequest(ctx, CreatePaymentRequestRequest{RequesterID: bob, PayerID: alice, Amount: 250})
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 1, Reference: "payment-request-" + created.PaymentRequestID})
+	require.NoError(t, err)
+
+	_, err = requests.AcceptPaymentRequest(ctx, created.PaymentRequestID, alice)
+	require.ErrorIs(t, err, ErrReferenceExists)
+	got, err := requests.GetPaymentRequest(ctx, created.PaymentRequestID)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusPending, got.Status, "a different transfer does not pay the request")
+}
+
+func TestPaymentRequest_DeclineAndExpiry(t *testing.T) {
+	ctx := t.Context()
+	requests, _, alice, bob, now := newPaymentRequestTestUsecase(t)
+	declined, err := requests.CreatePaymentRequest(ctx, CreatePaymentRequestRequest{RequesterID: bob, PayerID: alice, Amount: 100})
+	require.NoError(t, err)
+	expiring, err := requests.CreatePaymentRequest(ctx, CreatePaymentRequestRequest{
+		RequesterID: alice,
+		PayerID:     bob,
+		Amount:      100,
+		ExpiresAt:   now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	_, err = requests.DeclinePaymentRequest(ctx, declined.PaymentRequestID, bob)
+	require.ErrorIs(t, err, ErrNotPaymentRequestPayer)
+	resp, err := requests.DeclinePaymentRequest(ctx, declined.PaymentRequestID, alice)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusDeclined, resp.Status)
+	_, err = requests.AcceptPaymentRequest(ctx, declined.PaymentRequestID, alice)
+	require.ErrorIs(t, err, ErrPaymentRequestState)
+
+	*now = now.Add(time.Hour)
+	resp, err = requests.GetPaymentRequest(ctx, expiring.PaymentRequestID)
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentRequestStatusExpired, resp.Status)
+	_, err = requests.AcceptPaymentRequest(ctx, expiring.PaymentRequestID, bob)
+	require.ErrorIs(t, err, ErrPaymentRequestState)
+	require.Equal(t, "invalid_payment_request_state", ErrorCode(err))
+
+	for _, user := range []string{alice, bob} {
+		list, err := requests.ListPaymentRequests(ctx, user, 0)
+		require.NoError(t, err)
+		require.Len(t, list, 2, "both parties see both requests")
+	}
+	_, err = requests.ListPaymentRequests(ctx, "", 0)
+	require.ErrorIs(t, err, ErrInvalidPaymentRequest)
+}
@@ -1,11 +1,17 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"math"
+	"math/rand/v2"
 	"payment-service/internal/domain"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -13,14 +19,58 @@ var (
 	ErrInvalidAmount       = errors.New("amount must be greater than zero")
 	ErrSameUser            = errors.New("cannot transfer to the same user")
 	ErrReferenceExists     = errors.New("reference ID already exists")
+	ErrVersionMismatch     = errors.New("wallet version does not match expected version")
+	ErrEmptyUsername       = errors.New("username must not be empty")
+	ErrWalletFrozen        = errors.New("wallet is frozen")
 )
 
 type PaymentUsecase struct {
-	repo domain.TransactionRepository
+	repo       domain.TransactionRepository
+	retry      RetryPolicy
+	sleep      func(context.Context, time.Duration) error
+	optimistic bool
+	metrics    Metrics
+	logger     *slog.Logger
 }
 
-func NewPaymentUsecase(repo domain.TransactionRepository) *PaymentUsecase {
-	return &PaymentUsecase{repo: repo}
+// RetryPolicy controls how often a transaction aborted with
+// domain.ErrTxConflict is retried. Delays grow exponentially from BaseDelay up
+// to MaxDelay and are fully jittered so that competing requests spread out.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    250 * time.Millisecond,
+}
+
+type Option func(*PaymentUsecase)
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(u *PaymentUsecase) {
+		u.retry = p
+	}
+}
+
+// WithOptimisticLocking makes balance updates conditional on the wallet version
+// read at the start of the operation instead of holding row locks. Conflicting
+// updates are retried according to the retry policy.
+func WithOptimisticLocking() Option {
+	return func(u *PaymentUsecase) {
+		u.optimistic = true
+	}
+}
+
+func NewPaymentUsecase(repo domain.TransactionRepository, opts ...Option) *PaymentUsecase {
+	u := &PaymentUsecase{repo: repo, retry: DefaultRetryPolicy, sleep: sleepContext, metrics: noopMetrics{}, logger: slog.New(slog.DiscardHandler)}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
 }
 
 type TransferRequest struct {
@@ -28,25 +78,47 @@ type TransferRequest struct {
 	ReceiverID string `json:"receiver_id"`
 	Amount     int64  `json:"amount"`
 	Reference  string `json:"reference"`
+
+	// ExpectedVersion, when set, fails the transfer with ErrVersionMismatch
+	// unless the sender wallet is at this version.
+	ExpectedVersion *int `json:"-"`
 }
 
+// TransferResponse describes a transaction. SenderID and ReceiverID are
+// empty for the sides a transaction does not have, such as the sender of a
+// top up.
 type TransferResponse struct {
-	TransactionID string
-	Reference     string
-	Amount        int64
-	Status        string
-	CreatedAt     time.Time
+	TransactionID string    `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	SenderID      string    `json:"sender_id,omitempty"`
+	ReceiverID    string    `json:"receiver_id,omitempty"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type TopUpRequest struct {
 	UserID string `json:"user_id"`
 	Amount int64  `json:"amount"`
+
+	// ExpectedVersion, when set, fails the top up with ErrVersionMismatch
+	// unless the wallet is at this version.
+	ExpectedVersion *int `json:"-"`
 }
 
 type TopUpResponse struct {
-	UserID  string
-	Amount  int64
-	Balance int64
+	UserID  string `json:"user_id"`
+	Amount  int64  `json:"amount"`
+	Balance int64  `json:"balance"`
+}
+
+// CreateWalletRequest registers a user together with their wallet. UserID and
+// WalletID are generated when empty.
+type CreateWalletRequest struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	WalletID string `json:"wallet_id"`
+	Balance  int64  `json:"balance"`
 }
 
 type GetWalletResponse struct {
@@ -54,11 +126,22 @@ type GetWalletResponse struct {
 	UserID    string    `json:"user_id"`
 	Balance   int64     `json:"balance"`
 	Version   int       `json:"version"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-func (u *PaymentUsecase) TransferFunds(req TransferRequest) (*TransferResponse, error) {
+func (u *PaymentUsecase) TransferFunds(ctx context.Context, req TransferRequest) (_ *TransferResponse, err error) {
+	ctx, span := startSpan(ctx, "TransferFunds", attribute.String("payment.reference", req.Reference), attribute.Int64("payment.amount", req.Amount))
+	defer endSpan(span, &err)
+
+	resp, err := u.transferFunds(ctx, req)
+	u.metrics.TransferFinished(req.Amount, err)
+	u.logTransfer(ctx, req, resp, err)
+	return resp, err
+}
+
+func (u *PaymentUsecase) transferFunds(ctx context.Context, req TransferRequest) (*TransferResponse, error) {
 	if req.Amount <= 0 {
 		return nil, ErrInvalidAmount
 	}
@@ -67,44 +150,167 @@ func (u *PaymentUsecase) TransferFunds(req TransferRequest) (*TransferResponse,
 		return nil, ErrSameUser
 	}
 
-	existingTx, err := u.repo.GetTransactionByRef(req.Reference)
+	existingTx, err := u.repo.GetTransactionByRef(ctx, req.Reference)
 	if err == nil && existingTx != nil {
 		return nil, ErrReferenceExists
 	}
 
-	tx, err := u.repo.BeginTx()
+	var resp *TransferResponse
+	err = u.withRetry(ctx, func() error {
+		var err error
+		if u.optimistic {
+			resp, err = u.transferOptimistic(ctx, req)
+		} else {
+			resp, err = u.transfer(ctx, req)
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer u.repo.RollbackTx(tx)
+	return resp, nil
+}
+
+// transferOnce makes the transfer of req unless it was made before: a
+// transaction under req.Reference with the same sender, receiver and amount
+// counts as done. Callers use it to repeat transfers that may have been
+// made by an earlier attempt.
+func (u *PaymentUsecase) transferOnce(ctx context.Context, req TransferRequest) error {
+	_, err := u.transferOrAdopt(ctx, req)
+	return err
+}
 
-	senderWallet, err := u.repo.GetWalletForUpdate(tx, req.SenderID)
+// transferOrAdopt is transferOnce for callers that need the transaction: it
+// returns the response of the transfer, or of the earlier transfer it
+// adopts.
+func (u *PaymentUsecase) transferOrAdopt(ctx context.Context, req TransferRequest) (*TransferResponse, error) {
+	resp, err := u.TransferFunds(ctx, req)
+	if !errors.Is(err, ErrReferenceExists) {
+		return resp, err
+	}
+	tx, getErr := u.repo.GetTransactionByRef(ctx, req.Reference)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if !sameTransfer(tx, req) {
+		return nil, err
+	}
+	return &TransferResponse{
+		TransactionID: tx.ID,
+		Reference:     tx.Reference,
+		SenderID:      tx.SenderID,
+		ReceiverID:    tx.ReceiverID,
+		Amount:        tx.Amount,
+		Status:        tx.Status,
+		CreatedAt:     tx.CreatedAt,
+	}, nil
+}
+
+// sameTransfer reports whether tx is the transfer req asks for.
+func sameTransfer(tx *domain.Transaction, req TransferRequest) bool {
+	return tx.SenderID == req.SenderID && tx.ReceiverID == req.ReceiverID && tx.Amount == req.Amount
+}
+
+func (u *PaymentUsecase) transfer(ctx context.Context, req TransferRequest) (*TransferResponse, error) {
+	tx, err := u.repo.BeginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer u.repo.RollbackTx(tx)
+
+	// Lock both wallets in a stable order so that concurrent A->B and B->A
+	// transfers queue up behind each other instead of deadlocking. user_id is
+	// unique per wallet, so ordering by it is a total order over wallets.
+	wallets := make(map[string]*domain.Wallet, 2)
+	for _, userID := range lockOrder(req.SenderID, req.ReceiverID) {
+		wallet, err := u.lockWallet(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		wallets[userID] = wallet
+	}
+	senderWallet := wallets[req.SenderID]
+	receiverWallet := wallets[req.ReceiverID]
+
+	if err := checkVersion(senderWallet, req.ExpectedVersion); err != nil {
+		return nil, err
+	}
+
+	if isFrozen(senderWallet) || isFrozen(receiverWallet) {
+		return nil, ErrWalletFrozen
+	}
 
 	if senderWallet.Balance < req.Amount {
 		return nil, ErrInsufficientBalance
 	}
 
-	receiverWallet, err := u.repo.GetWalletForUpdate(tx, req.ReceiverID)
+	err = u.repo.UpdateWalletBalance(ctx, tx, senderWallet.ID, -req.Amount)
 	if err != nil {
 		return nil, err
 	}
 
-	err = u.repo.UpdateWalletBalance(tx, senderWallet.ID, -req.Amount)
+	err = u.repo.UpdateWalletBalance(ctx, tx, receiverWallet.ID, req.Amount)
 	if err != nil {
 		return nil, err
 	}
 
-	err = u.repo.UpdateWalletBalance(tx, receiverWallet.ID, req.Amount)
+	return u.completeTransfer(ctx, tx, req)
+}
+
+// transferOptimistic reads both wallets without locking them and makes each
+// balance update conditional on the version read. A concurrent change to
+// either wallet surfaces as domain.ErrVersionConflict and is retried.
+func (u *PaymentUsecase) transferOptimistic(ctx context.Context, req TransferRequest) (*TransferResponse, error) {
+	senderWallet, err := u.repo.GetWalletByUserID(ctx, req.SenderID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkVersion(senderWallet, req.ExpectedVersion); err != nil {
+		return nil, err
+	}
+
+	if senderWallet.Balance < req.Amount {
+		return nil, ErrInsufficientBalance
+	}
+
+	receiverWallet, err := u.repo.GetWalletByUserID(ctx, req.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if isFrozen(senderWallet) || isFrozen(receiverWallet) {
+		return nil, ErrWalletFrozen
+	}
+
+	tx, err := u.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer u.repo.RollbackTx(tx)
+
+	// The conditional UPDATEs still take row locks, so apply them in the same
+	// order as the pessimistic path.
+	wallets := map[string]*domain.Wallet{req.SenderID: senderWallet, req.ReceiverID: receiverWallet}
+	amounts := map[string]int64{req.SenderID: -req.Amount, req.ReceiverID: req.Amount}
+	for _, userID := range lockOrder(req.SenderID, req.ReceiverID) {
+		wallet := wallets[userID]
+		err = u.repo.UpdateWalletBalanceIfVersion(ctx, tx, wallet.ID, amounts[userID], wallet.Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return u.completeTransfer(ctx, tx, req)
+}
+
+// completeTransfer records the transaction and commits tx once both balances
+// have been moved.
+func (u *PaymentUsecase) completeTransfer(ctx context.Context, tx interface{}, req TransferRequest) (*TransferResponse, error) {
 	transaction := &domain.Transaction{
 		ID:         uuid.New().String(),
 		Reference:  req.Reference,
+		Type:       domain.TransactionTypeTransfer,
 		SenderID:   req.SenderID,
 		ReceiverID: req.ReceiverID,
 		Amount:     req.Amount,
@@ -112,7 +318,11 @@ func (u *PaymentUsecase) TransferFunds(req TransferRequest) (*TransferResponse,
 		CreatedAt:  time.Now(),
 	}
 
-	err = u.repo.CreateTransaction(tx, transaction)
+	err := u.repo.CreateTransaction(ctx, tx, transaction)
+	if errors.Is(err, domain.ErrDuplicateReference) {
+		// Lost the race against a concurrent request with the same reference.
+		return nil, ErrReferenceExists
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -125,14 +335,19 @@ func (u *PaymentUsecase) TransferFunds(req TransferRequest) (*TransferResponse,
 	return &TransferResponse{
 		TransactionID: transaction.ID,
 		Reference:     transaction.Reference,
+		SenderID:      transaction.SenderID,
+		ReceiverID:    transaction.ReceiverID,
 		Amount:        transaction.Amount,
 		Status:        transaction.Status,
 		CreatedAt:     transaction.CreatedAt,
 	}, nil
 }
 
-func (u *PaymentUsecase) GetTransactionByRef(refID string) (*TransferResponse, error) {
-	tx, err := u.repo.GetTransactionByRef(refID)
+func (u *PaymentUsecase) GetTransactionByRef(ctx context.Context, refID string) (_ *TransferResponse, err error) {
+	ctx, span := startSpan(ctx, "GetTransactionByRef", attribute.String("payment.reference", refID))
+	defer endSpan(span, &err)
+
+	tx, err := u.repo.GetTransactionByRef(ctx, refID)
 	if err != nil {
 		return nil, err
 	}
@@ -140,29 +355,78 @@ func (u *PaymentUsecase) GetTransactionByRef(refID string) (*TransferResponse, e
 	return &TransferResponse{
 		TransactionID: tx.ID,
 		Reference:     tx.Reference,
+		SenderID:      tx.SenderID,
+		ReceiverID:    tx.ReceiverID,
 		Amount:        tx.Amount,
 		Status:        tx.Status,
 		CreatedAt:     tx.CreatedAt,
 	}, nil
 }
 
-func (u *PaymentUsecase) TopUpWallet(req TopUpRequest) (*TopUpResponse, error) {
+func (u *PaymentUsecase) TopUpWallet(ctx context.Context, req TopUpRequest) (_ *TopUpResponse, err error) {
+	ctx, span := startSpan(ctx, "TopUpWallet", userAttr(req.UserID), attribute.Int64("payment.amount", req.Amount))
+	defer endSpan(span, &err)
+
+	resp, err := u.topUpWallet(ctx, req)
+	u.metrics.TopUpFinished(req.Amount, err)
+	return resp, err
+}
+
+func (u *PaymentUsecase) topUpWallet(ctx context.Context, req TopUpRequest) (*TopUpResponse, error) {
 	if req.Amount <= 0 {
 		return nil, ErrInvalidAmount
 	}
 
-	tx, err := u.repo.BeginTx()
+	var resp *TopUpResponse
+	err := u.withRetry(ctx, func() error {
+		var err error
+		if u.optimistic {
+			resp, err = u.topUpOptimistic(ctx, req)
+		} else {
+			resp, err = u.topUp(ctx, req)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (u *PaymentUsecase) topUp(ctx context.Context, req TopUpRequest) (*TopUpResponse, error) {
+	tx, err := u.repo.BeginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer u.repo.RollbackTx(tx)
 
-	err = u.repo.TopUpWallet(tx, req.UserID, req.Amount)
+	if req.ExpectedVersion != nil {
+		wallet, err := u.lockWallet(ctx, tx, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(wallet, req.ExpectedVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.repo.TopUpWallet(ctx, tx, req.UserID, req.Amount)
 	if err != nil {
 		return nil, err
 	}
 
-	wallet, err := u.repo.GetWalletForUpdate(tx, req.UserID) // Get the updated wallet to return the new balance
+	wallet, err := u.lockWallet(ctx, tx, req.UserID) // Get the updated wallet to return the new balance
+	if err != nil {
+		return nil, err
+	}
+
+	// The wallet is locked by now, so its status cannot change before the
+	// commit. Rolling back undoes the top up.
+	if isFrozen(wallet) {
+		return nil, ErrWalletFrozen
+	}
+
+	err = u.repo.CreateTransaction(ctx, tx, topUpTransaction(req))
 	if err != nil {
 		return nil, err
 	}
@@ -179,8 +443,109 @@ func (u *PaymentUsecase) TopUpWallet(req TopUpRequest) (*TopUpResponse, error) {
 	}, nil
 }
 
-func (u *PaymentUsecase) GetWallet(userID string) (*GetWalletResponse, error) {
-	wallet, err := u.repo.GetWalletByUserID(userID)
+func topUpTransaction(req TopUpRequest) *domain.Transaction {
+	id := uuid.New().String()
+	return &domain.Transaction{
+		ID:         id,
+		Reference:  "TOPUP-" + id,
+		Type:       domain.TransactionTypeTopUp,
+		ReceiverID: req.UserID,
+		Amount:     req.Amount,
+		Status:     "completed",
+		CreatedAt:  time.Now(),
+	}
+}
+
+func (u *PaymentUsecase) topUpOptimistic(ctx context.Context, req TopUpRequest) (*TopUpResponse, error) {
+	wallet, err := u.repo.GetWalletByUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(wallet, req.ExpectedVersion); err != nil {
+		return nil, err
+	}
+
+	if isFrozen(wallet) {
+		return nil, ErrWalletFrozen
+	}
+
+	tx, err := u.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer u.repo.RollbackTx(tx)
+
+	err = u.repo.UpdateWalletBalanceIfVersion(ctx, tx, wallet.ID, req.Amount, wallet.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.repo.CreateTransaction(ctx, tx, topUpTransaction(req))
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.repo.CommitTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TopUpResponse{
+		UserID:  req.UserID,
+		Amount:  req.Amount,
+		Balance: wallet.Balance + req.Amount,
+	}, nil
+}
+
+func (u *PaymentUsecase) CreateWallet(ctx context.Context, req CreateWalletRequest) (_ *GetWalletResponse, err error) {
+	ctx, span := startSpan(ctx, "CreateWallet")
+	defer endSpan(span, &err)
+
+	if req.Username == "" {
+		return nil, ErrEmptyUsername
+	}
+	if req.Balance < 0 {
+		return nil, ErrInvalidAmount
+	}
+	if req.UserID == "" {
+		req.UserID = uuid.New().String()
+	}
+	if req.WalletID == "" {
+		req.WalletID = uuid.New().String()
+	}
+
+	user := &domain.User{ID: req.UserID, Username: req.Username}
+	wallet := &domain.Wallet{ID: req.WalletID, UserID: req.UserID, Balance: req.Balance, Version: 1}
+
+	tx, err := u.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer u.repo.RollbackTx(tx)
+
+	err = u.repo.CreateWallet(ctx, tx, user, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the initial balance so that it is accounted for by the ledger.
+	if wallet.Balance > 0 {
+		err = u.repo.CreateTransaction(ctx, tx, &domain.Transaction{
+			ID:         wallet.ID,
+			Reference:  "OPENING-" + wallet.ID,
+			Type:       domain.TransactionTypeOpening,
+			ReceiverID: wallet.UserID,
+			Amount:     wallet.Balance,
+			Status:     "completed",
+			CreatedAt:  wallet.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.repo.CommitTx(tx)
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +555,105 @@ func (u *PaymentUsecase) GetWallet(userID string) (*GetWalletResponse, error) {
 		UserID:    wallet.UserID,
 		Balance:   wallet.Balance,
 		Version:   wallet.Version,
+		Status:    wallet.Status,
 		CreatedAt: wallet.CreatedAt,
 		UpdatedAt: wallet.UpdatedAt,
 	}, nil
 }
+
+func (u *PaymentUsecase) GetWallet(ctx context.Context, userID string) (_ *GetWalletResponse, err error) {
+	ctx, span := startSpan(ctx, "GetWallet", userAttr(userID))
+	defer endSpan(span, &err)
+
+	wallet, err := u.repo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetWalletResponse{
+		WalletID:  wallet.ID,
+		UserID:    wallet.UserID,
+		Balance:   wallet.Balance,
+		Version:   wallet.Version,
+		Status:    wallet.Status,
+		CreatedAt: wallet.CreatedAt,
+		UpdatedAt: wallet.UpdatedAt,
+	}, nil
+}
+
+// withRetry runs fn until it succeeds, fails with an error other than
+// domain.ErrTxConflict or domain.ErrVersionConflict, or the retry policy is
+// exhausted. It returns ctx.Err() when ctx ends while waiting for a retry.
+func (u *PaymentUsecase) withRetry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < max(u.retry.MaxAttempts, 1); attempt++ {
+		if attempt > 0 {
+			if err := u.sleep(ctx, u.retry.backoff(attempt)); err != nil {
+				return err
+			}
+		}
+		err = fn()
+		if !errors.Is(err, domain.ErrTxConflict) && !errors.Is(err, domain.ErrVersionConflict) {
+			return err
+		}
+		if attempt+1 < u.retry.MaxAttempts {
+			u.metrics.Retried(err)
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+				attribute.Int("retry.attempt", attempt+1),
+				attribute.String("retry.reason", ErrorCode(err)),
+			))
+		}
+	}
+	return err
+}
+
+// sleepContext waits for d or until ctx ends, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns the jittered delay before retrying after attempt: BaseDelay
+// doubled attempt-1 times, capped at MaxDelay. Without MaxDelay the doubling
+// stops before it would overflow.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay <= math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay)
+}
+
+// lockOrder returns the two user IDs in the order their wallets must be locked.
+func lockOrder(a, b string) []string {
+	if b < a {
+		return []string{b, a}
+	}
+	return []string{a, b}
+}
+
+func isFrozen(w *domain.Wallet) bool {
+	return w.Status == domain.WalletStatusFrozen
+}
+
+func checkVersion(wallet *domain.Wallet, expected *int) error {
+	if expected != nil && wallet.Version != *expected {
+		return ErrVersionMismatch
+	}
+	return nil
+}
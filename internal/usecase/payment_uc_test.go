@@ -2,20 +2,24 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"payment-service/internal/domain"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-// MockTransactionRepository is a mock implementation of domain.TransactionRepository
+// MockTransactionRepository is a mock implementation of domain.TransactionRepository.
+// The context is not recorded, expectations only match the other arguments.
 type MockTransactionRepository struct {
 	mock.Mock
 }
 
-func (m *MockTransactionRepository) GetWalletForUpdate(tx interface{}, userID string) (*domain.Wallet, error) {
+func (m *MockTransactionRepository) GetWalletForUpdate(ctx context.Context, tx interface{}, userID string) (*domain.Wallet, error) {
 	args := m.Called(tx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -23,17 +27,22 @@ func (m *MockTransactionRepository) GetWalletForUpdate(tx interface{}, userID st
 	return args.Get(0).(*domain.Wallet), args.Error(1)
 }
 
-func (m *MockTransactionRepository) UpdateWalletBalance(tx interface{}, walletID string, amount int64) error {
+func (m *MockTransactionRepository) UpdateWalletBalance(ctx context.Context, tx interface{}, walletID string, amount int64) error {
 	args := m.Called(tx, walletID, amount)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) CreateTransaction(tx interface{}, transaction *domain.Transaction) error {
+func (m *MockTransactionRepository) UpdateWalletBalanceIfVersion(ctx context.Context, tx interface{}, walletID string, amount int64, expectedVersion int) error {
+	args := m.Called(tx, walletID, amount, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) CreateTransaction(ctx context.Context, tx interface{}, transaction *domain.Transaction) error {
 	args := m.Called(tx, transaction)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) GetTransactionByRef(refID string) (*domain.Transaction, error) {
+func (m *MockTransactionRepository) GetTransactionByRef(ctx context.Context, refID string) (*domain.Transaction, error) {
 	args := m.Called(refID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -41,7 +50,7 @@ func (m *MockTransactionRepository) GetTransactionByRef(refID string) (*domain.T
 	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
-func (m *MockTransactionRepository) BeginTx() (interface{}, error) {
+func (m *MockTransactionRepository) BeginTx(ctx context.Context) (interface{}, error) {
 	args := m.Called()
 	return args.Get(0), args.Error(1)
 }
@@ -56,12 +65,12 @@ func (m *MockTransactionRepository) RollbackTx(tx interface{}) error {
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) TopUpWallet(tx interface{}, userID string, amount int64) error {
+func (m *MockTransactionRepository) TopUpWallet(ctx context.Context, tx interface{}, userID string, amount int64) error {
 	args := m.Called(tx, userID, amount)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) GetWalletByUserID(userID string) (*domain.Wallet, error) {
+func (m *MockTransactionRepository) GetWalletByUserID(ctx context.Context, userID string) (*domain.Wallet, error) {
 	args := m.Called(userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -69,7 +78,34 @@ func (m *MockTransactionRepository) GetWalletByUserID(userID string) (*domain.Wa
 	return args.Get(0).(*domain.Wallet), args.Error(1)
 }
 
+func (m *MockTransactionRepository) CreateWallet(ctx context.Context, tx interface{}, user *domain.User, wallet *domain.Wallet) error {
+	args := m.Called(tx, user, wallet)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) SetWalletStatus(ctx context.Context, tx interface{}, walletID string, status string) error {
+	args := m.Called(tx, walletID, status)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetLedger(ctx context.Context) (*domain.Ledger, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ledger), args.Error(1)
+}
+
 func TestTopUpWallet(t *testing.T) {
+	ctx := t.Context()
 	mockRepo := new(MockTransactionRepository)
 	uc := NewPaymentUsecase(mockRepo)
 
@@ -98,6 +134,7 @@ func TestTopUpWallet(t *testing.T) {
 					Balance: 11000,
 					Version: 1,
 				}, nil).Once()
+				mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil).Once()
 				mockRepo.On("CommitTx", mockTx).Return(nil).Once()
 				mockRepo.On("RollbackTx", mockTx).Return(nil).Once()
 			},
@@ -176,6 +213,7 @@ func TestTopUpWallet(t *testing.T) {
 					Balance: 11000,
 					Version: 1,
 				}, nil).Once()
+				mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil).Once()
 				mockRepo.On("CommitTx", mockTx).Return(errors.New("commit error")).Once()
 				mockRepo.On("RollbackTx", mock.Anything).Return(nil).Once()
 			},
@@ -189,7 +227,7 @@ func TestTopUpWallet(t *testing.T) {
 			mockRepo.Calls = []mock.Call{} // Clear previous mocks
 			tt.mock()
 
-			got, err := uc.TopUpWallet(tt.req)
+			got, err := uc.TopUpWallet(ctx, tt.req)
 
 			if tt.err != nil {
 				assert.Error(t, err)
@@ -206,3 +244,198 @@ func TestTopUpWallet(t *testing.T) {
 		})
 	}
 }
+
+func TestTransferFunds_LocksWalletsInStableOrder(t *testing.T) {
+	ctx := t.Context()
+	mockRepo := new(MockTransactionRepository)
+	uc := NewPaymentUsecase(mockRepo)
+	mockTx := &struct{}{}
+
+	// The receiver sorts before the sender, so it must be locked first.
+	mockRepo.On("GetTransactionByRef", "ref-1").Return(nil, errors.New("not found")).Once()
+	mockRepo.On("BeginTx").Return(mockTx, nil).Once()
+	mockRepo.On("GetWalletForUpdate", mockTx, "aaa").Return(&domain.Wallet{ID: "wallet-aaa", UserID: "aaa", Balance: 0}, nil).Once()
+	mockRepo.On("GetWalletForUpdate", mockTx, "bbb").Return(&domain.Wallet{ID: "wallet-bbb", UserID: "bbb", Balance: 500}, nil).Once()
+	mockRepo.On("UpdateWalletBalance", mockTx, "wallet-bbb", int64(-100)).Return(nil).Once()
+	mockRepo.On("UpdateWalletBalance", mockTx, "wallet-aaa", int64(100)).Return(nil).Once()
+	mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil).Once()
+	mockRepo.On("CommitTx", mockTx).Return(nil).Once()
+	mockRepo.On("RollbackTx", mockTx).Return(nil).Once()
+
+	got, err := uc.TransferFunds(ctx, TransferRequest{SenderID: "bbb", ReceiverID: "aaa", Amount: 100, Reference: "ref-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", got.Status)
+
+	var locked []string
+	for _, call := range mockRepo.Calls {
+		if call.Method == "GetWalletForUpdate" {
+			locked = append(locked, call.Arguments.String(1))
+		}
+	}
+	assert.Equal(t, []string{"aaa", "bbb"}, locked)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransferFunds_RetriesOnTxConflict(t *testing.T) {
+	ctx := t.Context()
+	conflict := fmt.Errorf("%w: deadlock detected", domain.ErrTxConflict)
+	req := TransferRequest{SenderID: "aaa", ReceiverID: "bbb", Amount: 100, Reference: "ref-2"}
+
+	tests := []struct {
+		name        string
+		commitErrs  []error
+		wantErr     error
+		wantBegins  int
+		wantSleeps  int
+		maxAttempts int
+	}{
+		{
+			name:        "Succeeds after conflict",
+			commitErrs:  []error{conflict, nil},
+			wantBegins:  2,
+			wantSleeps:  1,
+			maxAttempts: 3,
+		},
+		{
+			name:        "Gives up after max attempts",
+			commitErrs:  []error{conflict, conflict},
+			wantErr:     domain.ErrTxConflict,
+			wantBegins:  2,
+			wantSleeps:  1,
+			maxAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockTransactionRepository)
+			metrics := &recordingMetrics{}
+			uc := NewPaymentUsecase(mockRepo,
+				WithRetryPolicy(RetryPolicy{MaxAttempts: tt.maxAttempts, BaseDelay: time.Millisecond}),
+				WithMetrics(metrics))
+			var sleeps int
+			uc.sleep = func(context.Context, time.Duration) error { sleeps++; return nil }
+			mockTx := &struct{}{}
+
+			mockRepo.On("GetTransactionByRef", "ref-2").Return(nil, errors.New("not found")).Once()
+			mockRepo.On("BeginTx").Return(mockTx, nil)
+			mockRepo.On("GetWalletForUpdate", mockTx, "aaa").Return(&domain.Wallet{ID: "wallet-aaa", UserID: "aaa", Balance: 500}, nil)
+			mockRepo.On("GetWalletForUpdate", mockTx, "bbb").Return(&domain.Wallet{ID: "wallet-bbb", UserID: "bbb"}, nil)
+			mockRepo.On("UpdateWalletBalance", mockTx, mock.Anything, mock.Anything).Return(nil)
+			mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil)
+			for _, commitErr := range tt.commitErrs {
+				mockRepo.On("CommitTx", mockTx).Return(commitErr).Once()
+			}
+			mockRepo.On("RollbackTx", mockTx).Return(nil)
+
+			got, err := uc.TransferFunds(ctx, req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, got)
+			}
+			mockRepo.AssertNumberOfCalls(t, "BeginTx", tt.wantBegins)
+			assert.Equal(t, tt.wantSleeps, sleeps)
+			assert.Equal(t, tt.wantSleeps, metrics.retries)
+			assert.Equal(t, []string{ErrorCode(tt.wantErr)}, metrics.transfers)
+			assert.Equal(t, 2*tt.wantBegins, metrics.lockWaits)
+		})
+	}
+}
+
+func TestWithRetry_StopsWaitingWhenContextEnds(t *testing.T) {
+	uc := NewPaymentUsecase(new(MockTransactionRepository), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}))
+	ctx, cancel := context.WithCancel(t.Context())
+	calls := 0
+	err := uc.withRetry(ctx, func() error {
+		calls++
+		cancel()
+		return domain.ErrTxConflict
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "the retry is not attempted after the context ended")
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	for _, p := range []RetryPolicy{
+		{BaseDelay: time.Millisecond, MaxDelay: 250 * time.Millisecond},
+		{BaseDelay: time.Millisecond},
+		{BaseDelay: time.Hour, MaxDelay: time.Millisecond},
+		{},
+	} {
+		for attempt := 1; attempt <= 70; attempt++ {
+			delay := p.backoff(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0), "%+v attempt %d", p, attempt)
+			if p.MaxDelay > 0 {
+				assert.LessOrEqual(t, delay, p.MaxDelay, "%+v attempt %d", p, attempt)
+			}
+		}
+	}
+}
+
+func TestTransferFunds_Optimistic(t *testing.T) {
+	ctx := t.Context()
+	req := TransferRequest{SenderID: "aaa", ReceiverID: "bbb", Amount: 100, Reference: "ref-3"}
+	mockTx := &struct{}{}
+
+	t.Run("Retries on version conflict", func(t *testing.T) {
+		mockRepo := new(MockTransactionRepository)
+		uc := NewPaymentUsecase(mockRepo, WithOptimisticLocking())
+		uc.sleep = func(context.Context, time.Duration) error { return nil }
+
+		mockRepo.On("GetTransactionByRef", "ref-3").Return(nil, errors.New("not found")).Once()
+		mockRepo.On("GetWalletByUserID", "aaa").Return(&domain.Wallet{ID: "wallet-aaa", UserID: "aaa", Balance: 500, Version: 1}, nil).Once()
+		mockRepo.On("GetWalletByUserID", "bbb").Return(&domain.Wallet{ID: "wallet-bbb", UserID: "bbb", Version: 4}, nil).Once()
+		mockRepo.On("GetWalletByUserID", "aaa").Return(&domain.Wallet{ID: "wallet-aaa", UserID: "aaa", Balance: 500, Version: 2}, nil).Once()
+		mockRepo.On("GetWalletByUserID", "bbb").Return(&domain.Wallet{ID: "wallet-bbb", UserID: "bbb", Version: 4}, nil).Once()
+		mockRepo.On("BeginTx").Return(mockTx, nil)
+		mockRepo.On("UpdateWalletBalanceIfVersion", mockTx, "wallet-aaa", int64(-100), 1).Return(domain.ErrVersionConflict).Once()
+		mockRepo.On("UpdateWalletBalanceIfVersion", mockTx, "wallet-aaa", int64(-100), 2).Return(nil).Once()
+		mockRepo.On("UpdateWalletBalanceIfVersion", mockTx, "wallet-bbb", int64(100), 4).Return(nil).Once()
+		mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil).Once()
+		mockRepo.On("CommitTx", mockTx).Return(nil).Once()
+		mockRepo.On("RollbackTx", mockTx).Return(nil)
+
+		got, err := uc.TransferFunds(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, "completed", got.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Expected version mismatch", func(t *testing.T) {
+		mockRepo := new(MockTransactionRepository)
+		uc := NewPaymentUsecase(mockRepo, WithOptimisticLocking())
+		expected := 7
+		req := req
+		req.ExpectedVersion = &expected
+
+		mockRepo.On("GetTransactionByRef", "ref-3").Return(nil, errors.New("not found")).Once()
+		mockRepo.On("GetWalletByUserID", "aaa").Return(&domain.Wallet{ID: "wallet-aaa", UserID: "aaa", Balance: 500, Version: 1}, nil).Once()
+
+		got, err := uc.TransferFunds(ctx, req)
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+		assert.Nil(t, got)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestTopUpWallet_Optimistic(t *testing.T) {
+	ctx := t.Context()
+	mockRepo := new(MockTransactionRepository)
+	uc := NewPaymentUsecase(mockRepo, WithOptimisticLocking())
+	mockTx := &struct{}{}
+
+	mockRepo.On("GetWalletByUserID", "111").Return(&domain.Wallet{ID: "wallet-111", UserID: "111", Balance: 10000, Version: 3}, nil).Once()
+	mockRepo.On("BeginTx").Return(mockTx, nil).Once()
+	mockRepo.On("UpdateWalletBalanceIfVersion", mockTx, "wallet-111", int64(1000), 3).Return(nil).Once()
+	mockRepo.On("CreateTransaction", mockTx, mock.Anything).Return(nil).Once()
+	mockRepo.On("CommitTx", mockTx).Return(nil).Once()
+	mockRepo.On("RollbackTx", mockTx).Return(nil).Once()
+
+	got, err := uc.TopUpWallet(ctx, TopUpRequest{UserID: "111", Amount: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11000), got.Balance)
+	mockRepo.AssertExpectations(t)
+}
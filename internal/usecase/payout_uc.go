@@ -0,0 +1,285 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Bulk payouts pay the rows of a file uploaded by finance from one sender.
+// The whole file is validated before the first transfer is made; see
+// internal/payout for the file formats.
+
+var (
+	ErrInvalidPayout = errors.New("payout file has invalid rows")
+	ErrEmptyPayout   = errors.New("payout file has no rows")
+	// ErrMalformedRow is the error of rows with a missing or unreadable
+	// field.
+	ErrMalformedRow = errors.New("malformed payout row")
+)
+
+// MaxPayoutRows bounds the rows of one payout file, which is executed within
+// a single request.
+const MaxPayoutRows = 1000
+
+// maxReferenceLength is the length of the reference column of transactions.
+const maxReferenceLength = 100
+
+// PayoutRow is one transfer of a payout file. Line is its line in the file,
+// or its position for formats without lines. Note is not stored; it is
+// echoed in the results.
+type PayoutRow struct {
+	Line       int    `json:"line"`
+	ReceiverID string `json:"receiver_id"`
+	Amount     int64  `json:"amount"`
+	Reference  string `json:"reference"`
+	Note       string `json:"note,omitempty"`
+}
+
+// PayoutRowError explains why a row cannot be paid. Line is 0 for problems
+// of the file as a whole, such as a total above the sender's balance. Code is
+// an ErrorCode value.
+type PayoutRowError struct {
+	Line    int    `json:"line,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e PayoutRowError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// PayoutRequest pays Rows from SenderID. Invalid lists the rows the file
+// reader could not parse; they are reported with the other validation
+// errors.
+type PayoutRequest struct {
+	SenderID string
+	Rows     []PayoutRow
+	Invalid  []PayoutRowError
+}
+
+// PayoutSummary is the result of validating a payout file. The file can be
+// executed when Errors is empty. AlreadyPaid counts the rows paid by an
+// earlier upload of the file; they are not paid again.
+type PayoutSummary struct {
+	SenderID      string           `json:"sender_id"`
+	Rows          int              `json:"rows"`
+	Receivers     int              `json:"receivers"`
+	TotalAmount   int64            `json:"total_amount"`
+	AlreadyPaid   int              `json:"already_paid"`
+	SenderBalance int64            `json:"sender_balance"`
+	Errors        []PayoutRowError `json:"errors"`
+}
+
+// PayoutResult is the outcome of every row of an executed payout file. Rows
+// paid by an earlier upload have the status already_paid and count towards
+// AlreadyPaid rather than Completed.
+type PayoutResult struct {
+	PayoutSummary
+	Completed int               `json:"completed"`
+	Failed    int               `json:"failed"`
+	Results   []PayoutRowResult `json:"results"`
+}
+
+type PayoutRowResult struct {
+	PayoutRow
+	Status        string `json:"status"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// InvalidPayoutError is returned by ExecutePayout when validation failed.
+// It matches ErrInvalidPayout.
+type InvalidPayoutError struct {
+	Summary *PayoutSummary
+}
+
+func (e *InvalidPayoutError) Error() string {
+	return fmt.Sprintf("%v: %d errors, first %v", ErrInvalidPayout, len(e.Summary.Errors), e.Summary.Errors[0])
+}
+
+func (e *InvalidPayoutError) Unwrap() error {
+	return ErrInvalidPayout
+}
+
+// PreviewPayout validates req without moving money: every row must have a
+// receiver with an active wallet, a positive amount and a reference used
+// neither elsewhere in the file nor by an existing transaction, and the
+// sender's balance must cover the total of the rows to pay. A row whose
+// reference is used by the same transfer, made by an earlier upload of the
+// file, counts as already paid instead, so that a partly paid file can be
+// uploaded again.
+func (u *PaymentUsecase) PreviewPayout(ctx context.Context, req PayoutRequest) (_ *PayoutSummary, err error) {
+	ctx, span := startSpan(ctx, "PreviewPayout", userAttr(req.SenderID), attribute.Int("payout.rows", len(req.Rows)))
+	defer endSpan(span, &err)
+
+	summary, _, err := u.previewPayout(ctx, req)
+	return summary, err
+}
+
+// previewPayout also returns the transaction IDs of the already paid rows by
+// reference.
+func (u *PaymentUsecase) previewPayout(ctx context.Context, req PayoutRequest) (*PayoutSummary, map[string]string, error) {
+	if len(req.Rows)+len(req.Invalid) == 0 {
+		return nil, nil, ErrEmptyPayout
+	}
+	if n := len(req.Rows) + len(req.Invalid); n > MaxPayoutRows {
+		return nil, nil, fmt.Errorf("%w: %d rows, at most %d are allowed", ErrInvalidPayout, n, MaxPayoutRows)
+	}
+
+	sender, err := u.repo.GetWalletByUserID(ctx, req.SenderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := &PayoutSummary{
+		SenderID:      req.SenderID,
+		Rows:          len(req.Rows) + len(req.Invalid),
+		SenderBalance: sender.Balance,
+		Errors:        append([]PayoutRowError{}, req.Invalid...),
+	}
+	rowError := func(line int, err error, format string, args ...any) {
+		summary.Errors = append(summary.Errors, PayoutRowError{Line: line, Code: ErrorCode(err), Message: fmt.Sprintf(format, args...)})
+	}
+	if isFrozen(sender) {
+		rowError(0, ErrWalletFrozen, "sender wallet %s is frozen", req.SenderID)
+	}
+
+	receivers := make(map[string]error)
+	references := make(map[string]int)
+	paid := make(map[string]string)
+	var paidAmount int64
+	for _, row := range req.Rows {
+		alreadyPaid := false
+		switch first, dup := references[row.Reference]; {
+		case row.Reference == "":
+			rowError(row.Line, ErrMalformedRow, "reference is required")
+		case len(row.Reference) > maxReferenceLength:
+			rowError(row.Line, ErrMalformedRow, "reference is longer than %d characters", maxReferenceLength)
+		case dup:
+			rowError(row.Line, ErrReferenceExists, "reference %s is also used on line %d", row.Reference, first)
+		default:
+			references[row.Reference] = row.Line
+			tx, err := u.repo.GetTransactionByRef(ctx, row.Reference)
+			switch {
+			case errors.Is(err, domain.ErrTransactionNotFound):
+			case err != nil:
+				return nil, nil, err
+			case sameTransfer(tx, TransferRequest{SenderID: req.SenderID, ReceiverID: row.ReceiverID, Amount: row.Amount}):
+				paid[row.Reference] = tx.ID
+				alreadyPaid = true
+			default:
+				rowError(row.Line, ErrReferenceExists, "reference %s has already been used", row.Reference)
+			}
+		}
+
+		switch {
+		case row.ReceiverID == "":
+			rowError(row.Line, ErrMalformedRow, "receiver is required")
+		case row.ReceiverID == req.SenderID:
+			rowError(row.Line, ErrSameUser, "receiver is the sender")
+		default:
+			receiverErr, seen := receivers[row.ReceiverID]
+			if !seen {
+				receiverErr = u.checkPayoutReceiver(ctx, row.ReceiverID)
+				if receiverErr != nil && ErrorCode(receiverErr) == "internal" {
+					return nil, nil, receiverErr
+				}
+				receivers[row.ReceiverID] = receiverErr
+			}
+			switch {
+			case alreadyPaid:
+				// The receiver was checked when the row was paid.
+			case errors.Is(receiverErr, domain.ErrWalletNotFound):
+				rowError(row.Line, receiverErr, "receiver %s has no wallet", row.ReceiverID)
+			case receiverErr != nil:
+				rowError(row.Line, receiverErr, "receiver %s: %v", row.ReceiverID, receiverErr)
+			}
+		}
+
+		if row.Amount <= 0 {
+			rowError(row.Line, ErrInvalidAmount, "amount must be a positive integer")
+		} else if summary.TotalAmount+row.Amount < summary.TotalAmount {
+			rowError(row.Line, ErrInvalidAmount, "total amount overflows")
+		} else {
+			summary.TotalAmount += row.Amount
+		}
+		if alreadyPaid {
+			summary.AlreadyPaid++
+			paidAmount += row.Amount
+		}
+	}
+	summary.Receivers = len(receivers)
+
+	if due := summary.TotalAmount - paidAmount; due > sender.Balance {
+		if paidAmount > 0 {
+			rowError(0, ErrInsufficientBalance, "unpaid amount %d exceeds the sender balance %d", due, sender.Balance)
+		} else {
+			rowError(0, ErrInsufficientBalance, "total amount %d exceeds the sender balance %d", due, sender.Balance)
+		}
+	}
+	slices.SortStableFunc(summary.Errors, func(a, b PayoutRowError) int { return a.Line - b.Line })
+	return summary, paid, nil
+}
+
+// checkPayoutReceiver returns the error a transfer to userID would fail with
+// because of the receiver's wallet.
+func (u *PaymentUsecase) checkPayoutReceiver(ctx context.Context, userID string) error {
+	wallet, err := u.repo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if isFrozen(wallet) {
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
+// ExecutePayout validates req like PreviewPayout and, if every row is valid,
+// transfers the rows that are not already paid in file order. It fails with
+// an *InvalidPayoutError before the first transfer otherwise. Rows can still
+// fail if wallets change in between; they are reported in the result.
+func (u *PaymentUsecase) ExecutePayout(ctx context.Context, req PayoutRequest) (_ *PayoutResult, err error) {
+	ctx, span := startSpan(ctx, "ExecutePayout", userAttr(req.SenderID), attribute.Int("payout.rows", len(req.Rows)))
+	defer endSpan(span, &err)
+
+	summary, paid, err := u.previewPayout(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(summary.Errors) > 0 {
+		return nil, &InvalidPayoutError{Summary: summary}
+	}
+
+	result := &PayoutResult{PayoutSummary: *summary, Results: make([]PayoutRowResult, 0, len(req.Rows))}
+	for _, row := range req.Rows {
+		if id, ok := paid[row.Reference]; ok {
+			result.Results = append(result.Results, PayoutRowResult{PayoutRow: row, Status: "already_paid", TransactionID: id})
+			continue
+		}
+		resp, err := u.transferOrAdopt(ctx, TransferRequest{
+			SenderID:   req.SenderID,
+			ReceiverID: row.ReceiverID,
+			Amount:     row.Amount,
+			Reference:  row.Reference,
+		})
+		rowResult := PayoutRowResult{PayoutRow: row, Status: "completed"}
+		if err != nil {
+			rowResult.Status = "failed"
+			rowResult.Error = ErrorCode(err)
+			result.Failed++
+		} else {
+			rowResult.TransactionID = resp.TransactionID
+			result.Completed++
+		}
+		result.Results = append(result.Results, rowResult)
+	}
+	return result, nil
+}
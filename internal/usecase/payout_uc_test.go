@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewPayout(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	carol, err := uc.CreateWallet(ctx, CreateWalletRequest{Username: "carol"})
+	require.NoError(t, err)
+	_, err = uc.FreezeWallet(ctx, carol.UserID)
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 1, Reference: "used"})
+	require.NoError(t, err)
+
+	summary, err := uc.PreviewPayout(ctx, PayoutRequest{
+		SenderID: alice,
+		Rows: []PayoutRow{
+			{Line: 2, ReceiverID: bob, Amount: 100, Reference: "p-1"},
+			{Line: 3, ReceiverID: "missing", Amount: 100, Reference: "p-2"},
+			{Line: 4, ReceiverID: carol.UserID, Amount: 100, Reference: "p-3"},
+			{Line: 5, ReceiverID: bob, Amount: 0, Reference: "p-4"},
+			{Line: 6, ReceiverID: bob, Amount: 100, Reference: "p-1"},
+			{Line: 7, ReceiverID: bob, Amount: 900, Reference: "used"},
+			{Line: 8, ReceiverID: alice, Amount: 1, Reference: "p-8"},
+		},
+		Invalid: []PayoutRowError{{Line: 1, Code: "invalid_request", Message: "bad row"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 8, summary.Rows)
+	require.Equal(t, 3, summary.Receivers)
+	require.Equal(t, int64(1301), summary.TotalAmount)
+	require.Equal(t, int64(999), summary.SenderBalance)
+
+	var lines []int
+	var codes []string
+	for _, e := range summary.Errors {
+		lines = append(lines, e.Line)
+		codes = append(codes, e.Code)
+	}
+	require.Equal(t, []int{0, 1, 3, 4, 5, 6, 7, 8}, lines)
+	require.Equal(t, []string{"insufficient_balance", "invalid_request", "wallet_not_found", "wallet_frozen", "invalid_amount", "reference_exists", "reference_exists", "same_user"}, codes)
+	require.Equal(t, "line 6: reference p-1 is also used on line 2", summary.Errors[5].Error())
+
+	_, err = uc.PreviewPayout(ctx, PayoutRequest{SenderID: alice})
+	require.ErrorIs(t, err, ErrEmptyPayout)
+	_, err = uc.PreviewPayout(ctx, PayoutRequest{SenderID: alice, Rows: make([]PayoutRow, MaxPayoutRows+1)})
+	require.ErrorIs(t, err, ErrInvalidPayout)
+}
+
+func TestExecutePayout(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	carol, err := uc.CreateWallet(ctx, CreateWalletRequest{Username: "carol"})
+	require.NoError(t, err)
+
+	_, err = uc.ExecutePayout(ctx, PayoutRequest{
+		SenderID: alice,
+		Rows: []PayoutRow{
+			{Line: 1, ReceiverID: bob, Amount: 100, Reference: "p-1"},
+			{Line: 2, ReceiverID: carol.UserID, Amount: 2000, Reference: "p-2"},
+		},
+	})
+	var invalid *InvalidPayoutError
+	require.True(t, errors.As(err, &invalid))
+	require.ErrorIs(t, err, ErrInvalidPayout)
+	require.Equal(t, "insufficient_balance", invalid.Summary.Errors[0].Code)
+	requireBalance(t, uc, alice, 1000)
+
+	result, err := uc.ExecutePayout(ctx, PayoutRequest{
+		SenderID: alice,
+		Rows: []PayoutRow{
+			{Line: 1, ReceiverID: bob, Amount: 100, Reference: "p-1", Note: "October"},
+			{Line: 2, ReceiverID: carol.UserID, Amount: 200, Reference: "p-2"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Completed)
+	require.Zero(t, result.Failed)
+	require.Equal(t, "October", result.Results[0].Note)
+	tx, err := uc.GetTransactionByRef(ctx, "p-2")
+	require.NoError(t, err)
+	require.Equal(t, tx.TransactionID, result.Results[1].TransactionID)
+	requireBalance(t, uc, alice, 700)
+	requireBalance(t, uc, bob, 100)
+	requireBalance(t, uc, carol.UserID, 200)
+}
+
+func TestExecutePayout_Resume(t *testing.T) {
+	ctx := t.Context()
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	carol, err := uc.CreateWallet(ctx, CreateWalletRequest{Username: "carol"})
+	require.NoError(t, err)
+	_, err = uc.FreezeWallet(ctx, carol.UserID)
+	require.NoError(t, err)
+	req := PayoutRequest{
+		SenderID: alice,
+		Rows: []PayoutRow{
+			{Line: 1, ReceiverID: bob, Amount: 100, Reference: "p-1"},
+			{Line: 2, ReceiverID: carol.UserID, Amount: 200, Reference: "p-2"},
+		},
+	}
+
+	// An earlier upload paid the first row and failed the second, since
+	// carol's wallet is frozen.
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 100, Reference: "p-1"})
+	require.NoError(t, err)
+	summary, err := uc.PreviewPayout(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.AlreadyPaid)
+	require.Equal(t, "wallet_frozen", summary.Errors[0].Code, "only the unpaid row is checked")
+
+	_, err = uc.UnfreezeWallet(ctx, carol.UserID)
+	require.NoError(t, err)
+	summary, err = uc.PreviewPayout(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, summary.Errors)
+	require.Equal(t, int64(300), summary.TotalAmount)
+
+	result, err := uc.ExecutePayout(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.AlreadyPaid)
+	require.Equal(t, 1, result.Completed)
+	require.Equal(t, "already_paid", result.Results[0].Status)
+	tx, err := uc.GetTransactionByRef(ctx, "p-1")
+	require.NoError(t, err)
+	require.Equal(t, tx.TransactionID, result.Results[0].TransactionID)
+	require.Equal(t, "completed", result.Results[1].Status)
+	requireBalance(t, uc, alice, 700)
+	requireBalance(t, uc, bob, 100)
+	requireBalance(t, uc, carol.UserID, 200)
+
+	// A reference used by a different transfer is still an error.
+	req.Rows[0].Amount = 150
+	summary, err = uc.PreviewPayout(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "reference_exists", summary.Errors[0].Code)
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultReportLimit is the number of reports ListReports returns when no
+// limit is given.
+const DefaultReportLimit = 20
+
+// ReconciliationUsecase runs the ledger check and keeps its results as
+// reconciliation reports.
+type ReconciliationUsecase struct {
+	repo    domain.TransactionRepository
+	reports domain.ReconciliationRepository
+	now     func() time.Time
+}
+
+func NewReconciliationUsecase(repo domain.TransactionRepository, reports domain.ReconciliationRepository) *ReconciliationUsecase {
+	return &ReconciliationUsecase{repo: repo, reports: reports, now: time.Now}
+}
+
+type ReconciliationResponse struct {
+	ID         string    `json:"id"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	LedgerReport
+}
+
+// Run checks the ledger and stores the result. trigger records what started
+// the run, one of the domain.ReconciliationTrigger constants.
+func (u *ReconciliationUsecase) Run(ctx context.Context, trigger string) (_ *ReconciliationResponse, err error) {
+	ctx, span := startSpan(ctx, "ReconciliationRun", attribute.String("reconciliation.trigger", trigger))
+	defer endSpan(span, &err)
+
+	startedAt := u.now()
+	ledger, err := checkLedger(ctx, u.repo)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.ReconciliationReport{
+		ID:             uuid.New().String(),
+		Trigger:        trigger,
+		Status:         domain.ReconciliationStatusOK,
+		WalletsChecked: ledger.Wallets,
+		TotalBalance:   ledger.TotalBalance,
+		ExpectedTotal:  ledger.ExpectedTotal,
+		StartedAt:      startedAt,
+		FinishedAt:     u.now(),
+	}
+	if !ledger.Consistent() {
+		report.Status = domain.ReconciliationStatusMismatch
+	}
+	for _, d := range ledger.Discrepancies {
+		report.Mismatches = append(report.Mismatches, domain.LedgerMismatch{
+			WalletID: d.WalletID,
+			UserID:   d.UserID,
+			Balance:  d.Balance,
+			Expected: d.Expected,
+		})
+	}
+
+	if err := u.reports.CreateReport(ctx, report); err != nil {
+		return nil, err
+	}
+	return reconciliationResponse(report), nil
+}
+
+func (u *ReconciliationUsecase) GetReport(ctx context.Context, id string) (_ *ReconciliationResponse, err error) {
+	ctx, span := startSpan(ctx, "ReconciliationGetReport")
+	defer endSpan(span, &err)
+
+	report, err := u.reports.GetReport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return reconciliationResponse(report), nil
+}
+
+func (u *ReconciliationUsecase) ListReports(ctx context.Context, limit int) (_ []ReconciliationResponse, err error) {
+	ctx, span := startSpan(ctx, "ReconciliationListReports")
+	defer endSpan(span, &err)
+
+	if limit <= 0 {
+		limit = DefaultReportLimit
+	}
+	reports, err := u.reports.ListReports(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]ReconciliationResponse, 0, len(reports))
+	for i := range reports {
+		responses = append(responses, *reconciliationResponse(&reports[i]))
+	}
+	return responses, nil
+}
+
+func reconciliationResponse(report *domain.ReconciliationReport) *ReconciliationResponse {
+	resp := &ReconciliationResponse{
+		ID:         report.ID,
+		Trigger:    report.Trigger,
+		Status:     report.Status,
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		LedgerReport: LedgerReport{
+			Wallets:       report.WalletsChecked,
+			TotalBalance:  report.TotalBalance,
+			ExpectedTotal: report.ExpectedTotal,
+			Discrepancies: []LedgerDiscrepancy{},
+		},
+	}
+	for _, m := range report.Mismatches {
+		resp.Discrepancies = append(resp.Discrepancies, LedgerDiscrepancy{
+			WalletID: m.WalletID,
+			UserID:   m.UserID,
+			Balance:  m.Balance,
+			Expected: m.Expected,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRun(t *testing.T) {
+	ctx := t.Context()
+	_, repo, _, bob := newAdminTestUsecase(t)
+	reconciliation := NewReconciliationUsecase(repo, repository.NewMemoryReconciliationRepo())
+
+	ok, err := reconciliation.Run(ctx, domain.ReconciliationTriggerScheduled)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReconciliationStatusOK, ok.Status)
+	require.Equal(t, domain.ReconciliationTriggerScheduled, ok.Trigger)
+	require.Equal(t, 2, ok.Wallets)
+	require.Equal(t, int64(1000), ok.TotalBalance)
+
+	tx, err := repo.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, repo.TopUpWallet(ctx, tx, bob, 5))
+	require.NoError(t, repo.CommitTx(tx))
+
+	mismatch, err := reconciliation.Run(ctx, domain.ReconciliationTriggerManual)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReconciliationStatusMismatch, mismatch.Status)
+	require.Equal(t, int64(1005), mismatch.TotalBalance)
+	require.Equal(t, int64(1000), mismatch.ExpectedTotal)
+	require.Len(t, mismatch.Discrepancies, 1)
+	require.Equal(t, bob, mismatch.Discrepancies[0].UserID)
+
+	got, err := reconciliation.GetReport(ctx, mismatch.ID)
+	require.NoError(t, err)
+	require.Equal(t, mismatch.Discrepancies, got.Discrepancies)
+
+	reports, err := reconciliation.ListReports(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, mismatch.ID, reports[0].ID)
+
+	_, err = reconciliation.GetReport(ctx, "missing")
+	require.ErrorIs(t, err, domain.ErrReportNotFound)
+}
@@ -0,0 +1,394 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/internal/domain"
+	"payment-service/internal/recurrence"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidSchedule          = errors.New("invalid schedule")
+	ErrInvalidScheduleReference = errors.New("schedule reference must be 1 to 64 characters")
+	ErrScheduleReferenceExists  = errors.New("schedule reference already exists")
+	// ErrScheduleState is returned when a schedule cannot be paused, resumed
+	// or cancelled in its current status.
+	ErrScheduleState = errors.New("schedule cannot be changed in its status")
+)
+
+// MaxScheduleReferenceLength keeps the occurrence references, the schedule
+// reference followed by "-" and the occurrence number, within the 100
+// characters of a transaction reference.
+const MaxScheduleReferenceLength = 64
+
+// scheduleLock is how long a worker holds a claimed schedule. A schedule
+// whose worker crashed is run again once its lock expires; the occurrence
+// reference keeps that from paying twice.
+const scheduleLock = time.Minute
+
+// scheduleClockSkew is how far in the past a start time may be, so that
+// clients can schedule "now" with their own clock.
+const scheduleClockSkew = time.Minute
+
+// oneOff is the rule of schedules without a recurrence.
+var oneOff = recurrence.Rule{Freq: recurrence.Daily, Interval: 1, Count: 1}
+
+// SchedulePolicy says how occurrences the sender cannot cover are retried.
+// A schedule that retries tries again every RetryInterval, at most
+// MaxRetries times and never past its next occurrence, and then skips the
+// occurrence.
+type SchedulePolicy struct {
+	RetryInterval time.Duration
+	MaxRetries    int
+}
+
+var DefaultSchedulePolicy = SchedulePolicy{RetryInterval: time.Hour, MaxRetries: 3}
+
+// ScheduleUsecase manages scheduled transfers and runs the due ones, see
+// worker.ScheduleRunner.
+type ScheduleUsecase struct {
+	payments  *PaymentUsecase
+	schedules domain.ScheduleRepository
+	policy    SchedulePolicy
+	now       func() time.Time
+}
+
+func NewScheduleUsecase(payments *PaymentUsecase, schedules domain.ScheduleRepository, policy SchedulePolicy) *ScheduleUsecase {
+	return &ScheduleUsecase{payments: payments, schedules: schedules, policy: policy, now: time.Now}
+}
+
+// CreateScheduleRequest schedules transfers of Amount from SenderID to
+// ReceiverID. Reference identifies the schedule and must not have been used
+// by another schedule. StartAt is the first occurrence, now if it is zero.
+// Recurrence is a rule in the syntax of package recurrence; empty schedules
+// a single transfer. OnInsufficientBalance is one of the domain.ScheduleRetry
+// and domain.ScheduleSkip constants, retry by default.
+type CreateScheduleRequest struct {
+	Reference             string    `json:"reference"`
+	SenderID              string    `json:"sender_id"`
+	ReceiverID            string    `json:"receiver_id"`
+	Amount                int64     `json:"amount"`
+	StartAt               time.Time `json:"start_at"`
+	Recurrence            string    `json:"recurrence"`
+	OnInsufficientBalance string    `json:"on_insufficient_balance"`
+}
+
+// ScheduleResponse describes a schedule. NextRunAt is nil once the schedule
+// is cancelled or completed.
+type ScheduleResponse struct {
+	ScheduleID            string     `json:"schedule_id"`
+	Reference             string     `json:"reference"`
+	SenderID              string     `json:"sender_id"`
+	ReceiverID            string     `json:"receiver_id"`
+	Amount                int64      `json:"amount"`
+	Recurrence            string     `json:"recurrence,omitempty"`
+	StartAt               time.Time  `json:"start_at"`
+	OnInsufficientBalance string     `json:"on_insufficient_balance"`
+	Status                string     `json:"status"`
+	Occurrence            int        `json:"occurrence"`
+	NextRunAt             *time.Time `json:"next_run_at,omitempty"`
+	Attempts              int        `json:"attempts"`
+	Completed             int        `json:"completed"`
+	Skipped               int        `json:"skipped"`
+	LastError             string     `json:"last_error,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// CreateSchedule validates req and stores an active schedule. Both wallets
+// must exist; whether they are frozen or funded is checked by every
+// occurrence.
+func (u *ScheduleUsecase) CreateSchedule(ctx context.Context, req CreateScheduleRequest) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "CreateSchedule", attribute.String("schedule.reference", req.Reference), attribute.Int64("payment.amount", req.Amount))
+	defer endSpan(span, &err)
+
+	now := u.now()
+	if req.StartAt.IsZero() {
+		req.StartAt = now
+	}
+	if req.OnInsufficientBalance == "" {
+		req.OnInsufficientBalance = domain.ScheduleRetry
+	}
+	rule, err := u.validate(req, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range []string{req.SenderID, req.ReceiverID} {
+		if _, err := u.payments.repo.GetWalletByUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	schedule := &domain.Schedule{
+		ID:                    uuid.New().String(),
+		Reference:             req.Reference,
+		SenderID:              req.SenderID,
+		ReceiverID:            req.ReceiverID,
+		Amount:                req.Amount,
+		StartAt:               req.StartAt,
+		OnInsufficientBalance: req.OnInsufficientBalance,
+		Status:                domain.ScheduleStatusActive,
+		Occurrence:            1,
+		NextRunAt:             req.StartAt,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	if req.Recurrence != "" {
+		schedule.Recurrence = rule.String()
+	}
+
+	err = u.schedules.CreateSchedule(ctx, schedule)
+	if errors.Is(err, domain.ErrDuplicateScheduleReference) {
+		return nil, ErrScheduleReferenceExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scheduleResponse(schedule), nil
+}
+
+func (u *ScheduleUsecase) validate(req CreateScheduleRequest, now time.Time) (recurrence.Rule, error) {
+	if req.Reference == "" || len(req.Reference) > MaxScheduleReferenceLength {
+		return recurrence.Rule{}, ErrInvalidScheduleReference
+	}
+	if req.Amount <= 0 {
+		return recurrence.Rule{}, ErrInvalidAmount
+	}
+	if req.SenderID == req.ReceiverID {
+		return recurrence.Rule{}, ErrSameUser
+	}
+	if req.OnInsufficientBalance != domain.ScheduleRetry && req.OnInsufficientBalance != domain.ScheduleSkip {
+		return recurrence.Rule{}, fmt.Errorf("%w: on_insufficient_balance must be retry or skip", ErrInvalidSchedule)
+	}
+	if req.StartAt.Before(now.Add(-scheduleClockSkew)) {
+		return recurrence.Rule{}, fmt.Errorf("%w: start_at must not be in the past", ErrInvalidSchedule)
+	}
+	if req.Recurrence == "" {
+		return oneOff, nil
+	}
+
+	rule, err := recurrence.Parse(req.Recurrence)
+	if err != nil {
+		return recurrence.Rule{}, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if _, ok := rule.Occurrence(req.StartAt, 1); !ok {
+		return recurrence.Rule{}, fmt.Errorf("%w: the recurrence ends before start_at", ErrInvalidSchedule)
+	}
+	return rule, nil
+}
+
+func (u *ScheduleUsecase) GetSchedule(ctx context.Context, id string) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "GetSchedule", attribute.String("schedule.id", id))
+	defer endSpan(span, &err)
+
+	schedule, err := u.schedules.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return scheduleResponse(schedule), nil
+}
+
+// PauseSchedule stops an active schedule from running until it is resumed.
+func (u *ScheduleUsecase) PauseSchedule(ctx context.Context, id string) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "PauseSchedule", attribute.String("schedule.id", id))
+	defer endSpan(span, &err)
+
+	return u.update(ctx, id, func(s *domain.Schedule) error {
+		if s.Status != domain.ScheduleStatusActive {
+			return fmt.Errorf("%w: schedule is %s", ErrScheduleState, s.Status)
+		}
+		s.Status = domain.ScheduleStatusPaused
+		return nil
+	})
+}
+
+// ResumeSchedule reactivates a paused schedule. Occurrences that fell due
+// while it was paused are skipped, except for the retry of an occurrence
+// whose successor is not due yet.
+func (u *ScheduleUsecase) ResumeSchedule(ctx context.Context, id string) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "ResumeSchedule", attribute.String("schedule.id", id))
+	defer endSpan(span, &err)
+
+	return u.update(ctx, id, func(s *domain.Schedule) error {
+		if s.Status != domain.ScheduleStatusPaused {
+			return fmt.Errorf("%w: schedule is %s", ErrScheduleState, s.Status)
+		}
+		rule, err := scheduleRule(s)
+		if err != nil {
+			return err
+		}
+		s.Status = domain.ScheduleStatusActive
+		now := u.now()
+		for s.Status == domain.ScheduleStatusActive && s.NextRunAt.Before(now) {
+			if next, ok := rule.Occurrence(s.StartAt, s.Occurrence+1); s.Attempts > 0 && (!ok || next.After(now)) {
+				break
+			}
+			s.Skipped++
+			advance(s, rule)
+		}
+		return nil
+	})
+}
+
+// CancelSchedule stops an active or paused schedule for good.
+func (u *ScheduleUsecase) CancelSchedule(ctx context.Context, id string) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "CancelSchedule", attribute.String("schedule.id", id))
+	defer endSpan(span, &err)
+
+	return u.update(ctx, id, func(s *domain.Schedule) error {
+		if s.Status != domain.ScheduleStatusActive && s.Status != domain.ScheduleStatusPaused {
+			return fmt.Errorf("%w: schedule is %s", ErrScheduleState, s.Status)
+		}
+		s.Status = domain.ScheduleStatusCancelled
+		return nil
+	})
+}
+
+// update applies change to the stored schedule, reading it again when
+// another update won the race.
+func (u *ScheduleUsecase) update(ctx context.Context, id string, change func(s *domain.Schedule) error) (*ScheduleResponse, error) {
+	for {
+		schedule, err := u.schedules.GetSchedule(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := change(schedule); err != nil {
+			return nil, err
+		}
+		schedule.UpdatedAt = u.now()
+		err = u.schedules.UpdateSchedule(ctx, schedule)
+		if errors.Is(err, domain.ErrScheduleConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return scheduleResponse(schedule), nil
+	}
+}
+
+// ProcessNext claims the schedule due the longest and pays its occurrence
+// with TransferFunds under the reference "<reference>-<occurrence>". It
+// returns domain.ErrScheduleNotFound when no schedule is due. Failed
+// transfers do not fail ProcessNext: an insufficient balance is retried or
+// skipped as the schedule says, other errors skip the occurrence.
+func (u *ScheduleUsecase) ProcessNext(ctx context.Context) (_ *ScheduleResponse, err error) {
+	ctx, span := startSpan(ctx, "ProcessSchedule")
+	defer endSpan(span, &err)
+
+	now := u.now()
+	claimed, err := u.schedules.ClaimDueSchedule(ctx, now, now.Add(scheduleLock))
+	if err != nil {
+		return nil, err
+	}
+	occurrence := claimed.Occurrence
+	span.SetAttributes(attribute.String("schedule.id", claimed.ID), attribute.Int("schedule.occurrence", occurrence))
+	rule, err := scheduleRule(claimed)
+	if err != nil {
+		return nil, err
+	}
+
+	transferErr := u.pay(ctx, claimed)
+	if ErrorCode(transferErr) == "internal" || errors.Is(transferErr, domain.ErrTxConflict) {
+		// The lock expires and the occurrence is tried again.
+		return nil, transferErr
+	}
+
+	return u.update(ctx, claimed.ID, func(s *domain.Schedule) error {
+		s.LockedUntil = time.Time{}
+		if s.Occurrence != occurrence {
+			// Resumed in the meantime, which moved past this occurrence.
+			return nil
+		}
+		switch {
+		case transferErr == nil:
+			s.Completed++
+			s.LastError = ""
+			advance(s, rule)
+		case errors.Is(transferErr, ErrInsufficientBalance) && u.retry(s, rule, now):
+			s.Attempts++
+			s.LastError = ErrorCode(transferErr)
+			s.NextRunAt = now.Add(u.policy.RetryInterval)
+		default:
+			s.Skipped++
+			s.LastError = ErrorCode(transferErr)
+			advance(s, rule)
+		}
+		return nil
+	})
+}
+
+// pay transfers the current occurrence of s. An occurrence paid before, by
+// a worker that crashed before saving the schedule, counts as paid.
+func (u *ScheduleUsecase) pay(ctx context.Context, s *domain.Schedule) error {
+	return u.payments.transferOnce(ctx, TransferRequest{
+		SenderID:   s.SenderID,
+		ReceiverID: s.ReceiverID,
+		Amount:     s.Amount,
+		Reference:  s.Reference + "-" + strconv.Itoa(s.Occurrence),
+	})
+}
+
+// retry reports whether a failed attempt of the current occurrence of s is
+// retried at now.
+func (u *ScheduleUsecase) retry(s *domain.Schedule, rule recurrence.Rule, now time.Time) bool {
+	if s.OnInsufficientBalance != domain.ScheduleRetry || s.Attempts >= u.policy.MaxRetries {
+		return false
+	}
+	next, ok := rule.Occurrence(s.StartAt, s.Occurrence+1)
+	return !ok || now.Add(u.policy.RetryInterval).Before(next)
+}
+
+// advance moves s to its next occurrence, completing active schedules that
+// have none left.
+func advance(s *domain.Schedule, rule recurrence.Rule) {
+	s.Occurrence++
+	s.Attempts = 0
+	next, ok := rule.Occurrence(s.StartAt, s.Occurrence)
+	if !ok {
+		if s.Status == domain.ScheduleStatusActive || s.Status == domain.ScheduleStatusPaused {
+			s.Status = domain.ScheduleStatusCompleted
+		}
+		return
+	}
+	s.NextRunAt = next
+}
+
+func scheduleRule(s *domain.Schedule) (recurrence.Rule, error) {
+	if s.Recurrence == "" {
+		return oneOff, nil
+	}
+	return recurrence.Parse(s.Recurrence)
+}
+
+func scheduleResponse(s *domain.Schedule) *ScheduleResponse {
+	resp := &ScheduleResponse{
+		ScheduleID:            s.ID,
+		Reference:             s.Reference,
+		SenderID:              s.SenderID,
+		ReceiverID:            s.ReceiverID,
+		Amount:                s.Amount,
+		Recurrence:            s.Recurrence,
+		StartAt:               s.StartAt,
+		OnInsufficientBalance: s.OnInsufficientBalance,
+		Status:                s.Status,
+		Occurrence:            s.Occurrence,
+		Attempts:              s.Attempts,
+		Completed:             s.Completed,
+		Skipped:               s.Skipped,
+		LastError:             s.LastError,
+		CreatedAt:             s.CreatedAt,
+		UpdatedAt:             s.UpdatedAt,
+	}
+	if s.Status == domain.ScheduleStatusActive || s.Status == domain.ScheduleStatusPaused {
+		next := s.NextRunAt
+		resp.NextRunAt = &next
+	}
+	return resp
+}
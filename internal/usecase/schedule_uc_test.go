@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newScheduleTestUsecase returns a schedule usecase over alice (1000) and
+// bob (0) whose clock is *now.
+func newScheduleTestUsecase(t *testing.T) (*ScheduleUsecase, *PaymentUsecase, string, string, *time.Time) {
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	schedules := NewScheduleUsecase(uc, repository.NewMemoryScheduleRepo(), SchedulePolicy{RetryInterval: time.Hour, MaxRetries: 2})
+	now := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	schedules.now = func() time.Time { return now }
+	return schedules, uc, alice, bob, &now
+}
+
+func TestCreateSchedule_Validation(t *testing.T) {
+	ctx := t.Context()
+	schedules, _, alice, bob, now := newScheduleTestUsecase(t)
+	valid := CreateScheduleRequest{Reference: "allowance", SenderID: alice, ReceiverID: bob, Amount: 100, Recurrence: "FREQ=MONTHLY"}
+
+	for _, tc := range []struct {
+		name   string
+		change func(req *CreateScheduleRequest)
+		want   error
+	}{
+		{"no reference", func(req *CreateScheduleRequest) { req.Reference = "" }, ErrInvalidScheduleReference},
+		{"zero amount", func(req *CreateScheduleRequest) { req.Amount = 0 }, ErrInvalidAmount},
+		{"to the sender", func(req *CreateScheduleRequest) { req.ReceiverID = alice }, ErrSameUser},
+		{"unknown policy", func(req *CreateScheduleRequest) { req.OnInsufficientBalance = "wait" }, ErrInvalidSchedule},
+		{"in the past", func(req *CreateScheduleRequest) { req.StartAt = now.Add(-time.Hour) }, ErrInvalidSchedule},
+		{"invalid rule", func(req *CreateScheduleRequest) { req.Recurrence = "FREQ=HOURLY" }, ErrInvalidSchedule},
+		{"rule ends before start", func(req *CreateScheduleRequest) { req.Recurrence = "FREQ=DAILY;UNTIL=20260101T000000Z" }, ErrInvalidSchedule},
+		{"unknown receiver", func(req *CreateScheduleRequest) { req.ReceiverID = "carol" }, domain.ErrWalletNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := valid
+			tc.change(&req)
+			_, err := schedules.CreateSchedule(ctx, req)
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	resp, err := schedules.CreateSchedule(ctx, valid)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusActive, resp.Status)
+	require.Equal(t, domain.ScheduleRetry, resp.OnInsufficientBalance, "retry is the default")
+	require.Equal(t, *now, *resp.NextRunAt, "schedules start now by default")
+
+	_, err = schedules.CreateSchedule(ctx, valid)
+	require.ErrorIs(t, err, ErrScheduleReferenceExists)
+	require.Equal(t, "reference_exists", ErrorCode(err))
+}
+
+func TestProcessSchedule_Recurring(t *testing.T) {
+	ctx := t.Context()
+	schedules, uc, alice, bob, now := newScheduleTestUsecase(t)
+
+	created, err := schedules.CreateSchedule(ctx, CreateScheduleRequest{
+		Reference:  "allowance",
+		SenderID:   alice,
+		ReceiverID: bob,
+		Amount:     100,
+		Recurrence: "RRULE:FREQ=MONTHLY;COUNT=2",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "FREQ=MONTHLY;COUNT=2", created.Recurrence)
+
+	done, err := schedules.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, done.Completed)
+	require.Equal(t, 2, done.Occurrence)
+	require.Equal(t, time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC), *done.NextRunAt)
+	_, err = uc.GetTransactionByRef(ctx, "allowance-1")
+	require.NoError(t, err)
+
+	_, err = schedules.ProcessNext(ctx)
+	require.ErrorIs(t, err, domain.ErrScheduleNotFound, "the next occurrence is not due yet")
+
+	*now = done.NextRunAt.Add(time.Second)
+	done, err = schedules.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusCompleted, done.Status)
+	require.Equal(t, 2, done.Completed)
+	require.Nil(t, done.NextRunAt)
+	_, err = uc.GetTransactionByRef(ctx, "allowance-2")
+	require.NoError(t, err)
+	requireBalance(t, uc, alice, 800)
+	requireBalance(t, uc, bob, 200)
+}
+
+func TestProcessSchedule_InsufficientBalance(t *testing.T) {
+	ctx := t.Context()
+	schedules, uc, alice, bob, now := newScheduleTestUsecase(t)
+
+	retrying, err := schedules.CreateSchedule(ctx, CreateScheduleRequest{Reference: "rent", SenderID: alice, ReceiverID: bob, Amount: 600, Recurrence: "@monthly"})
+	require.NoError(t, err)
+	skipping, err := schedules.CreateSchedule(ctx, CreateScheduleRequest{Reference: "gift", SenderID: alice, ReceiverID: bob, Amount: 600,
+		StartAt: now.Add(24 * time.Hour), Recurrence: "@monthly", OnInsufficientBalance: domain.ScheduleSkip})
+	require.NoError(t, err)
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 500, Reference: "spent"})
+	require.NoError(t, err)
+
+	// Retried every hour, at most twice, then skipped.
+	for attempt := 1; attempt <= 3; attempt++ {
+		done, err := schedules.ProcessNext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, retrying.ScheduleID, done.ScheduleID)
+		require.Equal(t, "insufficient_balance", done.LastError)
+		if attempt < 3 {
+			require.Equal(t, attempt, done.Attempts)
+			require.Equal(t, now.Add(time.Hour), *done.NextRunAt)
+			*now = now.Add(time.Hour)
+			continue
+		}
+		require.Equal(t, 1, done.Skipped)
+		require.Zero(t, done.Attempts)
+		require.Equal(t, 2, done.Occurrence)
+	}
+
+	*now = *skipping.NextRunAt
+	done, err := schedules.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, skipping.ScheduleID, done.ScheduleID)
+	require.Equal(t, 1, done.Skipped, "skipped without retrying")
+	require.Equal(t, 2, done.Occurrence)
+	requireBalance(t, uc, alice, 500)
+}
+
+func TestScheduleStates(t *testing.T) {
+	ctx := t.Context()
+	schedules, uc, alice, bob, now := newScheduleTestUsecase(t)
+
+	created, err := schedules.CreateSchedule(ctx, CreateScheduleRequest{Reference: "pocket-money", SenderID: alice, ReceiverID: bob, Amount: 10, Recurrence: "FREQ=WEEKLY"})
+	require.NoError(t, err)
+	id := created.ScheduleID
+
+	paused, err := schedules.PauseSchedule(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusPaused, paused.Status)
+	_, err = schedules.PauseSchedule(ctx, id)
+	require.ErrorIs(t, err, ErrScheduleState)
+	require.Equal(t, "invalid_schedule_state", ErrorCode(err))
+	_, err = schedules.ProcessNext(ctx)
+	require.ErrorIs(t, err, domain.ErrScheduleNotFound, "paused schedules do not run")
+
+	// The occurrences missed while paused are skipped.
+	*now = now.Add(10 * 24 * time.Hour)
+	resumed, err := schedules.ResumeSchedule(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusActive, resumed.Status)
+	require.Equal(t, 2, resumed.Skipped)
+	require.Equal(t, 3, resumed.Occurrence)
+	require.Equal(t, time.Date(2026, time.February, 14, 9, 0, 0, 0, time.UTC), *resumed.NextRunAt)
+	_, err = schedules.ResumeSchedule(ctx, id)
+	require.ErrorIs(t, err, ErrScheduleState)
+
+	cancelled, err := schedules.CancelSchedule(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusCancelled, cancelled.Status)
+	require.Nil(t, cancelled.NextRunAt)
+	_, err = schedules.CancelSchedule(ctx, id)
+	require.ErrorIs(t, err, ErrScheduleState)
+
+	_, err = schedules.GetSchedule(ctx, "missing")
+	require.ErrorIs(t, err, domain.ErrScheduleNotFound)
+	requireBalance(t, uc, bob, 0)
+}
+
+func TestProcessSchedule_OccurrencePaidBefore(t *testing.T) {
+	ctx := t.Context()
+	schedules, uc, alice, bob, _ := newScheduleTestUsecase(t)
+
+	_, err := schedules.CreateSchedule(ctx, CreateScheduleRequest{Reference: "loan", SenderID: alice, ReceiverID: bob, Amount: 100})
+	require.NoError(t, err)
+	// A worker paid the occurrence and crashed before saving the schedule.
+	_, err = uc.TransferFunds(ctx, TransferRequest{SenderID: alice, ReceiverID: bob, Amount: 100, Reference: "loan-1"})
+	require.NoError(t, err)
+
+	done, err := schedules.ProcessNext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, domain.ScheduleStatusCompleted, done.Status, "one-off schedules complete after their transfer")
+	require.Equal(t, 1, done.Completed)
+	requireBalance(t, uc, alice, 900)
+}
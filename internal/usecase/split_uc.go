@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"payment-service/internal/domain"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidSplit = errors.New("invalid split")
+	// ErrNotSplitParticipant is returned when someone who has no share of a
+	// split pays into it.
+	ErrNotSplitParticipant = errors.New("not a participant of the split")
+)
+
+const (
+	// MaxSplitParticipants bounds the shares of a split, which are stored
+	// with it.
+	MaxSplitParticipants = 50
+	// MaxSplitDescriptionLength is the length of the description column.
+	MaxSplitDescriptionLength = 200
+)
+
+// SplitUsecase lets an organizer split a bill between participants, who pay
+// their shares into the organizer's wallet with transfers made by
+// PaymentUsecase.
+type SplitUsecase struct {
+	payments *PaymentUsecase
+	splits   domain.SplitRepository
+	now      func() time.Time
+}
+
+func NewSplitUsecase(payments *PaymentUsecase, splits domain.SplitRepository) *SplitUsecase {
+	return &SplitUsecase{payments: payments, splits: splits, now: time.Now}
+}
+
+// CreateSplitRequest splits Total between Participants as Method, one of the
+// domain.SplitMethod constants and equal by default, says. Total may be
+// omitted when splitting by amount; it is the sum of the amounts then.
+type CreateSplitRequest struct {
+	OrganizerID  string             `json:"organizer_id"`
+	Description  string             `json:"description"`
+	Total        int64              `json:"total"`
+	Method       string             `json:"method"`
+	Participants []SplitParticipant `json:"participants"`
+}
+
+// SplitParticipant is a participant of a split. Amount is only set when
+// splitting by amount and Percentage, with at most two decimals, when
+// splitting by percentage. The organizer may be a participant; their share
+// counts as paid.
+type SplitParticipant struct {
+	ParticipantID string  `json:"participant_id"`
+	Amount        int64   `json:"amount,omitempty"`
+	Percentage    float64 `json:"percentage,omitempty"`
+}
+
+// SplitResponse describes a split. Collected is the sum of the paid shares
+// and Outstanding of the others.
+type SplitResponse struct {
+	SplitID     string               `json:"split_id"`
+	OrganizerID string               `json:"organizer_id"`
+	Description string               `json:"description,omitempty"`
+	Total       int64                `json:"total"`
+	Method      string               `json:"method"`
+	Status      string               `json:"status"`
+	Collected   int64                `json:"collected"`
+	Outstanding int64                `json:"outstanding"`
+	Shares      []SplitShareResponse `json:"shares"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// SplitShareResponse is the share of a participant. TransactionReference is
+// the reference of the transfer paying it, empty for the organizer's share.
+type SplitShareResponse struct {
+	ParticipantID        string `json:"participant_id"`
+	Amount               int64  `json:"amount"`
+	Status               string `json:"status"`
+	TransactionReference string `json:"transaction_reference,omitempty"`
+}
+
+// CreateSplit divides the total of req into shares and stores an open split.
+// Shares that do not divide evenly are rounded down, and the minor units
+// left over go one each to the participants with the largest remainders,
+// in the order of req.Participants on ties. All wallets must exist; whether
+// a participant can pay is checked when they pay.
+func (u *SplitUsecase) CreateSplit(ctx context.Context, req CreateSplitRequest) (_ *SplitResponse, err error) {
+	ctx, span := startSpan(ctx, "CreateSplit", attribute.Int64("payment.amount", req.Total), attribute.Int("split.participants", len(req.Participants)))
+	defer endSpan(span, &err)
+
+	if req.Method == "" {
+		req.Method = domain.SplitMethodEqual
+	}
+	amounts, err := splitAmounts(&req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := u.payments.repo.GetWalletByUserID(ctx, req.OrganizerID); err != nil {
+		return nil, err
+	}
+
+	now := u.now()
+	split := &domain.Split{
+		ID:          uuid.New().String(),
+		OrganizerID: req.OrganizerID,
+		Description: req.Description,
+		Total:       req.Total,
+		Method:      req.Method,
+		Status:      domain.SplitStatusOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for i, p := range req.Participants {
+		share := domain.SplitShare{ParticipantID: p.ParticipantID, Amount: amounts[i], Status: domain.SplitShareStatusPaid}
+		if p.ParticipantID != req.OrganizerID {
+			if _, err := u.payments.repo.GetWalletByUserID(ctx, p.ParticipantID); err != nil {
+				return nil, err
+			}
+			share.Reference = "split-" + split.ID + "-" + strconv.Itoa(i+1)
+			share.Status = domain.SplitShareStatusPending
+		}
+		split.Shares = append(split.Shares, share)
+	}
+
+	if err := u.splits.CreateSplit(ctx, split); err != nil {
+		return nil, err
+	}
+	return splitResponse(split), nil
+}
+
+// splitAmounts validates req and returns the share of each participant. It
+// sets req.Total when splitting by amount without one.
+func splitAmounts(req *CreateSplitRequest) ([]int64, error) {
+	if len(req.Description) > MaxSplitDescriptionLength {
+		return nil, fmt.Errorf("%w: description must be at most %d characters", ErrInvalidSplit, MaxSplitDescriptionLength)
+	}
+	if len(req.Participants) == 0 || len(req.Participants) > MaxSplitParticipants {
+		return nil, fmt.Errorf("%w: a split has 1 to %d participants", ErrInvalidSplit, MaxSplitParticipants)
+	}
+	seen := make(map[string]bool, len(req.Participants))
+	for _, p := range req.Participants {
+		if p.ParticipantID == "" || seen[p.ParticipantID] {
+			return nil, fmt.Errorf("%w: participants must be set and distinct", ErrInvalidSplit)
+		}
+		seen[p.ParticipantID] = true
+	}
+	if len(req.Participants) == 1 && seen[req.OrganizerID] {
+		return nil, fmt.Errorf("%w: the organizer cannot be the only participant", ErrInvalidSplit)
+	}
+
+	weights := make([]int64, len(req.Participants))
+	switch req.Method {
+	case domain.SplitMethodEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case domain.SplitMethodAmount:
+		var sum int64
+		for i, p := range req.Participants {
+			if p.Amount <= 0 {
+				return nil, ErrInvalidAmount
+			}
+			if sum > math.MaxInt64-p.Amount {
+				return nil, fmt.Errorf("%w: the amounts are too large", ErrInvalidSplit)
+			}
+			sum += p.Amount
+			weights[i] = p.Amount
+		}
+		if req.Total == 0 {
+			req.Total = sum
+		}
+		if req.Total != sum {
+			return nil, fmt.Errorf("%w: the amounts add up to %d, not the total of %d", ErrInvalidSplit, sum, req.Total)
+		}
+	case domain.SplitMethodPercentage:
+		var sum int64
+		for i, p := range req.Participants {
+			// Checked before the conversion to int64, which is undefined for
+			// NaN, infinities and values out of range.
+			if math.IsNaN(p.Percentage) || p.Percentage <= 0 || p.Percentage > 100 {
+				return nil, fmt.Errorf("%w: percentages must be above 0 and at most 100", ErrInvalidSplit)
+			}
+			hundredths := math.Round(p.Percentage * 100)
+			if math.Abs(p.Percentage*100-hundredths) > 1e-6 {
+				return nil, fmt.Errorf("%w: percentages must have at most two decimals", ErrInvalidSplit)
+			}
+			weights[i] = int64(hundredths)
+			sum += weights[i]
+		}
+		if sum != 100*100 {
+			return nil, fmt.Errorf("%w: the percentages must add up to 100", ErrInvalidSplit)
+		}
+	default:
+		return nil, fmt.Errorf("%w: method must be equal, amount or percentage", ErrInvalidSplit)
+	}
+
+	if req.Total <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	amounts := allocate(req.Total, weights)
+	for _, amount := range amounts {
+		if amount == 0 {
+			return nil, fmt.Errorf("%w: the total is too small to give every participant a share", ErrInvalidSplit)
+		}
+	}
+	return amounts, nil
+}
+
+// allocate divides total in proportion to weights. Every share is rounded
+// down and the remaining minor units go one each to the shares with the
+// largest remainders, the earliest first on ties, so that the shares add up
+// to total.
+func allocate(total int64, weights []int64) []int64 {
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	shares := make([]int64, len(weights))
+	remainders := make([]int64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		// total*w/sum without overflowing total*w.
+		shares[i] = total/sum*w + total%sum*w/sum
+		remainders[i] = total % sum * w % sum
+		allocated += shares[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:total-allocated] {
+		shares[i]++
+	}
+	return shares
+}
+
+func (u *SplitUsecase) GetSplit(ctx context.Context, id string) (_ *SplitResponse, err error) {
+	ctx, span := startSpan(ctx, "GetSplit", attribute.String("split.id", id))
+	defer endSpan(span, &err)
+
+	split, err := u.splits.GetSplit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return splitResponse(split), nil
+}
+
+// PayShare transfers the share of participantID into the organizer's wallet
+// and settles the split once every share is paid. Paying a share again does
+// not transfer twice.
+func (u *SplitUsecase) PayShare(ctx context.Context, id, participantID string) (_ *SplitResponse, err error) {
+	ctx, span := startSpan(ctx, "PayShare", attribute.String("split.id", id))
+	defer endSpan(span, &err)
+
+	split, err := u.splits.GetSplit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := shareOf(split, participantID)
+	if i < 0 {
+		return nil, ErrNotSplitParticipant
+	}
+	share := split.Shares[i]
+	if share.Status == domain.SplitShareStatusPaid {
+		return splitResponse(split), nil
+	}
+
+	err = u.payments.transferOnce(ctx, TransferRequest{
+		SenderID:   share.ParticipantID,
+		ReceiverID: split.OrganizerID,
+		Amount:     share.Amount,
+		Reference:  share.Reference,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the payment, reading the split again when another payment was
+	// recorded in between.
+	for {
+		split.Shares[i].Status = domain.SplitShareStatusPaid
+		split.Status = domain.SplitStatusSettled
+		for _, s := range split.Shares {
+			if s.Status != domain.SplitShareStatusPaid {
+				split.Status = domain.SplitStatusOpen
+			}
+		}
+		split.UpdatedAt = u.now()
+		err := u.splits.UpdateSplit(ctx, split)
+		if !errors.Is(err, domain.ErrSplitConflict) {
+			if err != nil {
+				return nil, err
+			}
+			return splitResponse(split), nil
+		}
+		if split, err = u.splits.GetSplit(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shareOf returns the index of the share of participantID in split, or -1.
+func shareOf(split *domain.Split, participantID string) int {
+	for i, s := range split.Shares {
+		if s.ParticipantID == participantID {
+			return i
+		}
+	}
+	return -1
+}
+
+func splitResponse(split *domain.Split) *SplitResponse {
+	resp := &SplitResponse{
+		SplitID:     split.ID,
+		OrganizerID: split.OrganizerID,
+		Description: split.Description,
+		Total:       split.Total,
+		Method:      split.Method,
+		Status:      split.Status,
+		Shares:      make([]SplitShareResponse, 0, len(split.Shares)),
+		CreatedAt:   split.CreatedAt,
+		UpdatedAt:   split.UpdatedAt,
+	}
+	for _, s := range split.Shares {
+		if s.Status == domain.SplitShareStatusPaid {
+			resp.Collected += s.Amount
+		} else {
+			resp.Outstanding += s.Amount
+		}
+		resp.Shares = append(resp.Shares, SplitShareResponse{
+			ParticipantID:        s.ParticipantID,
+			Amount:               s.Amount,
+			Status:               s.Status,
+			TransactionReference: s.Reference,
+		})
+	}
+	return resp
+}
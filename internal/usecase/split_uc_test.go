@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"math"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSplitTestUsecase returns a split usecase over alice (1000), bob (0) and
+// carol (500).
+func newSplitTestUsecase(t *testing.T) (*SplitUsecase, *PaymentUsecase, string, string, string) {
+	uc, _, alice, bob := newAdminTestUsecase(t)
+	carol, err := uc.CreateWallet(t.Context(), CreateWalletRequest{Username: "carol", Balance: 500})
+	require.NoError(t, err)
+	return NewSplitUsecase(uc, repository.NewMemorySplitRepo()), uc, alice, bob, carol.UserID
+}
+
+func TestAllocate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{"even", 90, []int64{1, 1, 1}, []int64{30, 30, 30}},
+		{"remainder to the first", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"two left over", 101, []int64{1, 1, 1}, []int64{34, 34, 33}},
+		{"largest remainder first", 1000, []int64{3333, 3333, 3334}, []int64{333, 333, 334}},
+		{"largest remainder wins", 101, []int64{2500, 2500, 5000}, []int64{25, 25, 51}},
+		{"by amount", 60, []int64{10, 20, 30}, []int64{10, 20, 30}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := allocate(tc.total, tc.weights)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCreateSplit_Validation(t *testing.T) {
+	ctx := t.Context()
+	splits, _, alice, bob, carol := newSplitTestUsecase(t)
+	participants := []SplitParticipant{{ParticipantID: bob}, {ParticipantID: carol}}
+
+	for _, tc := range []struct {
+		name string
+		req  CreateSplitRequest
+		want error
+	}{
+		{"no participants", CreateSplitRequest{OrganizerID: alice, Total: 100}, ErrInvalidSplit},
+		{"repeated participant", CreateSplitRequest{OrganizerID: alice, Total: 100, Participants: []SplitParticipant{{ParticipantID: bob}, {ParticipantID: bob}}}, ErrInvalidSplit},
+		{"only the organizer", CreateSplitRequest{OrganizerID: alice, Total: 100, Participants: []SplitParticipant{{ParticipantID: alice}}}, ErrInvalidSplit},
+		{"unknown method", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: "weighted", Participants: participants}, ErrInvalidSplit},
+		{"zero total", CreateSplitRequest{OrganizerID: alice, Participants: participants}, ErrInvalidAmount},
+		{"total too small", CreateSplitRequest{OrganizerID: alice, Total: 1, Participants: participants}, ErrInvalidSplit},
+		{"amounts off the total", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodAmount, Participants: []SplitParticipant{{ParticipantID: bob, Amount: 40}, {ParticipantID: carol, Amount: 50}}}, ErrInvalidSplit},
+		{"percentages under 100", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodPercentage, Participants: []SplitParticipant{{ParticipantID: bob, Percentage: 50}, {ParticipantID: carol, Percentage: 49.99}}}, ErrInvalidSplit},
+		{"percentage over 100", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodPercentage, Participants: []SplitParticipant{{ParticipantID: bob, Percentage: 150}, {ParticipantID: carol, Percentage: -50}}}, ErrInvalidSplit},
+		{"NaN percentage", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodPercentage, Participants: []SplitParticipant{{ParticipantID: bob, Percentage: math.NaN()}, {ParticipantID: carol, Percentage: 100}}}, ErrInvalidSplit},
+		{"infinite percentage", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodPercentage, Participants: []SplitParticipant{{ParticipantID: bob, Percentage: math.Inf(1)}, {ParticipantID: carol, Percentage: math.Inf(-1)}}}, ErrInvalidSplit},
+		{"three decimals", CreateSplitRequest{OrganizerID: alice, Total: 100, Method: domain.SplitMethodPercentage, Participants: []SplitParticipant{{ParticipantID: bob, Percentage: 50.005}, {ParticipantID: carol, Percentage: 49.995}}}, ErrInvalidSplit},
+		{"unknown participant", CreateSplitRequest{OrganizerID: alice, Total: 100, Participants: []SplitParticipant{{ParticipantID: bob}, {ParticipantID: "dave"}}}, domain.ErrWalletNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := splits.CreateSplit(ctx, tc.req)
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	resp, err := splits.CreateSplit(ctx, CreateSplitRequest{
+		OrganizerID: alice,
+		Method:      domain.SplitMethodPercentage,
+		Total:       1001,
+		Participants: []SplitParticipant{
+			{ParticipantID: bob, Percentage: 33.33},
+			{ParticipantID: carol, Percentage: 33.33},
+			{ParticipantID: alice, Percentage: 33.34},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{334, 333, 334}, []int64{resp.Shares[0].Amount, resp.Shares[1].Amount, resp.Shares[2].Amount})
+
+	resp, err = splits.CreateSplit(ctx, CreateSplitRequest{
+		OrganizerID:  alice,
+		Method:       domain.SplitMethodAmount,
+		Participants: []SplitParticipant{{ParticipantID: bob, Amount: 30}, {ParticipantID: carol, Amount: 70}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), resp.Total, "the total of a split by amount defaults to their sum")
+}
+
+func TestPayShare(t *testing.T) {
+	ctx := t.Context()
+	splits, uc, alice, bob, carol := newSplitTestUsecase(t)
+
+	created, err := splits.CreateSplit(ctx, CreateSplitRequest{
+		OrganizerID:  bob,
+		Description:  "dinner",
+		Total:        1000,
+		Participants: []SplitParticipant{{ParticipantID: bob}, {ParticipantID: alice}, {ParticipantID: carol}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, domain.SplitMethodEqual, created.Method)
+	require.Equal(t, domain.SplitStatusOpen, created.Status)
+	require.Equal(t, int64(334), created.Collected, "the organizer's share counts as paid")
+	require.Equal(t, int64(666), created.Outstanding)
+	require.Empty(t, created.Shares[0].TransactionReference)
+
+	_, err = splits.PayShare(ctx, created.SplitID, "dave")
+	require.ErrorIs(t, err, ErrNotSplitParticipant)
+
+	paid, err := splits.PayShare(ctx, created.SplitID, alice)
+	require.NoError(t, err)
+	require.Equal(t, domain.SplitShareStatusPaid, paid.Shares[1].Status)
+	require.Equal(t, int64(333), paid.Outstanding)
+	tx, err := uc.GetTransactionDetails(ctx, paid.Shares[1].TransactionReference)
+	require.NoError(t, err)
+	require.Equal(t, alice, tx.SenderID)
+	require.Equal(t, bob, tx.ReceiverID)
+	require.Equal(t, int64(333), tx.Amount)
+
+	again, err := splits.PayShare(ctx, created.SplitID, alice)
+	require.NoError(t, err, "paying again does not transfer twice")
+	require.Equal(t, paid.Outstanding, again.Outstanding)
+
+	_, err = uc.FreezeWallet(ctx, carol)
+	require.NoError(t, err)
+	_, err = splits.PayShare(ctx, created.SplitID, carol)
+	require.ErrorIs(t, err, ErrWalletFrozen)
+	_, err = uc.UnfreezeWallet(ctx, carol)
+	require.NoError(t, err)
+
+	settled, err := splits.PayShare(ctx, created.SplitID, carol)
+	require.NoError(t, err)
+	require.Equal(t, domain.SplitStatusSettled, settled.Status)
+	require.Equal(t, int64(1000), settled.Collected)
+	require.Zero(t, settled.Outstanding)
+	wallet, err := uc.GetWallet(ctx, bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(666), wallet.Balance)
+}
@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer uses the global tracer provider, which is a no-op until
+// internal/tracing installs one.
+var tracer = otel.Tracer("payment-service/internal/usecase")
+
+// startSpan starts the span of a usecase operation. Finish it with endSpan.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "usecase."+name, trace.WithAttributes(attrs...))
+}
+
+// userAttr is the span attribute of a user ID. The ID is redacted with
+// RedactID, as in logs.
+func userAttr(id string) attribute.KeyValue {
+	return attribute.String("payment.user_id", RedactID(id))
+}
+
+// endSpan records *err on span and ends it. It is meant to be deferred with
+// the address of a named error result.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, ErrorCode(*err))
+	}
+	span.End()
+}
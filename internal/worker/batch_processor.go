@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"time"
+)
+
+// BatchProcessor processes the pending transfer batches every interval until
+// its context is cancelled. Several instances may run against the same
+// database; each batch is claimed by one of them.
+//
+// Every interval it processes batches until none is waiting or an attempt to
+// claim or save a batch fails. Failed transfers are recorded in their batch
+// and do not count.
+type BatchProcessor struct {
+	Periodic
+}
+
+func NewBatchProcessor(uc *usecase.BatchUsecase, interval time.Duration) *BatchProcessor {
+	return &BatchProcessor{Periodic{
+		Name:     "batch processor",
+		Interval: interval,
+		Tick: func(ctx context.Context) error {
+			_, err := uc.ProcessNext(ctx)
+			return err
+		},
+		Idle: domain.ErrBatchNotFound,
+	}}
+}
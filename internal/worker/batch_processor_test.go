@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchProcessor_ProcessesPendingBatches(t *testing.T) {
+	ctx := t.Context()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := payments.CreateWallet(ctx, usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	uc := usecase.NewBatchUsecase(payments, repository.NewMemoryBatchRepo(), usecase.DefaultBatchPolicy)
+
+	processor := NewBatchProcessor(uc, 5*time.Millisecond)
+	require.Error(t, processor.Health(context.Background()))
+
+	var ids []string
+	for _, ref := range []string{"batch-1", "batch-2"} {
+		batch, err := uc.SubmitBatch(ctx, usecase.BatchTransferRequest{
+			Reference: ref,
+			SenderID:  "alice",
+			Items:     []usecase.BatchItemRequest{{ReceiverID: "bob", Amount: 10}},
+		})
+		require.NoError(t, err)
+		ids = append(ids, batch.BatchID)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		processor.Run(runCtx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		for _, id := range ids {
+			batch, err := uc.GetBatch(ctx, id)
+			if err != nil || batch.Status != domain.BatchStatusCompleted {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, processor.Health(context.Background()))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("batch processor did not stop after its context was cancelled")
+	}
+	require.ErrorContains(t, processor.Health(context.Background()), "not running")
+
+	wallet, err := payments.GetWallet(ctx, "bob")
+	require.NoError(t, err)
+	require.EqualValues(t, 120, wallet.Balance)
+}
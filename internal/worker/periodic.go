@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics records the outcome of worker runs. *metrics.Metrics implements it.
+type Metrics interface {
+	// WorkerRan is called after every Tick that did work or failed.
+	WorkerRan(worker string, err error)
+}
+
+// Periodic calls Tick every Interval until its context is cancelled. Its
+// health only reports whether it is running: a failed Tick is logged and
+// recorded in Metrics, and the next interval tries again. The workers of this
+// package are Periodics with their own Tick.
+type Periodic struct {
+	// Name names the job in health errors, logs and metrics, e.g. "batch
+	// processor".
+	Name     string
+	Interval time.Duration
+	// Tick does one unit of work. Its context is not cancelled with the
+	// context of Run, so that work in progress is finished on shutdown.
+	Tick func(ctx context.Context) error
+	// Idle, when set, makes every interval call Tick again until it fails
+	// with an error matching Idle, which means that no work is left and
+	// does not count as a failure. Without Idle, Tick is called once per
+	// interval.
+	Idle error
+	// Metrics, when set, records every Tick that did work or failed.
+	Metrics Metrics
+
+	running atomic.Bool
+}
+
+// Run blocks until ctx is cancelled. The first Tick is one interval after the
+// start.
+func (p *Periodic) Run(ctx context.Context) {
+	p.running.Store(true)
+	defer p.running.Store(false)
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.drain(ctx)
+		}
+	}
+}
+
+// Health returns an error when the job is not running. A failed Tick does not
+// make the job unhealthy: the failure may be over long before the next
+// interval, and taking the instance out of service would not help it.
+func (p *Periodic) Health(ctx context.Context) error {
+	if !p.running.Load() {
+		return fmt.Errorf("%s is not running", p.Name)
+	}
+	return nil
+}
+
+// drain calls Tick until no work is left, ctx is cancelled or Tick fails, or
+// just once without Idle.
+func (p *Periodic) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := p.Tick(context.WithoutCancel(ctx))
+		if p.Idle != nil && errors.Is(err, p.Idle) {
+			return
+		}
+		if p.Metrics != nil {
+			p.Metrics.WorkerRan(p.Name, err)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, p.Name+" failed", "error", err)
+			return
+		}
+		if p.Idle == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runPeriodic runs p until the test ends.
+func runPeriodic(t *testing.T, p *Periodic) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestPeriodic_DrainsUntilIdle(t *testing.T) {
+	idle := errors.New("idle")
+	var pending atomic.Int32
+	pending.Store(3)
+	p := &Periodic{Name: "job", Interval: 5 * time.Millisecond, Idle: idle, Tick: func(ctx context.Context) error {
+		if pending.Load() == 0 {
+			return idle
+		}
+		pending.Add(-1)
+		return nil
+	}}
+	require.ErrorContains(t, p.Health(t.Context()), "job is not running")
+
+	runPeriodic(t, p)
+	require.Eventually(t, func() bool { return pending.Load() == 0 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return p.Health(t.Context()) == nil }, time.Second, time.Millisecond,
+		"running out of work is not a failure")
+}
+
+// recordedRuns counts the runs passed to WorkerRan by outcome.
+type recordedRuns struct {
+	ok, failed atomic.Int32
+}
+
+func (r *recordedRuns) WorkerRan(worker string, err error) {
+	if err != nil {
+		r.failed.Add(1)
+	} else {
+		r.ok.Add(1)
+	}
+}
+
+func TestPeriodic_FailuresKeepItHealthy(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	runs := &recordedRuns{}
+	p := &Periodic{Name: "job", Interval: 5 * time.Millisecond, Metrics: runs, Tick: func(ctx context.Context) error {
+		if down.Load() {
+			return errors.New("database is down")
+		}
+		return nil
+	}}
+
+	runPeriodic(t, p)
+	require.Eventually(t, func() bool { return runs.failed.Load() > 0 }, time.Second, time.Millisecond)
+	require.NoError(t, p.Health(t.Context()), "a failed run does not take the instance out of service")
+
+	down.Store(false)
+	require.Eventually(t, func() bool { return runs.ok.Load() > 0 }, time.Second, time.Millisecond,
+		"the next interval tries again")
+	require.NoError(t, p.Health(t.Context()))
+}
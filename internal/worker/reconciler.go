@@ -0,0 +1,42 @@
+// Package worker contains the background jobs run by the API server.
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"time"
+)
+
+// Reconciler runs a scheduled reconciliation every interval until its
+// context is cancelled. A run that found a mismatch did not fail; the mismatch
+// is in the report.
+type Reconciler struct {
+	Periodic
+}
+
+func NewReconciler(uc *usecase.ReconciliationUsecase, interval time.Duration) *Reconciler {
+	return &Reconciler{Periodic{
+		Name:     "reconciler",
+		Interval: interval,
+		Tick: func(ctx context.Context) error {
+			return reconcile(ctx, uc)
+		},
+	}}
+}
+
+func reconcile(ctx context.Context, uc *usecase.ReconciliationUsecase) error {
+	report, err := uc.Run(ctx, domain.ReconciliationTriggerScheduled)
+	if err != nil {
+		return err
+	}
+	if report.Status != domain.ReconciliationStatusOK {
+		slog.WarnContext(ctx, "reconciliation found a mismatch",
+			"report_id", report.ID,
+			"total_balance", report.TotalBalance,
+			"expected_total", report.ExpectedTotal,
+			"wallets_differing", len(report.Discrepancies))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler_RunsUntilCancelled(t *testing.T) {
+	ctx := t.Context()
+	reports := repository.NewMemoryReconciliationRepo()
+	uc := usecase.NewReconciliationUsecase(repository.NewMemoryRepo(), reports)
+
+	reconciler := NewReconciler(uc, 5*time.Millisecond)
+	require.Error(t, reconciler.Health(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reconciler.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		stored, err := reports.ListReports(ctx, 0)
+		return err == nil && len(stored) >= 2
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, reconciler.Health(context.Background()))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reconciler did not stop after its context was cancelled")
+	}
+	require.ErrorContains(t, reconciler.Health(context.Background()), "not running")
+
+	stored, err := reports.ListReports(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReconciliationTriggerScheduled, stored[0].Trigger)
+	require.Equal(t, domain.ReconciliationStatusOK, stored[0].Status)
+}
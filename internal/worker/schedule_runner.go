@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+	"time"
+)
+
+// ScheduleRunner pays the due occurrences of scheduled transfers every
+// interval until its context is cancelled. Several instances may run against
+// the same database; each occurrence is run by one of them.
+//
+// Every interval it runs schedules until none is due or an attempt to run a
+// schedule fails. Failed transfers are recorded in their schedule and do not
+// count.
+type ScheduleRunner struct {
+	Periodic
+}
+
+func NewScheduleRunner(uc *usecase.ScheduleUsecase, interval time.Duration) *ScheduleRunner {
+	return &ScheduleRunner{Periodic{
+		Name:     "schedule runner",
+		Interval: interval,
+		Tick: func(ctx context.Context) error {
+			_, err := uc.ProcessNext(ctx)
+			return err
+		},
+		Idle: domain.ErrScheduleNotFound,
+	}}
+}
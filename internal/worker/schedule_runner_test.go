@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRunner_RunsDueSchedules(t *testing.T) {
+	ctx := t.Context()
+	payments := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := payments.CreateWallet(ctx, usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	uc := usecase.NewScheduleUsecase(payments, repository.NewMemoryScheduleRepo(), usecase.DefaultSchedulePolicy)
+
+	runner := NewScheduleRunner(uc, 5*time.Millisecond)
+	require.Error(t, runner.Health(context.Background()))
+
+	due, err := uc.CreateSchedule(ctx, usecase.CreateScheduleRequest{Reference: "now", SenderID: "alice", ReceiverID: "bob", Amount: 10})
+	require.NoError(t, err)
+	later, err := uc.CreateSchedule(ctx, usecase.CreateScheduleRequest{Reference: "later", SenderID: "alice", ReceiverID: "bob", Amount: 10,
+		StartAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(runCtx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		schedule, err := uc.GetSchedule(ctx, due.ScheduleID)
+		return err == nil && schedule.Status == domain.ScheduleStatusCompleted
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, runner.Health(context.Background()))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("schedule runner did not stop after its context was cancelled")
+	}
+	require.ErrorContains(t, runner.Health(context.Background()), "not running")
+
+	schedule, err := uc.GetSchedule(ctx, later.ScheduleID)
+	require.NoError(t, err)
+	require.Zero(t, schedule.Completed, "schedules are not run before they are due")
+	wallet, err := payments.GetWallet(ctx, "bob")
+	require.NoError(t, err)
+	require.EqualValues(t, 110, wallet.Balance)
+}
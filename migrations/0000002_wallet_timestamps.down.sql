@@ -0,0 +1,13 @@
+CREATE TABLE wallets_old (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    balance BIGINT DEFAULT 0 CONSTRAINT wallets_balance_check CHECK (balance >= 0),
+    version INT DEFAULT 1
+);
+
+INSERT INTO wallets_old (id, user_id, balance, version)
+SELECT id, user_id, balance, version FROM wallets;
+
+DROP TABLE wallets;
+
+ALTER TABLE wallets_old RENAME TO wallets;
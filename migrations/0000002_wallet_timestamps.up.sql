@@ -0,0 +1,19 @@
+-- SQLite cannot add a column with a non-constant default, so the table is
+-- rebuilt instead of altered to keep one migration set for every backend.
+-- The rebuild also makes user_id mandatory and unique: each user owns exactly
+-- one wallet.
+CREATE TABLE wallets_new (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id),
+    balance BIGINT DEFAULT 0 CONSTRAINT wallets_balance_check CHECK (balance >= 0),
+    version INT DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO wallets_new (id, user_id, balance, version)
+SELECT id, user_id, balance, version FROM wallets;
+
+DROP TABLE wallets;
+
+ALTER TABLE wallets_new RENAME TO wallets;
@@ -0,0 +1,21 @@
+-- Only transfers have both a sender and a receiver, which the previous schema
+-- version expects. Opening transactions were derived from the balances by the
+-- up migration and are dropped again, but top ups, adjustments and refunds
+-- are ledger history that cannot be expressed in the previous schema: while
+-- any exist, the INSERT below violates the CHECK and the migration is rolled
+-- back instead of deleting them.
+CREATE TEMPORARY TABLE down_0000003_guard (
+    remaining INTEGER NOT NULL
+        CONSTRAINT transactions_other_than_transfers_must_be_removed_first CHECK (remaining = 0)
+);
+
+INSERT INTO down_0000003_guard (remaining)
+SELECT COUNT(*) FROM transactions WHERE type NOT IN ('transfer', 'opening');
+
+DROP TABLE down_0000003_guard;
+
+DELETE FROM transactions WHERE type = 'opening';
+
+ALTER TABLE transactions DROP COLUMN type;
+
+ALTER TABLE wallets DROP COLUMN status;
@@ -0,0 +1,17 @@
+ALTER TABLE wallets ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+ALTER TABLE transactions ADD COLUMN type VARCHAR(20) NOT NULL DEFAULT 'transfer';
+
+-- Balances were not recorded as transactions before this migration (seeded
+-- wallets and top-ups). Record what cannot be explained by transfers as an
+-- opening balance so that the ledger check starts out consistent.
+INSERT INTO transactions (id, reference_id, type, sender_id, receiver_id, amount, status)
+SELECT id, 'OPENING-' || id, 'opening', NULL, user_id, amount, 'completed'
+FROM (
+    SELECT w.id, w.user_id,
+           w.balance
+           - COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.receiver_id = w.user_id AND t.status = 'completed'), 0)
+           + COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.sender_id = w.user_id AND t.status = 'completed'), 0) AS amount
+    FROM wallets w
+) opening
+WHERE amount <> 0;
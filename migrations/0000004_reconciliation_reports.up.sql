@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id UUID PRIMARY KEY,
+    triggered_by VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    wallets_checked INT NOT NULL,
+    total_balance BIGINT NOT NULL,
+    expected_total BIGINT NOT NULL,
+    mismatches TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reconciliation_reports_started_at_idx ON reconciliation_reports (started_at);
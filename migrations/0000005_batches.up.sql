@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS batches (
+    id UUID PRIMARY KEY,
+    reference VARCHAR(100) NOT NULL UNIQUE,
+    sender_id VARCHAR(100) NOT NULL,
+    mode VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error VARCHAR(50) NOT NULL DEFAULT '',
+    items TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS batches_status_created_at_idx ON batches (status, created_at);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS schedules (
+    id UUID PRIMARY KEY,
+    reference VARCHAR(100) NOT NULL UNIQUE,
+    sender_id VARCHAR(100) NOT NULL,
+    receiver_id VARCHAR(100) NOT NULL,
+    amount BIGINT NOT NULL,
+    recurrence VARCHAR(200) NOT NULL DEFAULT '',
+    start_at TIMESTAMP NOT NULL,
+    on_insufficient_balance VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    occurrence INTEGER NOT NULL,
+    next_run_at TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    completed INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(50) NOT NULL DEFAULT '',
+    locked_until TIMESTAMP NOT NULL,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS schedules_status_next_run_at_idx ON schedules (status, next_run_at);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id UUID PRIMARY KEY,
+    requester_id VARCHAR(100) NOT NULL,
+    payer_id VARCHAR(100) NOT NULL,
+    amount BIGINT NOT NULL,
+    note VARCHAR(200) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payment_requests_requester_id_idx ON payment_requests (requester_id, created_at);
+CREATE INDEX IF NOT EXISTS payment_requests_payer_id_idx ON payment_requests (payer_id, created_at);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS splits (
+    id UUID PRIMARY KEY,
+    organizer_id VARCHAR(100) NOT NULL,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    total BIGINT NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    shares TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS splits_organizer_id_idx ON splits (organizer_id, created_at);
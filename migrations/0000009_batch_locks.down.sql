@@ -0,0 +1,2 @@
+ALTER TABLE batches DROP COLUMN version;
+ALTER TABLE batches DROP COLUMN locked_until;
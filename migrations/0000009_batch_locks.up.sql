@@ -0,0 +1,5 @@
+-- Processing batches are locked by the worker that claimed them, so that a
+-- batch whose worker crashed is claimed again once its lock expires. The
+-- constant default keeps the ALTER valid on SQLite.
+ALTER TABLE batches ADD COLUMN locked_until TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE batches ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- The demo users are not restored; run the seed command with the demo
+-- profile to create them again.
+SELECT 1;
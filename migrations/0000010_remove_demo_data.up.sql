@@ -0,0 +1,52 @@
+-- Migration 1 inserted the demo users alice and bob, which now come from the
+-- seed command. Remove them where they are untouched: the wallet holds its
+-- initial balance, its only transaction is the opening balance recorded by
+-- migration 3, and no batch, schedule, payment request or split refers to the
+-- user. Demo users that were used are kept.
+CREATE TEMPORARY TABLE untouched_demo_users (
+    user_id VARCHAR(36) NOT NULL,
+    wallet_id VARCHAR(36) NOT NULL
+);
+
+INSERT INTO untouched_demo_users (user_id, wallet_id)
+SELECT '11111111-1111-1111-1111-111111111111', 'aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa'
+WHERE EXISTS (SELECT 1 FROM wallets WHERE id = 'aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa'
+                AND user_id = '11111111-1111-1111-1111-111111111111' AND balance = 1000000)
+  AND NOT EXISTS (SELECT 1 FROM transactions
+                  WHERE (sender_id = '11111111-1111-1111-1111-111111111111' OR receiver_id = '11111111-1111-1111-1111-111111111111')
+                    AND reference_id <> 'OPENING-aaaa1111-aaaa-aaaa-aaaa-aaaaaaaaaaaa')
+  AND NOT EXISTS (SELECT 1 FROM batches
+                  WHERE sender_id = '11111111-1111-1111-1111-111111111111' OR items LIKE '%11111111-1111-1111-1111-111111111111%')
+  AND NOT EXISTS (SELECT 1 FROM schedules
+                  WHERE sender_id = '11111111-1111-1111-1111-111111111111' OR receiver_id = '11111111-1111-1111-1111-111111111111')
+  AND NOT EXISTS (SELECT 1 FROM payment_requests
+                  WHERE requester_id = '11111111-1111-1111-1111-111111111111' OR payer_id = '11111111-1111-1111-1111-111111111111')
+  AND NOT EXISTS (SELECT 1 FROM splits
+                  WHERE organizer_id = '11111111-1111-1111-1111-111111111111' OR shares LIKE '%11111111-1111-1111-1111-111111111111%');
+
+INSERT INTO untouched_demo_users (user_id, wallet_id)
+SELECT '22222222-2222-2222-2222-222222222222', 'bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb'
+WHERE EXISTS (SELECT 1 FROM wallets WHERE id = 'bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb'
+                AND user_id = '22222222-2222-2222-2222-222222222222' AND balance = 50000)
+  AND NOT EXISTS (SELECT 1 FROM transactions
+                  WHERE (sender_id = '22222222-2222-2222-2222-222222222222' OR receiver_id = '22222222-2222-2222-2222-222222222222')
+                    AND reference_id <> 'OPENING-bbbb2222-bbbb-bbbb-bbbb-bbbbbbbbbbbb')
+  AND NOT EXISTS (SELECT 1 FROM batches
+                  WHERE sender_id = '22222222-2222-2222-2222-222222222222' OR items LIKE '%22222222-2222-2222-2222-222222222222%')
+  AND NOT EXISTS (SELECT 1 FROM schedules
+                  WHERE sender_id = '22222222-2222-2222-2222-222222222222' OR receiver_id = '22222222-2222-2222-2222-222222222222')
+  AND NOT EXISTS (SELECT 1 FROM payment_requests
+                  WHERE requester_id = '22222222-2222-2222-2222-222222222222' OR payer_id = '22222222-2222-2222-2222-222222222222')
+  AND NOT EXISTS (SELECT 1 FROM splits
+                  WHERE organizer_id = '22222222-2222-2222-2222-222222222222' OR shares LIKE '%22222222-2222-2222-2222-222222222222%');
+
+DELETE FROM transactions
+WHERE reference_id IN (SELECT 'OPENING-' || wallet_id FROM untouched_demo_users);
+
+DELETE FROM wallets
+WHERE CAST(id AS VARCHAR(36)) IN (SELECT wallet_id FROM untouched_demo_users);
+
+DELETE FROM users
+WHERE CAST(id AS VARCHAR(36)) IN (SELECT user_id FROM untouched_demo_users);
+
+DROP TABLE untouched_demo_users;
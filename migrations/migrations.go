@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations so that every database
+// backend is created from the same set of files.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,43 @@
+package client
+
+import "net/http"
+
+// Signer authenticates a request before it is sent, e.g. by adding a header
+// or a signature. body is the request body, nil when there is none, so that
+// signatures can cover it. Sign is called again for every retry.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// SignerFunc adapts a function to a Signer.
+type SignerFunc func(req *http.Request, body []byte) error
+
+func (f SignerFunc) Sign(req *http.Request, body []byte) error {
+	return f(req, body)
+}
+
+// WithSigner adds s to the signers of every request, after the ones added
+// before it.
+func WithSigner(s Signer) Option {
+	return func(c *Client) {
+		c.signers = append(c.signers, s)
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header, which the server rate limits
+// by.
+func WithAPIKey(key string) Option {
+	return WithSigner(SignerFunc(func(req *http.Request, _ []byte) error {
+		req.Header.Set("X-API-Key", key)
+		return nil
+	}))
+}
+
+// WithBearerToken sends "Authorization: Bearer <token>", as expected by the
+// admin routes and by gateways in front of the API.
+func WithBearerToken(token string) Option {
+	return WithSigner(SignerFunc(func(req *http.Request, _ []byte) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}))
+}
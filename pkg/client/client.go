@@ -0,0 +1,368 @@
+// Package client is a Go client for the /v1 HTTP API of the payment service.
+//
+// Transfers get a generated idempotency reference when none is given, so that
+// they can be retried safely after a timeout or a 5xx response. Top ups have
+// no reference and are only retried when the server cannot have processed
+// them. Error responses are returned as *Error and match the sentinel errors
+// of this package with errors.Is:
+//
+//	c, err := client.New("https://payments.internal", client.WithAPIKey(key))
+//	...
+//	_, err = c.Transfer(ctx, client.TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 500})
+//	if errors.Is(err, client.ErrInsufficientBalance) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReferencePrefix starts the references generated for transfers without one.
+const ReferencePrefix = "cli-"
+
+type TransferRequest struct {
+	SenderID   string `json:"sender_id"`
+	ReceiverID string `json:"receiver_id"`
+	Amount     int64  `json:"amount"`
+	// Reference makes the transfer idempotent. A ReferencePrefix and a UUID
+	// are used when it is empty.
+	Reference string `json:"reference"`
+
+	// ExpectedVersion, when set, fails the transfer with ErrVersionMismatch
+	// unless the sender wallet is at this version.
+	ExpectedVersion *int `json:"-"`
+}
+
+type TransferResponse struct {
+	TransactionID string    `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	SenderID      string    `json:"sender_id,omitempty"`
+	ReceiverID    string    `json:"receiver_id,omitempty"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type TopUpRequest struct {
+	UserID string `json:"user_id"`
+	Amount int64  `json:"amount"`
+
+	// ExpectedVersion, when set, fails the top up with ErrVersionMismatch
+	// unless the wallet is at this version.
+	ExpectedVersion *int `json:"-"`
+}
+
+type TopUpResponse struct {
+	UserID  string `json:"user_id"`
+	Amount  int64  `json:"amount"`
+	Balance int64  `json:"balance"`
+}
+
+type Wallet struct {
+	WalletID  string    `json:"wallet_id"`
+	UserID    string    `json:"user_id"`
+	Balance   int64     `json:"balance"`
+	Version   int       `json:"version"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Batch modes, see BatchRequest.
+const (
+	BatchModeAllOrNothing = "all_or_nothing"
+	BatchModeBestEffort   = "best_effort"
+)
+
+// BatchRequest transfers every item from SenderID. Reference identifies the
+// batch and can only be used once. Mode is BatchModeAllOrNothing or
+// BatchModeBestEffort; empty selects the server's default.
+type BatchRequest struct {
+	Reference string      `json:"reference"`
+	SenderID  string      `json:"sender_id"`
+	Mode      string      `json:"mode,omitempty"`
+	Items     []BatchItem `json:"items"`
+}
+
+// BatchItem is one transfer of a batch. The response fields are set by
+// GetBatch: Reference is the reference of the item's transaction and Error
+// the error code of a failed item.
+type BatchItem struct {
+	ReceiverID    string `json:"receiver_id"`
+	Amount        int64  `json:"amount"`
+	Reference     string `json:"reference,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Error         string `json:"error,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+// Batch is a submitted batch. Status is pending or processing until the
+// server is done with it, then completed, partially_completed or failed.
+type Batch struct {
+	BatchID     string      `json:"batch_id"`
+	Reference   string      `json:"reference"`
+	SenderID    string      `json:"sender_id"`
+	Mode        string      `json:"mode"`
+	Status      string      `json:"status"`
+	Error       string      `json:"error"`
+	TotalAmount int64       `json:"total_amount"`
+	Items       []BatchItem `json:"items"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Done reports whether the server has finished processing the batch.
+func (b *Batch) Done() bool {
+	return b.Status != "pending" && b.Status != "processing"
+}
+
+// RetryPolicy bounds the attempts of a request. The delay before attempt n+1
+// is BaseDelay doubled n-1 times, capped at MaxDelay and jittered, or the
+// Retry-After of the response if that is longer.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+var errSign = errors.New("failed to sign request")
+
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	signers      []Signer
+	retry        RetryPolicy
+	newReference func() string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with hc instead of http.DefaultClient,
+// e.g. to set timeouts or TLS settings.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. MaxAttempts 1 disables
+// retries.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// New returns a client of the API at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("base URL must be an http or https URL, got %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:      strings.TrimSuffix(u.String(), "/") + "/v1",
+		httpClient:   http.DefaultClient,
+		retry:        DefaultRetryPolicy,
+		newReference: func() string { return ReferencePrefix + uuid.NewString() },
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// Transfer moves req.Amount from the sender to the receiver. It is retried on
+// network errors, 5xx and 429 responses. If a retry finds the reference used
+// by a transfer with the same sender, receiver and amount, the earlier
+// attempt succeeded and its transaction is returned.
+func (c *Client) Transfer(ctx context.Context, req TransferRequest) (*TransferResponse, error) {
+	if req.Reference == "" {
+		req.Reference = c.newReference()
+	}
+
+	var resp TransferResponse
+	attempts, err := c.do(ctx, http.MethodPost, "/transfer", req, ifMatch(req.ExpectedVersion), true, &resp)
+	if attempts > 1 && errors.Is(err, ErrReferenceExists) {
+		earlier, getErr := c.GetTransaction(ctx, req.Reference)
+		if getErr == nil && earlier.SenderID == req.SenderID && earlier.ReceiverID == req.ReceiverID &&
+			earlier.Amount == req.Amount {
+			return earlier, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TopUp adds req.Amount to a wallet. Top ups cannot be deduplicated, so they
+// are only retried on 429 responses and when the connection could not be
+// established.
+func (c *Client) TopUp(ctx context.Context, req TopUpRequest) (*TopUpResponse, error) {
+	var resp TopUpResponse
+	if _, err := c.do(ctx, http.MethodPost, "/topup", req, ifMatch(req.ExpectedVersion), false, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) GetTransaction(ctx context.Context, reference string) (*TransferResponse, error) {
+	var resp TransferResponse
+	if _, err := c.do(ctx, http.MethodGet, "/transaction/"+url.PathEscape(reference), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) GetWallet(ctx context.Context, userID string) (*Wallet, error) {
+	var resp Wallet
+	if _, err := c.do(ctx, http.MethodGet, "/wallet/"+url.PathEscape(userID), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitBatch submits a batch of transfers, which the server processes in
+// the background; poll it with GetBatch until Done. Like top ups, it is only
+// retried when the server cannot have received it: a retry after a lost
+// response would fail with ErrReferenceExists.
+func (c *Client) SubmitBatch(ctx context.Context, req BatchRequest) (*Batch, error) {
+	var resp Batch
+	if _, err := c.do(ctx, http.MethodPost, "/transfers/batch", req, nil, false, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	var resp Batch
+	if _, err := c.do(ctx, http.MethodGet, "/batches/"+url.PathEscape(id), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func ifMatch(version *int) http.Header {
+	if version == nil {
+		return nil
+	}
+	return http.Header{"If-Match": {`"v` + strconv.Itoa(*version) + `"`}}
+}
+
+// do sends the request, retrying as allowed for an idempotent or
+// non-idempotent request, and decodes a 2xx response into out. It returns
+// how many attempts were made.
+func (c *Client) do(ctx context.Context, method, path string, in any, header http.Header, idempotent bool, out any) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body, header)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return attempt, fmt.Errorf("failed to decode response: %w", err)
+			}
+			return attempt, nil
+		}
+
+		var retryAfter time.Duration
+		retry := false
+		if err != nil {
+			if ctx.Err() != nil {
+				return attempt, ctx.Err()
+			}
+			if errors.Is(err, errSign) {
+				return attempt, err
+			}
+			retry = idempotent || isDialError(err)
+		} else {
+			apiErr := decodeError(resp)
+			resp.Body.Close()
+			err, retryAfter = apiErr, apiErr.RetryAfter
+			retry = resp.StatusCode == http.StatusTooManyRequests ||
+				(idempotent && resp.StatusCode >= http.StatusInternalServerError)
+		}
+		if !retry || attempt >= c.retry.MaxAttempts {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(max(c.backoff(attempt), retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, s := range c.signers {
+		if err := s.Sign(req, body); err != nil {
+			return nil, fmt.Errorf("%w: %w", errSign, err)
+		}
+	}
+	return c.httpClient.Do(req)
+}
+
+// backoff returns the jittered delay after the given attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retry.BaseDelay << (attempt - 1)
+	if d <= 0 || d > c.retry.MaxDelay {
+		d = c.retry.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// isDialError reports whether err happened while connecting, before any of
+// the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
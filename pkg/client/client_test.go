@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/delivery"
+	"payment-service/internal/domain"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetries = WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+// newAPI serves the /v1 payment routes over a memory repository holding the
+// wallets of alice and bob with 100 each.
+func newAPI(t *testing.T) http.Handler {
+	h, _ := newBatchAPI(t)
+	return h
+}
+
+// newBatchAPI is newAPI also returning the usecase behind the batch routes,
+// whose batches are only processed when the test calls ProcessNext.
+func newBatchAPI(t *testing.T) (http.Handler, *usecase.BatchUsecase) {
+	t.Helper()
+	uc := usecase.NewPaymentUsecase(repository.NewMemoryRepo())
+	for _, user := range []string{"alice", "bob"} {
+		_, err := uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	batches := usecase.NewBatchUsecase(uc, repository.NewMemoryBatchRepo(), usecase.DefaultBatchPolicy)
+
+	h := delivery.NewHttpHandler(uc)
+	bh := delivery.NewBatchHandler(batches)
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/transfer", h.Transfer)
+		r.Post("/topup", h.TopUp)
+		r.Get("/transaction/{refId}", h.GetTransaction)
+		r.Get("/wallet/{userId}", h.GetWallet)
+		r.Post("/transfers/batch", bh.SubmitBatch)
+		r.Get("/batches/{id}", bh.GetBatch)
+	})
+	return r, batches
+}
+
+func newClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, append([]Option{fastRetries}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := t.Context()
+	c := newClient(t, newAPI(t))
+
+	transfer, err := c.Transfer(ctx, TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 30})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(transfer.Reference, ReferencePrefix), transfer.Reference)
+	require.Equal(t, "completed", transfer.Status)
+
+	got, err := c.GetTransaction(ctx, transfer.Reference)
+	require.NoError(t, err)
+	require.Equal(t, transfer.TransactionID, got.TransactionID)
+
+	topUp, err := c.TopUp(ctx, TopUpRequest{UserID: "bob", Amount: 20})
+	require.NoError(t, err)
+	require.EqualValues(t, 150, topUp.Balance)
+
+	wallet, err := c.GetWallet(ctx, "alice")
+	require.NoError(t, err)
+	require.EqualValues(t, 70, wallet.Balance)
+
+	version := wallet.Version
+	_, err = c.Transfer(ctx, TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 1, ExpectedVersion: &version})
+	require.NoError(t, err)
+	_, err = c.TopUp(ctx, TopUpRequest{UserID: "alice", Amount: 1, ExpectedVersion: &version})
+	require.ErrorIs(t, err, ErrVersionMismatch)
+}
+
+func TestClient_Errors(t *testing.T) {
+	ctx := t.Context()
+	c := newClient(t, newAPI(t))
+
+	_, err := c.Transfer(ctx, TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 1000})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.NotErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = c.Transfer(ctx, TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 1, Reference: "ref-1"})
+	require.NoError(t, err)
+	_, err = c.Transfer(ctx, TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 1, Reference: "ref-1"})
+	require.ErrorIs(t, err, ErrReferenceExists, "a reference reused by the caller is not mistaken for a retry")
+
+	_, err = c.GetWallet(ctx, "carol")
+	require.ErrorIs(t, err, ErrWalletNotFound)
+	_, err = c.GetTransaction(ctx, "missing")
+	require.ErrorIs(t, err, ErrTransactionNotFound)
+	_, err = c.TopUp(ctx, TopUpRequest{UserID: "alice", Amount: -5})
+	require.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestClient_Batches(t *testing.T) {
+	ctx := t.Context()
+	api, batches := newBatchAPI(t)
+	c := newClient(t, api)
+
+	batch, err := c.SubmitBatch(ctx, BatchRequest{
+		Reference: "payroll",
+		SenderID:  "alice",
+		Mode:      BatchModeBestEffort,
+		Items:     []BatchItem{{ReceiverID: "bob", Amount: 30}, {ReceiverID: "carol", Amount: 10}},
+	})
+	require.NoError(t, err)
+	require.False(t, batch.Done())
+	require.EqualValues(t, 40, batch.TotalAmount)
+
+	_, err = batches.ProcessNext(ctx)
+	require.NoError(t, err)
+
+	batch, err = c.GetBatch(ctx, batch.BatchID)
+	require.NoError(t, err)
+	require.True(t, batch.Done())
+	require.Equal(t, "partially_completed", batch.Status)
+	require.Equal(t, "payroll-1", batch.Items[0].Reference)
+	require.Equal(t, ErrWalletNotFound.Code, batch.Items[1].Error)
+
+	_, err = c.SubmitBatch(ctx, BatchRequest{Reference: "empty", SenderID: "alice"})
+	require.ErrorIs(t, err, ErrInvalidBatch)
+	_, err = c.GetBatch(ctx, "missing")
+	require.ErrorIs(t, err, ErrBatchNotFound)
+}
+
+func TestClient_RetriesTransferWhoseResponseWasLost(t *testing.T) {
+	api := newAPI(t)
+	var transfers atomic.Int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/transfer" && transfers.Add(1) == 1 {
+			// The transfer goes through but the response is lost.
+			api.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+	c := newClient(t, h)
+
+	transfer, err := c.Transfer(t.Context(), TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 25})
+	require.NoError(t, err)
+	require.EqualValues(t, 25, transfer.Amount)
+	require.EqualValues(t, 2, transfers.Load())
+
+	wallet, err := c.GetWallet(t.Context(), "alice")
+	require.NoError(t, err)
+	require.EqualValues(t, 75, wallet.Balance, "the transfer happened once")
+}
+
+// flakyRepo is a repository whose next transaction fails to start after
+// failNext is set, like a database dropping a connection.
+type flakyRepo struct {
+	domain.TransactionRepository
+	failNext atomic.Bool
+}
+
+func (r *flakyRepo) BeginTx(ctx context.Context) (interface{}, error) {
+	if r.failNext.CompareAndSwap(true, false) {
+		return nil, errors.New("driver: bad connection")
+	}
+	return r.TransactionRepository.BeginTx(ctx)
+}
+
+func TestClient_RetriesTransferOnServerError(t *testing.T) {
+	repo := &flakyRepo{TransactionRepository: repository.NewMemoryRepo()}
+	uc := usecase.NewPaymentUsecase(repo)
+	for _, user := range []string{"alice", "bob"} {
+		_, err := uc.CreateWallet(t.Context(), usecase.CreateWalletRequest{UserID: user, Username: user, Balance: 100})
+		require.NoError(t, err)
+	}
+	h := delivery.NewHttpHandler(uc)
+	var transfers atomic.Int32
+	r := chi.NewRouter()
+	r.Post("/v1/transfer", func(w http.ResponseWriter, r *http.Request) {
+		transfers.Add(1)
+		h.Transfer(w, r)
+	})
+	r.Get("/v1/wallet/{userId}", h.GetWallet)
+	c := newClient(t, r)
+
+	repo.failNext.Store(true)
+	transfer, err := c.Transfer(t.Context(), TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 25})
+	require.NoError(t, err)
+	require.Equal(t, "completed", transfer.Status)
+	require.EqualValues(t, 2, transfers.Load(), "the 500 answer is retried")
+
+	wallet, err := c.GetWallet(t.Context(), "alice")
+	require.NoError(t, err)
+	require.EqualValues(t, 75, wallet.Balance)
+}
+
+func TestClient_RetryDoesNotAdoptAnotherTransfer(t *testing.T) {
+	api := newAPI(t)
+	var transfers atomic.Int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/transfer" && transfers.Add(1) == 1 {
+			// Another transfer of the same amount takes the reference while
+			// the first attempt fails.
+			other := httptest.NewRequest(http.MethodPost, "/v1/transfer",
+				strings.NewReader(`{"sender_id":"bob","receiver_id":"alice","amount":25,"reference":"ref-1"}`))
+			api.ServeHTTP(httptest.NewRecorder(), other)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+	c := newClient(t, h)
+
+	_, err := c.Transfer(t.Context(), TransferRequest{SenderID: "alice", ReceiverID: "bob", Amount: 25, Reference: "ref-1"})
+	require.ErrorIs(t, err, ErrReferenceExists)
+	require.EqualValues(t, 2, transfers.Load())
+
+	earlier, err := c.GetTransaction(t.Context(), "ref-1")
+	require.NoError(t, err)
+	require.Equal(t, "bob", earlier.SenderID)
+	require.Equal(t, "alice", earlier.ReceiverID)
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(status)
+		io.WriteString(w, `{"error":"try again"}`)
+	})
+	c := newClient(t, h)
+
+	_, err := c.GetWallet(t.Context(), "alice")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.EqualValues(t, 3, calls.Load(), "reads are retried")
+
+	calls.Store(0)
+	_, err = c.TopUp(t.Context(), TopUpRequest{UserID: "alice", Amount: 1})
+	require.Error(t, err)
+	require.EqualValues(t, 1, calls.Load(), "a top up may have been applied, it is not retried")
+
+	calls.Store(0)
+	status = http.StatusTooManyRequests
+	_, err = c.TopUp(t.Context(), TopUpRequest{UserID: "alice", Amount: 1})
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.EqualValues(t, 3, calls.Load(), "refused requests are retried")
+}
+
+func TestClient_Signers(t *testing.T) {
+	var header http.Header
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		io.WriteString(w, `{"user_id":"alice","amount":5,"balance":105}`)
+	})
+	var signed []byte
+	c := newClient(t, h,
+		WithAPIKey("key-1"),
+		WithBearerToken("token"),
+		WithSigner(SignerFunc(func(req *http.Request, body []byte) error {
+			signed = body
+			req.Header.Set("X-Signature", "sig")
+			return nil
+		})),
+	)
+
+	_, err := c.TopUp(t.Context(), TopUpRequest{UserID: "alice", Amount: 5})
+	require.NoError(t, err)
+	require.Equal(t, "key-1", header.Get("X-API-Key"))
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Equal(t, "sig", header.Get("X-Signature"))
+	require.JSONEq(t, `{"user_id":"alice","amount":5}`, string(signed))
+
+	failing := newClient(t, h, WithSigner(SignerFunc(func(*http.Request, []byte) error { return errors.New("no key") })))
+	_, err = failing.GetWallet(t.Context(), "alice")
+	require.ErrorContains(t, err, "no key")
+}
+
+func TestNew_RejectsInvalidBaseURL(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8080", "ftp://host"} {
+		_, err := New(baseURL)
+		require.Error(t, err, baseURL)
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error is an error response of the API. Compare it with the sentinel errors
+// below using errors.Is, or use errors.As to inspect the status code.
+type Error struct {
+	StatusCode int
+	// Code is the stable error code of the response, e.g.
+	// "insufficient_balance". It is empty for errors without a code.
+	Code    string
+	Message string
+	// RetryAfter is the delay the server asked for with Retry-After.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return "payment api: " + e.Message
+	}
+	return fmt.Sprintf("payment api: %s (status %d, code %q)", e.Message, e.StatusCode, e.Code)
+}
+
+// Is reports whether target is the sentinel error of e's code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.StatusCode == 0 && t.Code != "" && t.Code == e.Code
+}
+
+// Sentinel errors for the codes of the API, see the Error schema of
+// api/openapi.json.
+var (
+	ErrInvalidRequest      = &Error{Code: "invalid_request", Message: "invalid request"}
+	ErrInvalidAmount       = &Error{Code: "invalid_amount", Message: "amount must be positive"}
+	ErrSameUser            = &Error{Code: "same_user", Message: "sender and receiver must differ"}
+	ErrInsufficientBalance = &Error{Code: "insufficient_balance", Message: "insufficient balance"}
+	ErrReferenceExists     = &Error{Code: "reference_exists", Message: "reference already used"}
+	ErrVersionMismatch     = &Error{Code: "version_mismatch", Message: "wallet version mismatch"}
+	ErrWalletFrozen        = &Error{Code: "wallet_frozen", Message: "wallet is frozen"}
+	ErrWalletNotFound      = &Error{Code: "wallet_not_found", Message: "wallet not found"}
+	ErrTransactionNotFound = &Error{Code: "transaction_not_found", Message: "transaction not found"}
+	ErrInvalidBatch        = &Error{Code: "invalid_batch", Message: "invalid batch"}
+	ErrBatchNotFound       = &Error{Code: "batch_not_found", Message: "batch not found"}
+	ErrRateLimited         = &Error{Code: "rate_limited", Message: "rate limit exceeded"}
+)
+
+// decodeError builds the Error of a response that is not 2xx.
+func decodeError(resp *http.Response) *Error {
+	e := &Error{StatusCode: resp.StatusCode}
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		e.Message, e.Code = body.Error, body.Code
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if e.Code == "" && resp.StatusCode == http.StatusTooManyRequests {
+		e.Code = ErrRateLimited.Code
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return e
+}